@@ -4,6 +4,25 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
+	"time"
+)
+
+// RateLimitPolicy 令牌桶限流策略：每分钟补充 PerMinute 个令牌，桶容量为 Burst，PerMinute 为 0 时不限流
+type RateLimitPolicy struct {
+	PerMinute int
+	Burst     int
+}
+
+// 限流策略名称
+const (
+	RateLimitAPI      = "api"
+	RateLimitLogin    = "login"
+	RateLimitRegister = "register"
+	RateLimitDeduct   = "deduct"
+	RateLimitAI       = "ai"
+	// RateLimitSharePassword 分享链接访问密码错误的次数，按链接和客户端IP计数
+	RateLimitSharePassword = "share_password"
 )
 
 type Config struct {
@@ -14,6 +33,54 @@ type Config struct {
 	JWTSecret       string
 	JWTExpiresHours int
 	BcryptCost      int
+	// 是否尝试使用MongoDB变更流推送实时事件（需要副本集）
+	ChangeStreamEnabled bool
+	// 全文搜索分析器：cjk（中日韩二元组切分）或 simple（按空格分词）
+	SearchAnalyzer string
+	// 附件存储：gridfs 或 local
+	AttachmentStorage  string
+	AttachmentLocalDir string
+	// 单个附件大小上限和每个用户的附件总容量（MB）
+	AttachmentMaxSizeMB int
+	AttachmentQuotaMB   int
+	// 导出文件本地存储目录（存储后端与附件相同），直接下载的备忘录数上限，导出文件保留小时数
+	ExportLocalDir    string
+	ExportSyncLimit   int
+	ExportExpireHours int
+	// 导入文件大小上限（MB）
+	ImportMaxSizeMB int
+	// 提醒调度器扫描间隔（秒）
+	ReminderScanIntervalSeconds int
+	// 通知投递渠道，逗号分隔：event（实时事件）、webhook、log
+	Notifiers        string
+	NotifyWebhookURL string
+	// AI 模型服务：stub（本地确定性实现）或 openai（兼容 OpenAI 的接口），以及每次生成摘要消耗的算力
+	AIProvider    string
+	AIBaseURL     string
+	AIAPIKey      string
+	AIModel       string
+	AISummaryCost int
+	// 图片生成服务：stub（本地占位图）或 openai，以及生成一张标准尺寸图片消耗的算力
+	ImageProvider string
+	ImageModel    string
+	AIImageCost   int
+	// 语义搜索向量模型：stub（本地特征哈希）或 openai，以及数据库向量索引名称（为空时在进程内逐条比较）
+	EmbeddingProvider string
+	EmbeddingModel    string
+	VectorSearchIndex string
+	// 限流计数存储：memory（单实例）或 mongo（多实例共享），各类接口的限流策略，
+	// 以及可信的反向代理地址（只信任这些代理传递的客户端IP）
+	RateLimitStore string
+	RateLimits     map[string]RateLimitPolicy
+	TrustedProxies []string
+	// 登录失败锁定：同一账号或同一IP连续失败达到上限后锁定的时长，再次被锁定时加倍，最多为 4 倍
+	LoginMaxFailures   int
+	LoginIPMaxFailures int
+	LoginLockout       time.Duration
+	// 管理员用户ID，可以解除登录锁定。使用ID而不是用户名，避免他人抢先注册配置的用户名
+	AdminUserIDs []string
+	// 每个实例执行AI任务的工作协程数，为 0 时当前实例只接收任务不执行
+	AIJobWorkers int
 }
 
 var AppConfig *Config
@@ -38,6 +105,76 @@ func LoadConfig() {
 		bcryptCost = 12
 	}
 
+	// 解析变更流开关
+	changeStreamEnabled, err := strconv.ParseBool(getEnv("CHANGE_STREAM_ENABLED", "true"))
+	if err != nil {
+		changeStreamEnabled = true
+	}
+
+	// 解析附件大小限制
+	attachmentMaxSize, err := strconv.Atoi(getEnv("ATTACHMENT_MAX_SIZE_MB", "20"))
+	if err != nil {
+		attachmentMaxSize = 20
+	}
+	attachmentQuota, err := strconv.Atoi(getEnv("ATTACHMENT_QUOTA_MB", "500"))
+	if err != nil {
+		attachmentQuota = 500
+	}
+
+	// 解析导出配置
+	exportSyncLimit, err := strconv.Atoi(getEnv("EXPORT_SYNC_LIMIT", "200"))
+	if err != nil {
+		exportSyncLimit = 200
+	}
+	exportExpireHours, err := strconv.Atoi(getEnv("EXPORT_EXPIRE_HOURS", "24"))
+	if err != nil || exportExpireHours < 1 {
+		exportExpireHours = 24
+	}
+
+	// 解析导入文件大小限制
+	importMaxSize, err := strconv.Atoi(getEnv("IMPORT_MAX_SIZE_MB", "50"))
+	if err != nil || importMaxSize < 1 {
+		importMaxSize = 50
+	}
+
+	// 解析提醒扫描间隔
+	reminderScanInterval, err := strconv.Atoi(getEnv("REMINDER_SCAN_INTERVAL_SECONDS", "30"))
+	if err != nil || reminderScanInterval < 1 {
+		reminderScanInterval = 30
+	}
+
+	// 解析AI摘要价格
+	aiSummaryCost, err := strconv.Atoi(getEnv("AI_SUMMARY_COST", "10"))
+	if err != nil || aiSummaryCost < 0 {
+		aiSummaryCost = 10
+	}
+
+	// 解析图片生成价格
+	aiImageCost, err := strconv.Atoi(getEnv("AI_IMAGE_COST", "20"))
+	if err != nil || aiImageCost < 0 {
+		aiImageCost = 20
+	}
+
+	// 解析AI任务工作协程数
+	aiJobWorkers, err := strconv.Atoi(getEnv("AI_JOB_WORKERS", "2"))
+	if err != nil || aiJobWorkers < 0 {
+		aiJobWorkers = 2
+	}
+
+	// 解析登录失败锁定配置
+	loginMaxFailures, err := strconv.Atoi(getEnv("LOGIN_MAX_FAILURES", "10"))
+	if err != nil || loginMaxFailures < 1 {
+		loginMaxFailures = 10
+	}
+	loginIPMaxFailures, err := strconv.Atoi(getEnv("LOGIN_IP_MAX_FAILURES", "50"))
+	if err != nil || loginIPMaxFailures < 1 {
+		loginIPMaxFailures = 50
+	}
+	loginLockoutMinutes, err := strconv.Atoi(getEnv("LOGIN_LOCKOUT_MINUTES", "15"))
+	if err != nil || loginLockoutMinutes < 1 {
+		loginLockoutMinutes = 15
+	}
+
 	AppConfig = &Config{
 		Port:            getEnv("PORT", "8080"),
 		GinMode:         getEnv("GIN_MODE", "debug"),
@@ -46,7 +183,89 @@ func LoadConfig() {
 		JWTSecret:       getEnv("JWT_SECRET", "your-secret-key-here"),
 		JWTExpiresHours: jwtExpires,
 		BcryptCost:      bcryptCost,
+
+		ChangeStreamEnabled: changeStreamEnabled,
+		SearchAnalyzer:      getEnv("SEARCH_ANALYZER", "cjk"),
+		AttachmentStorage:   getEnv("ATTACHMENT_STORAGE", "gridfs"),
+		AttachmentLocalDir:  getEnv("ATTACHMENT_LOCAL_DIR", "./data/attachments"),
+		AttachmentMaxSizeMB: attachmentMaxSize,
+		AttachmentQuotaMB:   attachmentQuota,
+
+		ExportLocalDir:    getEnv("EXPORT_LOCAL_DIR", "./data/exports"),
+		ExportSyncLimit:   exportSyncLimit,
+		ExportExpireHours: exportExpireHours,
+		ImportMaxSizeMB:   importMaxSize,
+
+		ReminderScanIntervalSeconds: reminderScanInterval,
+		Notifiers:                   getEnv("NOTIFIERS", "event"),
+		NotifyWebhookURL:            getEnv("NOTIFY_WEBHOOK_URL", ""),
+
+		AIProvider:    getEnv("AI_PROVIDER", "stub"),
+		AIBaseURL:     getEnv("AI_BASE_URL", "https://api.openai.com/v1"),
+		AIAPIKey:      getEnv("AI_API_KEY", ""),
+		AIModel:       getEnv("AI_MODEL", "gpt-4o-mini"),
+		AISummaryCost: aiSummaryCost,
+		AIJobWorkers:  aiJobWorkers,
+
+		ImageProvider: getEnv("IMAGE_PROVIDER", "stub"),
+		ImageModel:    getEnv("IMAGE_MODEL", "dall-e-2"),
+		AIImageCost:   aiImageCost,
+
+		EmbeddingProvider: getEnv("EMBEDDING_PROVIDER", "stub"),
+		EmbeddingModel:    getEnv("EMBEDDING_MODEL", "text-embedding-3-small"),
+		VectorSearchIndex: getEnv("VECTOR_SEARCH_INDEX", ""),
+
+		RateLimitStore: getEnv("RATE_LIMIT_STORE", "memory"),
+		RateLimits: map[string]RateLimitPolicy{
+			RateLimitAPI:           getRateLimit("RATE_LIMIT_API", RateLimitPolicy{PerMinute: 300, Burst: 100}),
+			RateLimitLogin:         getRateLimit("RATE_LIMIT_LOGIN", RateLimitPolicy{PerMinute: 10, Burst: 5}),
+			RateLimitRegister:      getRateLimit("RATE_LIMIT_REGISTER", RateLimitPolicy{PerMinute: 5, Burst: 3}),
+			RateLimitDeduct:        getRateLimit("RATE_LIMIT_DEDUCT", RateLimitPolicy{PerMinute: 30, Burst: 10}),
+			RateLimitAI:            getRateLimit("RATE_LIMIT_AI", RateLimitPolicy{PerMinute: 20, Burst: 10}),
+			RateLimitSharePassword: getRateLimit("RATE_LIMIT_SHARE_PASSWORD", RateLimitPolicy{PerMinute: 1, Burst: 5}),
+		},
+		TrustedProxies: getList("TRUSTED_PROXIES"),
+
+		LoginMaxFailures:   loginMaxFailures,
+		LoginIPMaxFailures: loginIPMaxFailures,
+		LoginLockout:       time.Duration(loginLockoutMinutes) * time.Minute,
+		AdminUserIDs:       getList("ADMIN_USER_IDS"),
+	}
+}
+
+// getRateLimit 解析 "每分钟令牌数:桶容量" 格式的限流策略，省略桶容量时与每分钟令牌数相同，"0" 表示不限流
+func getRateLimit(key string, defaultValue RateLimitPolicy) RateLimitPolicy {
+	value := getEnv(key, "")
+	if value == "" {
+		return defaultValue
+	}
+
+	rate, burst, hasBurst := strings.Cut(value, ":")
+	perMinute, err := strconv.Atoi(strings.TrimSpace(rate))
+	if err != nil || perMinute < 0 {
+		log.Printf("无效的限流配置 %s=%s，使用默认值", key, value)
+		return defaultValue
+	}
+	policy := RateLimitPolicy{PerMinute: perMinute, Burst: perMinute}
+	if hasBurst {
+		policy.Burst, err = strconv.Atoi(strings.TrimSpace(burst))
+		if err != nil || policy.Burst < 1 {
+			log.Printf("无效的限流配置 %s=%s，使用默认值", key, value)
+			return defaultValue
+		}
+	}
+	return policy
+}
+
+// getList 解析逗号分隔的列表，忽略空项
+func getList(key string) []string {
+	var list []string
+	for _, item := range strings.Split(getEnv(key, ""), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
 	}
+	return list
 }
 
 func getEnv(key, defaultValue string) string {
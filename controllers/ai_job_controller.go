@@ -0,0 +1,149 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+
+	"mjbackend/middleware"
+	"mjbackend/models"
+	"mjbackend/services"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type AIJobController struct {
+	aiJobService    *services.AIJobService
+	currencyService *services.CurrencyService
+}
+
+func NewAIJobController(currencyService *services.CurrencyService) *AIJobController {
+	return &AIJobController{
+		aiJobService:    services.NewAIJobService(),
+		currencyService: currencyService,
+	}
+}
+
+// 提交后台AI任务，提交时预扣算力，任务成功后确认扣费，失败或取消时退回
+func (ctrl *AIJobController) CreateJob(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.UnauthorizedResponse("用户未认证"))
+		return
+	}
+
+	var req models.CreateAIJobRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse(err.Error()))
+		return
+	}
+
+	job, err := ctrl.aiJobService.SubmitJob(userID, &req)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "算力余额不足"):
+			respondInsufficientBalance(c, ctrl.currencyService, userID, ctrl.aiJobService.JobCost(req.Type, req.Params))
+		case err.Error() == "附件不存在":
+			c.JSON(http.StatusNotFound, models.NotFoundResponse(err.Error()))
+		case err.Error() == "进行中的AI任务过多":
+			c.JSON(http.StatusTooManyRequests, models.ErrorResponseWithCode(http.StatusTooManyRequests, err.Error()))
+		case err.Error() == "不支持的任务类型", err.Error() == "无效的备忘录ID", err.Error() == "该任务类型不接受参数",
+			err.Error() == "备忘录内容为空", err.Error() == "附件不是图片", err.Error() == "图片尺寸过大", err.Error() == "图片像素过多",
+			strings.HasPrefix(err.Error(), "无效的任务参数"):
+			c.JSON(http.StatusBadRequest, models.BadRequestResponse(err.Error()))
+		default:
+			respondMemoError(c, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusAccepted, models.SuccessWithMessage("AI任务已提交", job))
+}
+
+// 获取最近的AI任务，支持按状态和备忘录过滤
+func (ctrl *AIJobController) ListJobs(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.UnauthorizedResponse("用户未认证"))
+		return
+	}
+
+	query := &models.AIJobListQuery{Status: c.Query("status")}
+	if memoID := c.Query("memoId"); memoID != "" {
+		id, err := primitive.ObjectIDFromHex(memoID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.BadRequestResponse("无效的备忘录ID"))
+			return
+		}
+		query.MemoID = &id
+	}
+
+	jobs, err := ctrl.aiJobService.ListJobs(userID, query)
+	if err != nil {
+		if err.Error() == "无效的任务状态" {
+			c.JSON(http.StatusBadRequest, models.BadRequestResponse(err.Error()))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.InternalServerErrorResponse(err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessWithMessage("获取成功", jobs))
+}
+
+// 获取AI任务的状态、进度和结果
+func (ctrl *AIJobController) GetJob(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.UnauthorizedResponse("用户未认证"))
+		return
+	}
+
+	jobID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse("无效的任务ID"))
+		return
+	}
+
+	job, err := ctrl.aiJobService.GetJob(userID, jobID)
+	if err != nil {
+		respondAIJobError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessWithMessage("获取成功", job))
+}
+
+// 取消AI任务，执行中的任务在中止后状态变为已取消
+func (ctrl *AIJobController) CancelJob(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.UnauthorizedResponse("用户未认证"))
+		return
+	}
+
+	jobID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse("无效的任务ID"))
+		return
+	}
+
+	job, err := ctrl.aiJobService.CancelJob(userID, jobID)
+	if err != nil {
+		respondAIJobError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessWithMessage("取消成功", job))
+}
+
+func respondAIJobError(c *gin.Context, err error) {
+	switch err.Error() {
+	case "AI任务不存在":
+		c.JSON(http.StatusNotFound, models.NotFoundResponse(err.Error()))
+	case "AI任务已结束":
+		c.JSON(http.StatusConflict, models.ErrorResponseWithCode(http.StatusConflict, err.Error()))
+	default:
+		c.JSON(http.StatusInternalServerError, models.InternalServerErrorResponse(err.Error()))
+	}
+}
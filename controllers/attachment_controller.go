@@ -0,0 +1,190 @@
+package controllers
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"mjbackend/config"
+	"mjbackend/middleware"
+	"mjbackend/models"
+	"mjbackend/services"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type AttachmentController struct {
+	attachmentService *services.AttachmentService
+}
+
+func NewAttachmentController() *AttachmentController {
+	return &AttachmentController{
+		attachmentService: services.NewAttachmentService(),
+	}
+}
+
+// 上传附件
+func (ctrl *AttachmentController) UploadAttachment(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.UnauthorizedResponse("用户未认证"))
+		return
+	}
+
+	memoID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse("无效的备忘录ID"))
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse("请上传文件"))
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse("读取上传文件失败"))
+		return
+	}
+	defer file.Close()
+
+	attachment, err := ctrl.attachmentService.UploadAttachment(userID, memoID, fileHeader.Filename, file)
+	if err != nil {
+		switch err.Error() {
+		case "备忘录不存在":
+			c.JSON(http.StatusNotFound, models.NotFoundResponse(err.Error()))
+		case "没有操作权限":
+			c.JSON(http.StatusForbidden, models.ForbiddenResponse(err.Error()))
+		case "附件大小超过限制", "附件内容不能为空":
+			c.JSON(http.StatusBadRequest, models.BadRequestResponse(err.Error()))
+		case "附件容量不足":
+			// 附件占用备忘录创建者的容量
+			used, _ := ctrl.attachmentService.GetMemoOwnerUsage(memoID)
+			errorData := models.AttachmentQuotaError{
+				UsedBytes:     used,
+				QuotaBytes:    int64(config.AppConfig.AttachmentQuotaMB) << 20,
+				RequiredBytes: fileHeader.Size,
+			}
+			c.JSON(http.StatusBadRequest, models.ErrorResponseWithData(400, err.Error(), errorData))
+		default:
+			c.JSON(http.StatusInternalServerError, models.InternalServerErrorResponse(err.Error()))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessWithMessage("上传成功", attachment))
+}
+
+// 获取附件列表
+func (ctrl *AttachmentController) ListAttachments(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.UnauthorizedResponse("用户未认证"))
+		return
+	}
+
+	memoID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse("无效的备忘录ID"))
+		return
+	}
+
+	attachments, err := ctrl.attachmentService.ListAttachments(userID, memoID)
+	if err != nil {
+		respondAttachmentError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessWithMessage("获取成功", attachments))
+}
+
+// 下载附件，thumbnail=true 时返回缩略图
+func (ctrl *AttachmentController) DownloadAttachment(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.UnauthorizedResponse("用户未认证"))
+		return
+	}
+
+	memoID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse("无效的备忘录ID"))
+		return
+	}
+	attachmentID, err := primitive.ObjectIDFromHex(c.Param("attachmentId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse("无效的附件ID"))
+		return
+	}
+
+	thumbnail := c.Query("thumbnail") == "true"
+	attachment, reader, err := ctrl.attachmentService.OpenAttachment(userID, memoID, attachmentID, thumbnail)
+	if err != nil {
+		respondAttachmentError(c, err)
+		return
+	}
+	defer reader.Close()
+
+	contentType := attachment.ContentType
+	if thumbnail {
+		contentType = attachment.ThumbnailType
+	}
+
+	// 仅图片允许内联展示，其余类型一律作为下载处理，防止上传的HTML在本站域名下执行
+	disposition := "attachment"
+	if strings.HasPrefix(contentType, "image/") {
+		disposition = "inline"
+	}
+
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": attachment.Filename}))
+	c.Header("X-Content-Type-Options", "nosniff")
+	if !thumbnail {
+		c.Header("Content-Length", strconv.FormatInt(attachment.Size, 10))
+	}
+	c.Status(http.StatusOK)
+	io.Copy(c.Writer, reader)
+}
+
+// 删除附件
+func (ctrl *AttachmentController) DeleteAttachment(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.UnauthorizedResponse("用户未认证"))
+		return
+	}
+
+	memoID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse("无效的备忘录ID"))
+		return
+	}
+	attachmentID, err := primitive.ObjectIDFromHex(c.Param("attachmentId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse("无效的附件ID"))
+		return
+	}
+
+	if err := ctrl.attachmentService.DeleteAttachment(userID, memoID, attachmentID); err != nil {
+		respondAttachmentError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessWithMessage("删除成功", nil))
+}
+
+// 根据附件接口的服务层错误返回对应的状态码
+func respondAttachmentError(c *gin.Context, err error) {
+	switch err.Error() {
+	case "没有操作权限":
+		c.JSON(http.StatusForbidden, models.ForbiddenResponse(err.Error()))
+	case "备忘录不存在", "附件不存在", "附件没有缩略图":
+		c.JSON(http.StatusNotFound, models.NotFoundResponse(err.Error()))
+	default:
+		c.JSON(http.StatusInternalServerError, models.InternalServerErrorResponse(err.Error()))
+	}
+}
@@ -1,8 +1,12 @@
 package controllers
 
 import (
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
 
+	"mjbackend/middleware"
 	"mjbackend/models"
 	"mjbackend/services"
 
@@ -50,11 +54,72 @@ func (ctrl *AuthController) Login(c *gin.Context) {
 		return
 	}
 
-	loginResponse, err := ctrl.userService.Login(&req)
+	loginResponse, err := ctrl.userService.Login(&req, c.ClientIP(), c.Request.UserAgent())
 	if err != nil {
+		var throttled *services.LoginThrottledError
+		if errors.As(err, &throttled) {
+			seconds := int(math.Ceil(throttled.RetryAfter.Seconds()))
+			if seconds < 1 {
+				seconds = 1
+			}
+			c.Header("Retry-After", strconv.Itoa(seconds))
+			c.JSON(http.StatusTooManyRequests, models.ErrorResponseWithData(http.StatusTooManyRequests, throttled.Message,
+				models.RateLimitError{RetryAfter: seconds}))
+			return
+		}
 		c.JSON(http.StatusBadRequest, models.BadRequestResponse(err.Error()))
 		return
 	}
 
 	c.JSON(http.StatusOK, models.SuccessWithMessage("登录成功", loginResponse))
-}
\ No newline at end of file
+}
+
+// ListLoginAttempts 获取当前用户最近的登录记录
+func (ctrl *AuthController) ListLoginAttempts(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.UnauthorizedResponse("未授权，请先登录"))
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	attempts, err := ctrl.userService.ListLoginAttempts(userID, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.InternalServerErrorResponse("获取登录记录失败"))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(attempts))
+}
+
+// ListLoginLocks 获取当前被锁定的账号和IP（管理员）
+func (ctrl *AuthController) ListLoginLocks(c *gin.Context) {
+	locks, err := ctrl.userService.ListLoginLocks()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.InternalServerErrorResponse("获取登录锁定失败"))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(locks))
+}
+
+// UnlockLogin 解除账号或IP的登录锁定（管理员）
+func (ctrl *AuthController) UnlockLogin(c *gin.Context) {
+	var req models.UnlockLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse(err.Error()))
+		return
+	}
+
+	unlocked, err := ctrl.userService.UnlockLogin(&req)
+	if err != nil {
+		if err.Error() == "请指定要解锁的用户名或IP" {
+			c.JSON(http.StatusBadRequest, models.BadRequestResponse(err.Error()))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.InternalServerErrorResponse("解除登录锁定失败"))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessWithMessage("已解除登录锁定", gin.H{"unlocked": unlocked}))
+}
@@ -0,0 +1,179 @@
+package controllers
+
+import (
+	"net/http"
+
+	"mjbackend/middleware"
+	"mjbackend/models"
+	"mjbackend/services"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ChecklistController struct {
+	memoService *services.MemoService
+}
+
+func NewChecklistController() *ChecklistController {
+	return &ChecklistController{
+		memoService: services.NewMemoService(),
+	}
+}
+
+// 添加清单条目
+func (ctrl *ChecklistController) AddItem(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.UnauthorizedResponse("用户未认证"))
+		return
+	}
+
+	memoID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse("无效的备忘录ID"))
+		return
+	}
+
+	var req models.AddChecklistItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse(err.Error()))
+		return
+	}
+
+	memo, err := ctrl.memoService.AddChecklistItem(userID, memoID, &req)
+	if err != nil {
+		respondChecklistError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessWithMessage("添加成功", memo))
+}
+
+// 修改清单条目
+func (ctrl *ChecklistController) UpdateItem(c *gin.Context) {
+	userID, memoID, itemID, ok := checklistItemParams(c)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateChecklistItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse(err.Error()))
+		return
+	}
+
+	memo, err := ctrl.memoService.UpdateChecklistItem(userID, memoID, itemID, &req)
+	if err != nil {
+		respondChecklistError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessWithMessage("更新成功", memo))
+}
+
+// 切换清单条目的勾选状态
+func (ctrl *ChecklistController) ToggleItem(c *gin.Context) {
+	userID, memoID, itemID, ok := checklistItemParams(c)
+	if !ok {
+		return
+	}
+
+	memo, err := ctrl.memoService.ToggleChecklistItem(userID, memoID, itemID)
+	if err != nil {
+		respondChecklistError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessWithMessage("更新成功", memo))
+}
+
+// 删除清单条目
+func (ctrl *ChecklistController) DeleteItem(c *gin.Context) {
+	userID, memoID, itemID, ok := checklistItemParams(c)
+	if !ok {
+		return
+	}
+
+	memo, err := ctrl.memoService.DeleteChecklistItem(userID, memoID, itemID)
+	if err != nil {
+		respondChecklistError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessWithMessage("删除成功", memo))
+}
+
+// 清单重新排序
+func (ctrl *ChecklistController) ReorderItems(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.UnauthorizedResponse("用户未认证"))
+		return
+	}
+
+	memoID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse("无效的备忘录ID"))
+		return
+	}
+
+	var req models.ReorderChecklistRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse(err.Error()))
+		return
+	}
+
+	itemIDs := make([]primitive.ObjectID, 0, len(req.ItemIDs))
+	for _, hex := range req.ItemIDs {
+		itemID, err := primitive.ObjectIDFromHex(hex)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.BadRequestResponse("无效的条目ID"))
+			return
+		}
+		itemIDs = append(itemIDs, itemID)
+	}
+
+	memo, err := ctrl.memoService.ReorderChecklist(userID, memoID, itemIDs)
+	if err != nil {
+		respondChecklistError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessWithMessage("排序成功", memo))
+}
+
+// 解析条目操作的用户、备忘录和条目ID，失败时已写入响应
+func checklistItemParams(c *gin.Context) (userID, memoID, itemID primitive.ObjectID, ok bool) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.UnauthorizedResponse("用户未认证"))
+		return
+	}
+
+	memoID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse("无效的备忘录ID"))
+		return
+	}
+	itemID, err = primitive.ObjectIDFromHex(c.Param("itemId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse("无效的条目ID"))
+		return
+	}
+	return userID, memoID, itemID, true
+}
+
+// 根据服务层错误返回对应的状态码
+func respondChecklistError(c *gin.Context, err error) {
+	switch err.Error() {
+	case "清单条目不存在":
+		c.JSON(http.StatusNotFound, models.NotFoundResponse(err.Error()))
+	case "该备忘录不是清单", "清单条目数量超过上限", "条目顺序与当前清单不一致":
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse(err.Error()))
+	case "清单正在被其他人修改，请重试":
+		c.JSON(http.StatusConflict, models.ErrorResponseWithCode(http.StatusConflict, err.Error()))
+	default:
+		respondMemoError(c, err)
+	}
+}
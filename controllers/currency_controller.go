@@ -73,18 +73,7 @@ func (ctrl *CurrencyController) DeductBalance(c *gin.Context) {
 	if err != nil {
 		// 检查是否是余额不足错误
 		if strings.Contains(err.Error(), "算力余额不足") {
-			// 解析当前余额和需要的金额
-			balanceResp, _ := ctrl.currencyService.GetBalance(userID)
-			currentBalance := 0
-			if balanceResp != nil {
-				currentBalance = balanceResp.Balance
-			}
-			
-			errorData := models.InsufficientBalanceError{
-				CurrentBalance: currentBalance,
-				RequiredAmount: request.Amount,
-			}
-			c.JSON(http.StatusBadRequest, models.ErrorResponseWithData(400, "算力余额不足", errorData))
+			respondInsufficientBalance(c, ctrl.currencyService, userID, request.Amount)
 			return
 		}
 		c.JSON(http.StatusInternalServerError, models.InternalServerErrorResponse("扣减算力失败: "+err.Error()))
@@ -132,4 +121,18 @@ func (ctrl *CurrencyController) RechargeBalance(c *gin.Context) {
 	}
 	
 	c.JSON(http.StatusOK, models.SuccessWithMessage("充值成功", result))
-}
\ No newline at end of file
+}
+
+// respondInsufficientBalance 返回余额不足错误，附带当前余额和需要的算力
+func respondInsufficientBalance(c *gin.Context, currencyService *services.CurrencyService, userID primitive.ObjectID, required int) {
+	currentBalance := 0
+	if balanceResp, _ := currencyService.GetBalance(userID); balanceResp != nil {
+		currentBalance = balanceResp.Balance
+	}
+
+	errorData := models.InsufficientBalanceError{
+		CurrentBalance: currentBalance,
+		RequiredAmount: required,
+	}
+	c.JSON(http.StatusBadRequest, models.ErrorResponseWithData(400, "算力余额不足", errorData))
+}
@@ -0,0 +1,67 @@
+package controllers
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"mjbackend/middleware"
+	"mjbackend/models"
+	"mjbackend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SSE心跳间隔，防止代理关闭空闲连接
+const eventHeartbeatInterval = 25 * time.Second
+
+type EventController struct {
+	eventBus *services.EventBus
+}
+
+func NewEventController(eventBus *services.EventBus) *EventController {
+	return &EventController{
+		eventBus: eventBus,
+	}
+}
+
+// 以SSE推送当前用户的备忘录、通知和算力余额变更
+func (ctrl *EventController) Stream(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.UnauthorizedResponse("用户未认证"))
+		return
+	}
+
+	events, unsubscribe := ctrl.eventBus.Subscribe(userID)
+	defer unsubscribe()
+
+	heartbeat := time.NewTicker(eventHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	c.SSEvent("ready", gin.H{
+		"changeStream": ctrl.eventBus.UsingChangeStream(),
+	})
+	c.Writer.Flush()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case event, ok := <-events:
+			if !ok {
+				return false
+			}
+			c.SSEvent(event.Type, event)
+			return true
+		case <-heartbeat.C:
+			c.SSEvent("ping", gin.H{"time": time.Now()})
+			return true
+		}
+	})
+}
@@ -0,0 +1,171 @@
+package controllers
+
+import (
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"mjbackend/config"
+	"mjbackend/middleware"
+	"mjbackend/models"
+	"mjbackend/services"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ExportController struct {
+	exportService *services.ExportService
+}
+
+func NewExportController() *ExportController {
+	return &ExportController{
+		exportService: services.NewExportService(),
+	}
+}
+
+// 导出备忘录，数量较少时直接下载，否则创建后台任务
+func (ctrl *ExportController) CreateExport(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.UnauthorizedResponse("用户未认证"))
+		return
+	}
+
+	var req models.CreateExportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse(err.Error()))
+		return
+	}
+
+	if !req.Async {
+		count, err := ctrl.exportService.CountMemos(userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.InternalServerErrorResponse(err.Error()))
+			return
+		}
+		if count <= int64(config.AppConfig.ExportSyncLimit) {
+			ctrl.streamExport(c, userID, req.Format)
+			return
+		}
+	}
+
+	job, err := ctrl.exportService.CreateJob(userID, req.Format)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.InternalServerErrorResponse(err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusAccepted, models.SuccessWithMessage("导出任务已创建", job))
+}
+
+// 直接以响应流输出导出文件
+func (ctrl *ExportController) streamExport(c *gin.Context, userID primitive.ObjectID, format string) {
+	contentType := "application/zip"
+	if format == "json" {
+		contentType = "application/json"
+	}
+	filename := services.ExportFilename(format, time.Now())
+
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Status(http.StatusOK)
+
+	// 响应头已发送，出错时只能中断连接，客户端会收到不完整的文件
+	if _, err := ctrl.exportService.WriteExport(c.Request.Context(), userID, format, c.Writer); err != nil {
+		log.Printf("导出用户 %s 的备忘录失败: %v", userID.Hex(), err)
+		c.Abort()
+	}
+}
+
+// 获取最近的导出任务
+func (ctrl *ExportController) ListExports(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.UnauthorizedResponse("用户未认证"))
+		return
+	}
+
+	jobs, err := ctrl.exportService.ListJobs(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.InternalServerErrorResponse(err.Error()))
+		return
+	}
+	for i := range jobs {
+		setDownloadURL(&jobs[i])
+	}
+
+	c.JSON(http.StatusOK, models.SuccessWithMessage("获取成功", jobs))
+}
+
+// 获取导出任务状态
+func (ctrl *ExportController) GetExport(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.UnauthorizedResponse("用户未认证"))
+		return
+	}
+
+	jobID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse("无效的导出任务ID"))
+		return
+	}
+
+	job, err := ctrl.exportService.GetJob(userID, jobID)
+	if err != nil {
+		if err.Error() == "导出任务不存在" {
+			c.JSON(http.StatusNotFound, models.NotFoundResponse(err.Error()))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.InternalServerErrorResponse(err.Error()))
+		return
+	}
+	setDownloadURL(job)
+
+	c.JSON(http.StatusOK, models.SuccessWithMessage("获取成功", job))
+}
+
+// 下载导出文件
+func (ctrl *ExportController) DownloadExport(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.UnauthorizedResponse("用户未认证"))
+		return
+	}
+
+	jobID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse("无效的导出任务ID"))
+		return
+	}
+
+	job, reader, err := ctrl.exportService.OpenExport(userID, jobID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.NotFoundResponse(err.Error()))
+		return
+	}
+	defer reader.Close()
+
+	contentType := "application/zip"
+	if job.Format == "json" {
+		contentType = "application/json"
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": job.Filename}))
+	c.Header("Content-Length", strconv.FormatInt(job.Size, 10))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Status(http.StatusOK)
+	io.Copy(c.Writer, reader)
+}
+
+// 导出完成且未过期的任务返回下载地址
+func setDownloadURL(job *models.ExportJob) {
+	if job.Status == models.ExportDone && (job.ExpiresAt == nil || job.ExpiresAt.After(time.Now())) {
+		job.DownloadURL = "/api/exports/" + job.ID.Hex() + "/download"
+	}
+}
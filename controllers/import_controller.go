@@ -0,0 +1,72 @@
+package controllers
+
+import (
+	"net/http"
+
+	"mjbackend/config"
+	"mjbackend/middleware"
+	"mjbackend/models"
+	"mjbackend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ImportController struct {
+	importService *services.ImportService
+}
+
+func NewImportController() *ImportController {
+	return &ImportController{
+		importService: services.NewImportService(),
+	}
+}
+
+// 导入备忘录，支持 Markdown（zip 或单个 .md 文件）、本应用导出的 JSON 和 Evernote .enex
+func (ctrl *ImportController) ImportMemos(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.UnauthorizedResponse("用户未认证"))
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse("请上传文件"))
+		return
+	}
+	if fileHeader.Size > int64(config.AppConfig.ImportMaxSizeMB)<<20 {
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse("导入文件大小超过限制"))
+		return
+	}
+
+	// 未指定格式时按扩展名识别
+	format := c.PostForm("format")
+	if format == "" {
+		format = services.ImportFormat(fileHeader.Filename)
+	}
+	if format != models.ImportMarkdown && format != models.ImportJSON && format != models.ImportENEX {
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse("不支持的导入格式"))
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse("读取上传文件失败"))
+		return
+	}
+	defer file.Close()
+
+	result, err := ctrl.importService.ImportMemos(userID, format, fileHeader.Filename, file, fileHeader.Size)
+	if err != nil {
+		switch err.Error() {
+		case "不支持的导入格式", "导入文件格式错误", "不支持的导入文件版本", "导入文件中没有备忘录",
+			"导入的备忘录过多，请拆分后导入", "导入文件解压后过大":
+			c.JSON(http.StatusBadRequest, models.BadRequestResponse(err.Error()))
+		default:
+			c.JSON(http.StatusInternalServerError, models.InternalServerErrorResponse(err.Error()))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessWithMessage("导入完成", result))
+}
@@ -0,0 +1,92 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+
+	"mjbackend/middleware"
+	"mjbackend/models"
+	"mjbackend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type KeyController struct {
+	keyService *services.KeyService
+}
+
+func NewKeyController() *KeyController {
+	return &KeyController{
+		keyService: services.NewKeyService(),
+	}
+}
+
+// 获取当前用户的加密密钥材料（包裹后的密钥和恢复密钥）
+func (ctrl *KeyController) ListKeys(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.UnauthorizedResponse("用户未认证"))
+		return
+	}
+
+	keys, err := ctrl.keyService.ListKeys(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.InternalServerErrorResponse(err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessWithMessage("获取成功", keys))
+}
+
+// 保存或替换加密密钥材料
+func (ctrl *KeyController) PutKey(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.UnauthorizedResponse("用户未认证"))
+		return
+	}
+
+	var req models.UserKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse(err.Error()))
+		return
+	}
+
+	key, err := ctrl.keyService.PutKey(userID, c.Param("keyId"), &req)
+	if err != nil {
+		respondKeyError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessWithMessage("保存成功", key))
+}
+
+// 删除加密密钥材料
+func (ctrl *KeyController) DeleteKey(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.UnauthorizedResponse("用户未认证"))
+		return
+	}
+
+	if err := ctrl.keyService.DeleteKey(userID, c.Param("keyId")); err != nil {
+		respondKeyError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessWithMessage("删除成功", nil))
+}
+
+func respondKeyError(c *gin.Context, err error) {
+	switch {
+	case err.Error() == "密钥不存在":
+		c.JSON(http.StatusNotFound, models.NotFoundResponse(err.Error()))
+	case err.Error() == "密钥仍被加密备忘录使用":
+		c.JSON(http.StatusConflict, models.ErrorResponseWithCode(http.StatusConflict, err.Error()))
+	case err.Error() == "无效的密钥ID", err.Error() == "密钥数量超过上限",
+		strings.HasPrefix(err.Error(), "密钥格式无效"), strings.HasPrefix(err.Error(), "恢复密钥格式无效"):
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse(err.Error()))
+	default:
+		c.JSON(http.StatusInternalServerError, models.InternalServerErrorResponse(err.Error()))
+	}
+}
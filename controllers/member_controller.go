@@ -0,0 +1,212 @@
+package controllers
+
+import (
+	"net/http"
+
+	"mjbackend/middleware"
+	"mjbackend/models"
+	"mjbackend/services"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type MemberController struct {
+	memberService *services.MemberService
+}
+
+func NewMemberController() *MemberController {
+	return &MemberController{
+		memberService: services.NewMemberService(),
+	}
+}
+
+// 邀请协作者
+func (ctrl *MemberController) InviteMember(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.UnauthorizedResponse("用户未认证"))
+		return
+	}
+	username, _ := middleware.GetUsername(c)
+
+	memoID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse("无效的备忘录ID"))
+		return
+	}
+
+	var req models.InviteMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse(err.Error()))
+		return
+	}
+
+	member, err := ctrl.memberService.InviteMember(userID, username, memoID, &req)
+	if err != nil {
+		respondMemberError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessWithMessage("邀请成功", member))
+}
+
+// 获取协作者列表
+func (ctrl *MemberController) ListMembers(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.UnauthorizedResponse("用户未认证"))
+		return
+	}
+
+	memoID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse("无效的备忘录ID"))
+		return
+	}
+
+	members, err := ctrl.memberService.ListMembers(userID, memoID)
+	if err != nil {
+		respondMemberError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessWithMessage("获取成功", members))
+}
+
+// 修改协作者角色
+func (ctrl *MemberController) UpdateMember(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.UnauthorizedResponse("用户未认证"))
+		return
+	}
+
+	memoID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse("无效的备忘录ID"))
+		return
+	}
+	memberID, err := primitive.ObjectIDFromHex(c.Param("memberId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse("无效的协作者ID"))
+		return
+	}
+
+	var req models.UpdateMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse(err.Error()))
+		return
+	}
+
+	member, err := ctrl.memberService.UpdateMemberRole(userID, memoID, memberID, req.Role)
+	if err != nil {
+		respondMemberError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessWithMessage("更新成功", member))
+}
+
+// 移除协作者或退出协作
+func (ctrl *MemberController) RemoveMember(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.UnauthorizedResponse("用户未认证"))
+		return
+	}
+
+	memoID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse("无效的备忘录ID"))
+		return
+	}
+	memberID, err := primitive.ObjectIDFromHex(c.Param("memberId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse("无效的协作者ID"))
+		return
+	}
+
+	if err := ctrl.memberService.RemoveMember(userID, memoID, memberID); err != nil {
+		respondMemberError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessWithMessage("移除成功", nil))
+}
+
+// 获取待处理的协作邀请
+func (ctrl *MemberController) ListInvitations(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.UnauthorizedResponse("用户未认证"))
+		return
+	}
+
+	invitations, err := ctrl.memberService.ListInvitations(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.InternalServerErrorResponse(err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessWithMessage("获取成功", invitations))
+}
+
+// 接受协作邀请
+func (ctrl *MemberController) AcceptInvitation(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.UnauthorizedResponse("用户未认证"))
+		return
+	}
+
+	invitationID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse("无效的邀请ID"))
+		return
+	}
+
+	member, err := ctrl.memberService.AcceptInvitation(userID, invitationID)
+	if err != nil {
+		respondMemberError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessWithMessage("已接受邀请", member))
+}
+
+// 拒绝协作邀请
+func (ctrl *MemberController) DeclineInvitation(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.UnauthorizedResponse("用户未认证"))
+		return
+	}
+
+	invitationID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse("无效的邀请ID"))
+		return
+	}
+
+	if err := ctrl.memberService.DeclineInvitation(userID, invitationID); err != nil {
+		respondMemberError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessWithMessage("已拒绝邀请", nil))
+}
+
+// 根据服务层错误返回对应的状态码
+func respondMemberError(c *gin.Context, err error) {
+	switch err.Error() {
+	case "没有操作权限":
+		c.JSON(http.StatusForbidden, models.ForbiddenResponse(err.Error()))
+	case "备忘录不存在", "用户不存在", "协作者不存在", "邀请不存在":
+		c.JSON(http.StatusNotFound, models.NotFoundResponse(err.Error()))
+	case "不能邀请备忘录创建者", "该用户已是协作者或已被邀请":
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse(err.Error()))
+	default:
+		c.JSON(http.StatusInternalServerError, models.InternalServerErrorResponse(err.Error()))
+	}
+}
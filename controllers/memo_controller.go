@@ -1,9 +1,12 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 
+	"mjbackend/config"
 	"mjbackend/middleware"
 	"mjbackend/models"
 	"mjbackend/services"
@@ -13,12 +16,14 @@ import (
 )
 
 type MemoController struct {
-	memoService *services.MemoService
+	memoService     *services.MemoService
+	currencyService *services.CurrencyService
 }
 
 func NewMemoController() *MemoController {
 	return &MemoController{
-		memoService: services.NewMemoService(),
+		memoService:     services.NewMemoService(),
+		currencyService: services.NewCurrencyService(),
 	}
 }
 
@@ -35,10 +40,22 @@ func (ctrl *MemoController) CreateMemo(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, models.BadRequestResponse(err.Error()))
 		return
 	}
+	// 使用模板时清单类型可能来自模板，由服务层在合并模板后校验
+	if req.TemplateID == "" && req.Type != models.MemoTypeChecklist && len(req.Items) > 0 {
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse("只有清单备忘录可以包含条目"))
+		return
+	}
 
 	memo, err := ctrl.memoService.CreateMemo(userID, &req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.InternalServerErrorResponse(err.Error()))
+		switch {
+		case err.Error() == "模板不存在", err.Error() == "无效的时区", err.Error() == "标题不能为空",
+			err.Error() == "只有清单备忘录可以包含条目", err.Error() == "模板生成的标题过长",
+			err.Error() == "模板生成的清单条目过长", isEncryptionError(err):
+			c.JSON(http.StatusBadRequest, models.BadRequestResponse(err.Error()))
+		default:
+			c.JSON(http.StatusInternalServerError, models.InternalServerErrorResponse(err.Error()))
+		}
 		return
 	}
 
@@ -56,7 +73,7 @@ func (ctrl *MemoController) GetMemoList(c *gin.Context) {
 	// 获取查询参数
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
-	keyword := c.Query("keyword")
+	cursor, useCursor := c.GetQuery("cursor")
 
 	// 参数验证
 	if page < 1 {
@@ -66,8 +83,55 @@ func (ctrl *MemoController) GetMemoList(c *gin.Context) {
 		limit = 10
 	}
 
-	memoList, err := ctrl.memoService.GetMemoList(userID, page, limit, keyword)
+	query := &models.MemoListQuery{
+		Page:      page,
+		Limit:     limit,
+		Keyword:   c.Query("keyword"),
+		Sort:      c.Query("sort"),
+		Order:     c.Query("order"),
+		Tag:       c.Query("tag"),
+		UseCursor: useCursor,
+		Cursor:    cursor,
+	}
+	if folder, ok := c.GetQuery("folder"); ok {
+		query.Folder = &folder
+	}
+	// fields=title,excerpt 只返回指定字段，fields=all 返回完整备忘录，默认返回摘要字段集
+	if fields := c.Query("fields"); fields != "" {
+		for _, name := range strings.Split(fields, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				query.Fields = append(query.Fields, name)
+			}
+		}
+	}
+
+	// 状态筛选，默认隐藏已归档的备忘录，archived=all 时不筛选
+	var err error
+	if query.Pinned, err = parseFlagQuery(c, "pinned"); err != nil {
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse(err.Error()))
+		return
+	}
+	if query.Favorite, err = parseFlagQuery(c, "favorite"); err != nil {
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse(err.Error()))
+		return
+	}
+	if c.Query("archived") != "all" {
+		if query.Archived, err = parseFlagQuery(c, "archived"); err != nil {
+			c.JSON(http.StatusBadRequest, models.BadRequestResponse(err.Error()))
+			return
+		}
+		if query.Archived == nil {
+			notArchived := false
+			query.Archived = &notArchived
+		}
+	}
+
+	memoList, err := ctrl.memoService.GetMemoList(userID, query)
 	if err != nil {
+		if isMemoQueryError(err) {
+			c.JSON(http.StatusBadRequest, models.BadRequestResponse(err.Error()))
+			return
+		}
 		c.JSON(http.StatusInternalServerError, models.InternalServerErrorResponse(err.Error()))
 		return
 	}
@@ -91,15 +155,111 @@ func (ctrl *MemoController) GetMemoByID(c *gin.Context) {
 		return
 	}
 
+	// format=html 时额外返回渲染后的HTML
+	switch c.DefaultQuery("format", "markdown") {
+	case "markdown":
+	case "html":
+		memo, err := ctrl.memoService.GetMemoHTML(userID, memoID)
+		if err != nil {
+			respondMemoError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, models.SuccessWithMessage("获取成功", memo))
+		return
+	default:
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse("无效的格式"))
+		return
+	}
+
 	memo, err := ctrl.memoService.GetMemoByID(userID, memoID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, models.NotFoundResponse(err.Error()))
+		respondMemoError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, models.SuccessWithMessage("获取成功", memo))
 }
 
+// 生成备忘录的AI摘要，按次消耗算力
+func (ctrl *MemoController) SummarizeMemo(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.UnauthorizedResponse("用户未认证"))
+		return
+	}
+
+	memoID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse("无效的备忘录ID"))
+		return
+	}
+
+	result, err := ctrl.memoService.SummarizeMemo(userID, memoID, c.Query("force") == "true")
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "算力余额不足"):
+			respondInsufficientBalance(c, ctrl.currencyService, userID, config.AppConfig.AISummaryCost)
+		case err.Error() == "备忘录内容为空":
+			c.JSON(http.StatusBadRequest, models.BadRequestResponse(err.Error()))
+		case err.Error() == "生成摘要失败":
+			c.JSON(http.StatusBadGateway, models.ErrorResponseWithCode(http.StatusBadGateway, err.Error()))
+		case err.Error() == "摘要正在生成中，请稍后再试":
+			c.JSON(http.StatusConflict, models.ErrorResponseWithCode(http.StatusConflict, err.Error()))
+		default:
+			respondMemoError(c, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessWithMessage("生成成功", result))
+}
+
+// 获取链接到该备忘录的备忘录（反向链接）
+func (ctrl *MemoController) GetBacklinks(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.UnauthorizedResponse("用户未认证"))
+		return
+	}
+
+	memoID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse("无效的备忘录ID"))
+		return
+	}
+
+	backlinks, err := ctrl.memoService.GetBacklinks(userID, memoID)
+	if err != nil {
+		respondMemoError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessWithMessage("获取成功", backlinks))
+}
+
+// 获取备忘录正文中的链接
+func (ctrl *MemoController) GetOutgoingLinks(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.UnauthorizedResponse("用户未认证"))
+		return
+	}
+
+	memoID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse("无效的备忘录ID"))
+		return
+	}
+
+	links, err := ctrl.memoService.GetOutgoingLinks(userID, memoID)
+	if err != nil {
+		respondMemoError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessWithMessage("获取成功", links))
+}
+
 // 更新备忘录
 func (ctrl *MemoController) UpdateMemo(c *gin.Context) {
 	userID, exists := middleware.GetUserID(c)
@@ -124,7 +284,55 @@ func (ctrl *MemoController) UpdateMemo(c *gin.Context) {
 
 	memo, err := ctrl.memoService.UpdateMemo(userID, memoID, &req)
 	if err != nil {
-		c.JSON(http.StatusNotFound, models.NotFoundResponse(err.Error()))
+		respondMemoError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessWithMessage("更新成功", memo))
+}
+
+// 部分更新备忘录，请求体为 JSON Merge Patch（RFC 7396），只修改出现的字段
+func (ctrl *MemoController) PatchMemo(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.UnauthorizedResponse("用户未认证"))
+		return
+	}
+
+	memoID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse("无效的备忘录ID"))
+		return
+	}
+
+	switch c.ContentType() {
+	case "application/merge-patch+json", "application/json":
+	default:
+		c.JSON(http.StatusUnsupportedMediaType, models.ErrorResponseWithCode(http.StatusUnsupportedMediaType, "仅支持 application/merge-patch+json"))
+		return
+	}
+
+	body, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse("读取请求体失败"))
+		return
+	}
+	patch, err := services.ParseMemoPatch(body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse(err.Error()))
+		return
+	}
+
+	memo, err := ctrl.memoService.PatchMemo(userID, memoID, patch)
+	if err != nil {
+		switch {
+		case err.Error() == "该备忘录不是清单", strings.HasPrefix(err.Error(), "提醒设置无效"):
+			c.JSON(http.StatusBadRequest, models.BadRequestResponse(err.Error()))
+		case err.Error() == "备忘录已被修改", err.Error() == "备忘录正在被其他人修改，请重试":
+			c.JSON(http.StatusConflict, models.ErrorResponseWithCode(http.StatusConflict, err.Error()))
+		default:
+			respondMemoError(c, err)
+		}
 		return
 	}
 
@@ -147,11 +355,282 @@ func (ctrl *MemoController) DeleteMemo(c *gin.Context) {
 		return
 	}
 
-	err = ctrl.memoService.DeleteMemo(userID, memoID)
+	// permanent=true 时彻底删除备忘录及其附件，否则仅移入回收站
+	if c.Query("permanent") == "true" {
+		err = ctrl.memoService.PurgeMemo(userID, memoID)
+	} else {
+		err = ctrl.memoService.DeleteMemo(userID, memoID)
+	}
 	if err != nil {
-		c.JSON(http.StatusNotFound, models.NotFoundResponse(err.Error()))
+		respondMemoError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, models.SuccessWithMessage("删除成功", nil))
-}
\ No newline at end of file
+}
+// 获取备忘录增量变更
+func (ctrl *MemoController) GetMemoChanges(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.UnauthorizedResponse("用户未认证"))
+		return
+	}
+
+	since := c.Query("since")
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+
+	changes, err := ctrl.memoService.GetMemoChanges(userID, since, limit)
+	if err != nil {
+		if err.Error() == "无效的同步游标" {
+			c.JSON(http.StatusBadRequest, models.BadRequestResponse(err.Error()))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.InternalServerErrorResponse(err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessWithMessage("获取成功", changes))
+}
+
+// 批量推送离线变更
+func (ctrl *MemoController) SyncMemos(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.UnauthorizedResponse("用户未认证"))
+		return
+	}
+
+	var req models.SyncPushRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse(err.Error()))
+		return
+	}
+
+	result, err := ctrl.memoService.ApplySyncChanges(userID, req.Changes)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.InternalServerErrorResponse(err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessWithMessage("同步完成", result))
+}
+
+// 全文搜索备忘录
+func (ctrl *MemoController) SearchMemos(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.UnauthorizedResponse("用户未认证"))
+		return
+	}
+
+	q := c.Query("q")
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+
+	// 参数验证
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	result, err := ctrl.memoService.SearchMemos(userID, q, page, limit)
+	if err != nil {
+		if err.Error() == "搜索关键词不能为空" {
+			c.JSON(http.StatusBadRequest, models.BadRequestResponse(err.Error()))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.InternalServerErrorResponse(err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessWithMessage("搜索成功", result))
+}
+
+// 按语义相似度搜索备忘录
+func (ctrl *MemoController) SemanticSearchMemos(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.UnauthorizedResponse("用户未认证"))
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if limit < 1 || limit > 50 {
+		limit = 10
+	}
+
+	result, err := ctrl.memoService.SemanticSearch(userID, c.Query("q"), limit)
+	if err != nil {
+		switch err.Error() {
+		case "搜索关键词不能为空":
+			c.JSON(http.StatusBadRequest, models.BadRequestResponse(err.Error()))
+		case "生成查询向量失败":
+			c.JSON(http.StatusBadGateway, models.ErrorResponseWithCode(http.StatusBadGateway, err.Error()))
+		default:
+			c.JSON(http.StatusInternalServerError, models.InternalServerErrorResponse(err.Error()))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessWithMessage("搜索成功", result))
+}
+
+// 置顶备忘录
+func (ctrl *MemoController) PinMemo(c *gin.Context) {
+	ctrl.setMemoFlag(c, "pinned", true, "置顶成功")
+}
+
+// 取消置顶备忘录
+func (ctrl *MemoController) UnpinMemo(c *gin.Context) {
+	ctrl.setMemoFlag(c, "pinned", false, "取消置顶成功")
+}
+
+// 归档备忘录
+func (ctrl *MemoController) ArchiveMemo(c *gin.Context) {
+	ctrl.setMemoFlag(c, "archived", true, "归档成功")
+}
+
+// 取消归档备忘录
+func (ctrl *MemoController) UnarchiveMemo(c *gin.Context) {
+	ctrl.setMemoFlag(c, "archived", false, "取消归档成功")
+}
+
+// 收藏备忘录
+func (ctrl *MemoController) FavoriteMemo(c *gin.Context) {
+	ctrl.setMemoFlag(c, "favorite", true, "收藏成功")
+}
+
+// 取消收藏备忘录
+func (ctrl *MemoController) UnfavoriteMemo(c *gin.Context) {
+	ctrl.setMemoFlag(c, "favorite", false, "取消收藏成功")
+}
+
+func (ctrl *MemoController) setMemoFlag(c *gin.Context, flag string, value bool, message string) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.UnauthorizedResponse("用户未认证"))
+		return
+	}
+
+	// 获取备忘录ID
+	memoIDStr := c.Param("id")
+	memoID, err := primitive.ObjectIDFromHex(memoIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse("无效的备忘录ID"))
+		return
+	}
+
+	memo, err := ctrl.memoService.SetMemoFlag(userID, memoID, flag, value)
+	if err != nil {
+		respondMemoError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessWithMessage(message, memo))
+}
+
+// 解析布尔类型的筛选参数，参数缺失时返回 nil
+func parseFlagQuery(c *gin.Context, name string) (*bool, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return nil, nil
+	}
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		return nil, errors.New("无效的筛选参数: " + name)
+	}
+	return &value, nil
+}
+
+// 批量操作备忘录
+func (ctrl *MemoController) BulkMemos(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.UnauthorizedResponse("用户未认证"))
+		return
+	}
+
+	var req models.BulkMemoRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse(err.Error()))
+		return
+	}
+
+	result, err := ctrl.memoService.BulkMemos(userID, &req)
+	if err != nil {
+		switch err.Error() {
+		case "无效的备忘录ID", "匹配的备忘录过多，请缩小筛选范围", "请指定标签", "ids 和 filter 只能指定一个", "请指定备忘录ID或筛选条件",
+			"筛选条件为空，操作全部备忘录需指定 all 为 true":
+			c.JSON(http.StatusBadRequest, models.BadRequestResponse(err.Error()))
+		default:
+			c.JSON(http.StatusInternalServerError, models.InternalServerErrorResponse(err.Error()))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessWithMessage("操作完成", result))
+}
+
+// 获取共享给我的备忘录
+func (ctrl *MemoController) GetSharedWithMe(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.UnauthorizedResponse("用户未认证"))
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+
+	// 参数验证
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	memoList, err := ctrl.memoService.GetSharedWithMe(userID, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.InternalServerErrorResponse(err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessWithMessage("获取成功", memoList))
+}
+
+// 根据服务层错误返回对应的状态码
+func respondMemoError(c *gin.Context, err error) {
+	switch {
+	case err.Error() == "没有操作权限":
+		c.JSON(http.StatusForbidden, models.ForbiddenResponse(err.Error()))
+	case err.Error() == "备忘录不存在":
+		c.JSON(http.StatusNotFound, models.NotFoundResponse(err.Error()))
+	case err.Error() == "备忘录正在被其他人修改，请重试":
+		c.JSON(http.StatusConflict, models.ErrorResponseWithCode(http.StatusConflict, err.Error()))
+	case isEncryptionError(err):
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse(err.Error()))
+	default:
+		c.JSON(http.StatusInternalServerError, models.InternalServerErrorResponse(err.Error()))
+	}
+}
+
+// 判断是否为加密备忘录的请求错误
+func isEncryptionError(err error) bool {
+	switch err.Error() {
+	case "加密备忘录不能包含明文内容", "加密备忘录需要提交密文", "加密备忘录不支持该操作", "解除加密时需要提供标题", "加密密钥不存在":
+		return true
+	}
+	return strings.HasPrefix(err.Error(), "密文格式无效")
+}
+
+// 判断是否为查询参数错误
+func isMemoQueryError(err error) bool {
+	switch err.Error() {
+	case "无效的排序方式", "无效的排序方向", "无效的分页游标", "分页游标与排序方式不匹配":
+		return true
+	}
+	return strings.HasPrefix(err.Error(), "无效的返回字段")
+}
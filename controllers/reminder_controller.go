@@ -0,0 +1,80 @@
+package controllers
+
+import (
+	"net/http"
+
+	"mjbackend/middleware"
+	"mjbackend/models"
+	"mjbackend/services"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ReminderController struct {
+	reminderService *services.ReminderService
+}
+
+func NewReminderController() *ReminderController {
+	return &ReminderController{
+		reminderService: services.NewReminderService(),
+	}
+}
+
+// 设置备忘录的截止时间和提醒
+func (ctrl *ReminderController) SetReminder(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.UnauthorizedResponse("用户未认证"))
+		return
+	}
+
+	memoID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse("无效的备忘录ID"))
+		return
+	}
+
+	var req models.SetReminderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse(err.Error()))
+		return
+	}
+
+	memo, err := ctrl.reminderService.SetReminder(userID, memoID, &req)
+	if err != nil {
+		switch err.Error() {
+		case "没有操作权限", "备忘录不存在", "备忘录正在被其他人修改，请重试":
+			respondMemoError(c, err)
+		default:
+			// 其余错误均为提醒时间、重复规则或时区无效
+			c.JSON(http.StatusBadRequest, models.BadRequestResponse(err.Error()))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessWithMessage("设置成功", memo))
+}
+
+// 清除备忘录的截止时间和提醒
+func (ctrl *ReminderController) ClearReminder(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.UnauthorizedResponse("用户未认证"))
+		return
+	}
+
+	memoID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse("无效的备忘录ID"))
+		return
+	}
+
+	memo, err := ctrl.reminderService.ClearReminder(userID, memoID)
+	if err != nil {
+		respondMemoError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessWithMessage("已清除", memo))
+}
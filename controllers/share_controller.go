@@ -0,0 +1,137 @@
+package controllers
+
+import (
+	"net/http"
+
+	"mjbackend/middleware"
+	"mjbackend/models"
+	"mjbackend/services"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ShareController struct {
+	shareService *services.ShareService
+	memoService  *services.MemoService
+}
+
+func NewShareController() *ShareController {
+	return &ShareController{
+		shareService: services.NewShareService(),
+		memoService:  services.NewMemoService(),
+	}
+}
+
+// 创建分享链接
+func (ctrl *ShareController) CreateShare(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.UnauthorizedResponse("用户未认证"))
+		return
+	}
+
+	memoID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse("无效的备忘录ID"))
+		return
+	}
+
+	var req models.CreateShareRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse(err.Error()))
+		return
+	}
+
+	share, err := ctrl.shareService.CreateShare(userID, memoID, &req)
+	if err != nil {
+		respondShareError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessWithMessage("创建成功", share))
+}
+
+// 获取备忘录的分享链接列表
+func (ctrl *ShareController) ListShares(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.UnauthorizedResponse("用户未认证"))
+		return
+	}
+
+	memoID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse("无效的备忘录ID"))
+		return
+	}
+
+	shares, err := ctrl.shareService.ListShares(userID, memoID)
+	if err != nil {
+		respondShareError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessWithMessage("获取成功", shares))
+}
+
+// 撤销分享链接
+func (ctrl *ShareController) RevokeShare(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.UnauthorizedResponse("用户未认证"))
+		return
+	}
+
+	memoID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse("无效的备忘录ID"))
+		return
+	}
+	shareID, err := primitive.ObjectIDFromHex(c.Param("shareId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse("无效的分享ID"))
+		return
+	}
+
+	if err := ctrl.shareService.RevokeShare(userID, memoID, shareID); err != nil {
+		respondShareError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessWithMessage("撤销成功", nil))
+}
+
+// 根据分享管理接口的服务层错误返回对应的状态码
+func respondShareError(c *gin.Context, err error) {
+	switch err.Error() {
+	case "没有操作权限":
+		c.JSON(http.StatusForbidden, models.ForbiddenResponse(err.Error()))
+	case "备忘录不存在", "分享链接不存在":
+		c.JSON(http.StatusNotFound, models.NotFoundResponse(err.Error()))
+	default:
+		c.JSON(http.StatusInternalServerError, models.InternalServerErrorResponse(err.Error()))
+	}
+}
+
+// 通过分享链接查看备忘录（无需登录），访问密码通过 X-Share-Password 请求头传递
+func (ctrl *ShareController) ViewSharedMemo(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+
+	memo, err := ctrl.memoService.GetSharedMemo(c.Param("token"), c.GetHeader("X-Share-Password"))
+	if err != nil {
+		switch err.Error() {
+		case "分享链接不存在":
+			c.JSON(http.StatusNotFound, models.NotFoundResponse(err.Error()))
+		case "需要访问密码":
+			c.JSON(http.StatusUnauthorized, models.UnauthorizedResponse(err.Error()))
+		case "访问密码错误":
+			c.JSON(http.StatusForbidden, models.ForbiddenResponse(err.Error()))
+		default:
+			c.JSON(http.StatusInternalServerError, models.InternalServerErrorResponse(err.Error()))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessWithMessage("获取成功", memo))
+}
@@ -0,0 +1,150 @@
+package controllers
+
+import (
+	"net/http"
+
+	"mjbackend/middleware"
+	"mjbackend/models"
+	"mjbackend/services"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type TemplateController struct {
+	templateService *services.TemplateService
+}
+
+func NewTemplateController() *TemplateController {
+	return &TemplateController{
+		templateService: services.NewTemplateService(),
+	}
+}
+
+// 获取模板列表（内置模板和自己创建的模板）
+func (ctrl *TemplateController) ListTemplates(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.UnauthorizedResponse("用户未认证"))
+		return
+	}
+
+	templates, err := ctrl.templateService.ListTemplates(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.InternalServerErrorResponse(err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessWithMessage("获取成功", templates))
+}
+
+// 获取模板详情
+func (ctrl *TemplateController) GetTemplate(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.UnauthorizedResponse("用户未认证"))
+		return
+	}
+
+	templateID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse("无效的模板ID"))
+		return
+	}
+
+	template, err := ctrl.templateService.GetTemplate(userID, templateID)
+	if err != nil {
+		respondTemplateError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessWithMessage("获取成功", template))
+}
+
+// 创建模板
+func (ctrl *TemplateController) CreateTemplate(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.UnauthorizedResponse("用户未认证"))
+		return
+	}
+
+	var req models.TemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse(err.Error()))
+		return
+	}
+
+	template, err := ctrl.templateService.CreateTemplate(userID, &req)
+	if err != nil {
+		respondTemplateError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessWithMessage("创建成功", template))
+}
+
+// 更新模板
+func (ctrl *TemplateController) UpdateTemplate(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.UnauthorizedResponse("用户未认证"))
+		return
+	}
+
+	templateID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse("无效的模板ID"))
+		return
+	}
+
+	var req models.TemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse(err.Error()))
+		return
+	}
+
+	template, err := ctrl.templateService.UpdateTemplate(userID, templateID, &req)
+	if err != nil {
+		respondTemplateError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessWithMessage("更新成功", template))
+}
+
+// 删除模板
+func (ctrl *TemplateController) DeleteTemplate(c *gin.Context) {
+	userID, exists := middleware.GetUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.UnauthorizedResponse("用户未认证"))
+		return
+	}
+
+	templateID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse("无效的模板ID"))
+		return
+	}
+
+	if err := ctrl.templateService.DeleteTemplate(userID, templateID); err != nil {
+		respondTemplateError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessWithMessage("删除成功", nil))
+}
+
+// 根据服务层错误返回对应的状态码
+func respondTemplateError(c *gin.Context, err error) {
+	switch err.Error() {
+	case "模板不存在":
+		c.JSON(http.StatusNotFound, models.NotFoundResponse(err.Error()))
+	case "内置模板不能修改":
+		c.JSON(http.StatusForbidden, models.ForbiddenResponse(err.Error()))
+	case "模板数量超过上限", "只有清单备忘录可以包含条目":
+		c.JSON(http.StatusBadRequest, models.BadRequestResponse(err.Error()))
+	default:
+		c.JSON(http.StatusInternalServerError, models.InternalServerErrorResponse(err.Error()))
+	}
+}
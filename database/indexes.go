@@ -0,0 +1,73 @@
+package database
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// requiredIndexes 正确性依赖的索引（唯一约束等），启动时确保存在，不依赖部署时是否执行过 init-mongo.js。
+// 键和选项与 init-mongo.js 保持一致，已存在时创建为空操作
+var requiredIndexes = map[string][]mongo.IndexModel{
+	"memos": {
+		// 离线创建按客户端ID去重，重试同步不会产生重复备忘录
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "client_id", Value: 1}},
+			Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"client_id": bson.M{"$type": "string"}}),
+		},
+		// 导入按去重键跳过重复内容，唯一约束保证并发导入同一文件时只写入一次。
+		// 与旧的非唯一索引名称不同，已有部署无需先删除旧索引
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "import_key", Value: 1}},
+			Options: options.Index().SetName("import_key_unique").SetUnique(true).
+				SetPartialFilterExpression(bson.M{"import_key": bson.M{"$type": "string"}}),
+		},
+		// 全文搜索依赖的文本索引。集合只能有一个文本索引，旧部署需先按 init-mongo.js 的说明删除旧索引
+		{
+			Keys: bson.D{{Key: "search_title", Value: "text"}, {Key: "search_text", Value: "text"}, {Key: "search_items", Value: "text"}},
+			Options: options.Index().SetName("memo_search").
+				SetWeights(bson.D{{Key: "search_title", Value: 5}, {Key: "search_text", Value: 1}, {Key: "search_items", Value: 1}}).
+				SetDefaultLanguage("none"),
+		},
+	},
+	// 分享链接按令牌查找，令牌必须唯一
+	"memo_shares": {
+		{Keys: bson.D{{Key: "token", Value: 1}}, Options: options.Index().SetUnique(true)},
+	},
+	// 同一用户在一个备忘录中只有一条协作记录，并发邀请不会产生重复角色
+	"memo_members": {
+		{Keys: bson.D{{Key: "memo_id", Value: 1}, {Key: "user_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+	},
+	// 提醒通知按去重键保证只生成一条
+	"notifications": {
+		{Keys: bson.D{{Key: "dedupe_key", Value: 1}}, Options: options.Index().SetUnique(true).SetSparse(true)},
+	},
+	// 补满的限流桶由 TTL 索引删除
+	"rate_limits": {
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	},
+	// 登录失败计数过期后删除，登录记录保留 90 天
+	"login_failures": {
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	},
+	"login_attempts": {
+		{Keys: bson.D{{Key: "created_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(7776000)},
+	},
+}
+
+// EnsureIndexes 创建缺少的必需索引，失败时终止启动
+func EnsureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	for collection, models := range requiredIndexes {
+		if _, err := GetCollection(collection).Indexes().CreateMany(ctx, models); err != nil {
+			log.Fatalf("创建 %s 集合索引失败: %v", collection, err)
+		}
+	}
+}
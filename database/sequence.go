@@ -0,0 +1,94 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type counter struct {
+	ID  string `bson:"_id"`
+	Seq int64  `bson:"seq"`
+}
+
+// NextSequence 原子地为指定计数器分配 n 个连续序号，返回分配到的最大序号
+func NextSequence(ctx context.Context, name string, n int64) (int64, error) {
+	var c counter
+	err := GetCollection("counters").FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": name},
+		bson.M{"$inc": bson.M{"seq": n}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&c)
+	if err != nil {
+		return 0, err
+	}
+	return c.Seq, nil
+}
+
+// CurrentSequence 读取计数器当前值，计数器不存在时返回0
+func CurrentSequence(ctx context.Context, name string) (int64, error) {
+	var c counter
+	err := GetCollection("counters").FindOne(ctx, bson.M{"_id": name}).Decode(&c)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return c.Seq, nil
+}
+
+// sequenceSample 某一时刻读取到的计数器值
+type sequenceSample struct {
+	Seq int64     `bson:"seq"`
+	At  time.Time `bson:"at"`
+}
+
+// 每个计数器保留的采样数，多实例同时采样时也能覆盖足够长的时间
+const maxSequenceSamples = 500
+
+// SampleSequence 记录计数器当前值及读取时间，供 SettledSequence 计算已稳定的序号
+func SampleSequence(ctx context.Context, name string) error {
+	seq, err := CurrentSequence(ctx, name)
+	if err != nil {
+		return err
+	}
+	_, err = GetCollection("counters").UpdateOne(ctx,
+		bson.M{"_id": name + "_samples"},
+		bson.M{"$push": bson.M{"samples": bson.M{
+			"$each":  bson.A{sequenceSample{Seq: seq, At: time.Now()}},
+			"$slice": -maxSequenceSamples,
+		}}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+// SettledSequence 返回 settle 之前采样到的最大计数器值。序号先分配再写入，
+// 分配后超过 settle 的写入都已提交或已放弃，因此不超过该值的序号不会再出现新的写入。
+// 还没有足够早的采样时返回 0
+func SettledSequence(ctx context.Context, name string, settle time.Duration) (int64, error) {
+	var doc struct {
+		Samples []sequenceSample `bson:"samples"`
+	}
+	err := GetCollection("counters").FindOne(ctx, bson.M{"_id": name + "_samples"}).Decode(&doc)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	deadline := time.Now().Add(-settle)
+	var settled int64
+	for _, sample := range doc.Samples {
+		if !sample.At.After(deadline) && sample.Seq > settled {
+			settled = sample.Seq
+		}
+	}
+	return settled, nil
+}
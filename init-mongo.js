@@ -27,12 +27,105 @@ db.createCollection('currency_balances');
 // 创建算力交易记录集合
 db.createCollection('currency_transactions');
 
+// 创建算力预扣集合
+db.createCollection('currency_holds');
+
+// 创建备忘录向量集合（语义搜索）
+db.createCollection('memo_embeddings');
+
+// 创建后台AI任务集合
+db.createCollection('ai_jobs');
+
+// 创建限流令牌桶集合（RATE_LIMIT_STORE=mongo 时使用）
+db.createCollection('rate_limits');
+
+// 创建登录失败计数和登录记录集合
+db.createCollection('login_failures');
+db.createCollection('login_attempts');
+
+// 创建附件元数据集合（文件内容存储在 GridFS 的 attachments 桶中）
+db.createCollection('attachments');
+
+// 创建备忘录分享链接集合
+db.createCollection('memo_shares');
+
+// 创建备忘录协作成员集合
+db.createCollection('memo_members');
+
+// 创建站内通知集合
+db.createCollection('notifications');
+
+// 创建备忘录链接索引集合
+db.createCollection('memo_links');
+
+// 创建备忘录模板集合（内置模板定义在代码中）
+db.createCollection('memo_templates');
+
+// 创建加密密钥材料集合（只保存客户端包裹后的密钥）
+db.createCollection('user_keys');
+
+// 创建导出任务集合（导出文件存储在 GridFS 的 exports 桶中）
+db.createCollection('export_jobs');
+
+// 创建调度租约集合（多实例部署时选出执行定时任务的实例）
+db.createCollection('leases');
+
 // 为用户名创建唯一索引
 db.users.createIndex({ "username": 1 }, { unique: true });
 
 // 为备忘录创建索引
 db.memos.createIndex({ "user_id": 1 });
 db.memos.createIndex({ "created_at": -1 });
+// 列表默认置顶优先排序
+db.memos.createIndex({ "user_id": 1, "pinned": -1, "created_at": -1, "_id": -1 });
+// 增量同步：按用户和同步序号查询变更，按客户端ID去重离线创建
+db.memos.createIndex({ "user_id": 1, "sync_seq": 1 });
+db.memos.createIndex({ "user_id": 1, "client_id": 1 }, { unique: true, partialFilterExpression: { "client_id": { $type: "string" } } });
+
+// 按标签和文件夹筛选
+db.memos.createIndex({ "user_id": 1, "tags": 1 });
+db.memos.createIndex({ "user_id": 1, "folder": 1 });
+// 按归一化标题解析备忘录链接
+db.memos.createIndex({ "user_id": 1, "title_lower": 1 });
+// 导入时按去重键识别重复内容，唯一约束防止并发导入重复写入
+// 已有部署的旧非唯一索引可以删除：db.memos.dropIndex("user_id_1_import_key_1")
+db.memos.createIndex({ "user_id": 1, "import_key": 1 }, { name: "import_key_unique", unique: true, partialFilterExpression: { "import_key": { $type: "string" } } });
+// 删除密钥时查找仍使用该密钥的加密备忘录
+db.memos.createIndex({ "user_id": 1, "encrypted.key_id": 1 }, { partialFilterExpression: { "encrypted": { $exists: true } } });
+// 提醒调度器按下一次提醒时间扫描
+db.memos.createIndex({ "reminder.next_at": 1 }, { partialFilterExpression: { "reminder.next_at": { $exists: true } } });
+
+// 为附件创建索引
+db.attachments.createIndex({ "memo_id": 1, "created_at": 1 });
+db.attachments.createIndex({ "user_id": 1 });
+
+// 为分享链接创建索引
+db.memo_shares.createIndex({ "token": 1 }, { unique: true });
+db.memo_shares.createIndex({ "memo_id": 1, "created_at": -1 });
+
+// 为协作成员创建索引
+db.memo_members.createIndex({ "memo_id": 1, "user_id": 1 }, { unique: true });
+db.memo_members.createIndex({ "user_id": 1, "status": 1 });
+
+// 为站内通知创建索引，dedupe_key 保证同一提醒只生成一条通知
+db.notifications.createIndex({ "user_id": 1, "created_at": -1 });
+db.notifications.createIndex({ "user_id": 1, "read_at": 1 });
+db.notifications.createIndex({ "dedupe_key": 1 }, { unique: true, sparse: true });
+
+// 为备忘录链接创建索引
+db.memo_links.createIndex({ "source_id": 1 });
+db.memo_links.createIndex({ "target_id": 1 });
+db.memo_links.createIndex({ "user_id": 1, "ref": 1 });
+
+// 为备忘录模板创建索引
+db.memo_templates.createIndex({ "user_id": 1, "created_at": -1 });
+
+// 为加密密钥材料创建唯一索引
+db.user_keys.createIndex({ "user_id": 1, "key_id": 1 }, { unique: true });
+
+// 为导出任务创建索引
+db.export_jobs.createIndex({ "user_id": 1, "created_at": -1 });
+db.export_jobs.createIndex({ "status": 1, "expires_at": 1 });
 
 // 为算力余额创建索引
 db.currency_balances.createIndex({ "user_id": 1 }, { unique: true });
@@ -44,7 +137,39 @@ db.currency_transactions.createIndex({ "created_at": -1 });
 db.currency_transactions.createIndex({ "transaction_id": 1 }, { unique: true });
 db.currency_transactions.createIndex({ "type": 1 });
 
-// 为备忘录标题和内容创建文本搜索索引（可选，用于优化搜索性能）
-db.memos.createIndex({ "title": "text", "content": "text" });
+// 为算力预扣创建索引，用于查找超时未确认的预扣
+db.currency_holds.createIndex({ "status": 1, "expires_at": 1 });
+
+// 为备忘录向量创建索引，用于进程内比较时加载用户的向量
+// 使用 MongoDB Atlas 时可另建向量索引并配置 VECTOR_SEARCH_INDEX，例如：
+// { "fields": [ { "type": "vector", "path": "vector", "numDimensions": 1536, "similarity": "cosine" },
+//               { "type": "filter", "path": "user_id" }, { "type": "filter", "path": "model" } ] }
+db.memo_embeddings.createIndex({ "user_id": 1, "model": 1, "updated_at": -1 });
+
+// 为后台AI任务创建索引：按用户查询任务列表，工作协程按状态租用到期任务或租约过期的任务
+db.ai_jobs.createIndex({ "user_id": 1, "created_at": -1 });
+db.ai_jobs.createIndex({ "user_id": 1, "memo_id": 1, "created_at": -1 });
+db.ai_jobs.createIndex({ "status": 1, "next_run_at": 1 });
+db.ai_jobs.createIndex({ "status": 1, "lease_expires_at": 1 });
+
+// 令牌桶补满后不再需要，由 TTL 索引自动删除
+db.rate_limits.createIndex({ "expires_at": 1 }, { expireAfterSeconds: 0 });
+
+// 登录失败计数在最后一次失败或锁定结束一段时间后自动删除；管理员按锁定时间查看被锁定的账号和IP
+db.login_failures.createIndex({ "expires_at": 1 }, { expireAfterSeconds: 0 });
+db.login_failures.createIndex({ "locked_until": -1 });
+
+// 为登录记录创建索引：按用户查看最近的登录记录，判断是否来自新的IP，记录保留 90 天
+db.login_attempts.createIndex({ "user_id": 1, "created_at": -1 });
+db.login_attempts.createIndex({ "user_id": 1, "result": 1, "ip": 1 });
+db.login_attempts.createIndex({ "created_at": 1 }, { expireAfterSeconds: 7776000 });
+
+// 为备忘录全文搜索创建文本索引
+// search_title/search_text/search_items 为服务端分析器切分后的词元（中文按二元组切分），关闭语言处理以免被按英文词干化
+// 已有部署需先删除旧索引：db.memos.dropIndex("title_text_content_text")；升级清单功能时需删除并重建：db.memos.dropIndex("memo_search")
+db.memos.createIndex(
+  { "search_title": "text", "search_text": "text", "search_items": "text" },
+  { name: "memo_search", weights: { "search_title": 5, "search_text": 1, "search_items": 1 }, default_language: "none" }
+);
 
 print('Database initialized successfully!');
\ No newline at end of file
@@ -5,7 +5,9 @@ import (
 
 	"mjbackend/config"
 	"mjbackend/database"
+	"mjbackend/middleware"
 	"mjbackend/routes"
+	"mjbackend/services"
 
 	"github.com/gin-gonic/gin"
 )
@@ -20,8 +22,47 @@ func main() {
 	// 连接数据库
 	database.ConnectMongoDB()
 
-	// 创建Gin引擎
-	r := gin.Default()
+	// 创建必需的索引
+	database.EnsureIndexes()
+
+	// 启动实时事件监听
+	services.StartEventWatcher()
+
+	// 采样同步序号，用于计算增量同步游标
+	services.StartSyncSampler()
+
+	// 启动提醒调度器
+	services.StartReminderScheduler()
+
+	// 定期清理过期的导出文件
+	services.StartExportCleanup()
+
+	// 启动后台导出任务执行协程
+	services.StartExportWorker()
+
+	// 退回超时未确认的算力预扣
+	services.StartHoldExpiry()
+
+	// 启动后台AI任务工作协程
+	services.StartAIJobWorkers()
+
+	// 为历史备忘录补建全文索引字段
+	go services.NewMemoService().BackfillSearchIndex()
+
+	// 首次启用链接索引时为已有备忘录建立索引
+	go services.NewMemoService().BackfillMemoLinks()
+
+	// 启动语义搜索向量的更新任务
+	services.StartEmbeddingIndexer()
+
+	// 创建Gin引擎，请求日志隐藏查询参数中的令牌
+	r := gin.New()
+	r.Use(middleware.Logger(), gin.Recovery())
+
+	// 只信任配置的反向代理传递的客户端IP，未配置时直接使用连接地址，防止伪造 X-Forwarded-For 绕过限流
+	if err := r.SetTrustedProxies(config.AppConfig.TrustedProxies); err != nil {
+		log.Fatal("可信代理配置无效:", err)
+	}
 
 	// 设置路由
 	routes.SetupRoutes(r)
@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"net/http"
+
+	"mjbackend/config"
+	"mjbackend/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 管理员权限中间件，需在 AuthMiddleware 之后使用，管理员由 ADMIN_USER_IDS 配置
+func AdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if userID, exists := GetUserID(c); exists {
+			for _, admin := range config.AppConfig.AdminUserIDs {
+				if userID.Hex() == admin {
+					c.Next()
+					return
+				}
+			}
+		}
+		c.JSON(http.StatusForbidden, models.ForbiddenResponse("需要管理员权限"))
+		c.Abort()
+	}
+}
@@ -61,4 +61,16 @@ func GetUsername(c *gin.Context) (string, bool) {
 		return "", false
 	}
 	return username.(string), true
-}
\ No newline at end of file
+}
+
+// 从查询参数读取token，供无法设置请求头的EventSource等客户端使用
+func QueryTokenMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" {
+			if token := c.Query("access_token"); token != "" {
+				c.Request.Header.Set("Authorization", "Bearer "+token)
+			}
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"mjbackend/config"
+	"mjbackend/models"
+	"mjbackend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimit 按名称对应的令牌桶策略限流，超出时返回 429 和 Retry-After 响应头。
+// 策略未配置或为 0 时不限流；限流存储不可用时放行请求，只记录日志
+func RateLimit(limiter services.RateLimiter, name string) gin.HandlerFunc {
+	policy := config.AppConfig.RateLimits[name]
+	if policy.PerMinute <= 0 {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	return func(c *gin.Context) {
+		// 已认证的请求按用户计数，其余按客户端IP计数
+		key := name + ":ip:" + c.ClientIP()
+		if userID, exists := GetUserID(c); exists {
+			key = name + ":user:" + userID.Hex()
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Second)
+		allowed, wait, err := limiter.Allow(ctx, key, policy)
+		cancel()
+		if err != nil {
+			log.Printf("限流检查失败，放行请求: %v", err)
+			c.Next()
+			return
+		}
+		if !allowed {
+			abortRateLimited(c, wait, "请求过于频繁，请稍后再试")
+			return
+		}
+		c.Next()
+	}
+}
+
+// RateLimitFailures 只对响应状态码为 failureStatus 的请求计数，按请求路径和客户端IP分桶，
+// 桶中没有令牌时在执行处理函数前拒绝请求。用于限制猜测密码等只有失败才需要计数的接口
+func RateLimitFailures(limiter services.RateLimiter, name string, failureStatus int) gin.HandlerFunc {
+	policy := config.AppConfig.RateLimits[name]
+	if policy.PerMinute <= 0 {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	return func(c *gin.Context) {
+		key := name + ":ip:" + c.ClientIP() + ":" + c.Request.URL.Path
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Second)
+		blocked, wait, err := limiter.Blocked(ctx, key, policy)
+		cancel()
+		if err != nil {
+			log.Printf("限流检查失败，放行请求: %v", err)
+		}
+		if blocked {
+			abortRateLimited(c, wait, "尝试次数过多，请稍后再试")
+			return
+		}
+
+		c.Next()
+
+		if c.Writer.Status() != failureStatus {
+			return
+		}
+		ctx, cancel = context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		if _, _, err := limiter.Allow(ctx, key, policy); err != nil {
+			log.Printf("记录失败次数失败: %v", err)
+		}
+	}
+}
+
+// abortRateLimited 返回 429，Retry-After 响应头和 data.retryAfter 为建议等待的秒数
+func abortRateLimited(c *gin.Context, wait time.Duration, message string) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.JSON(http.StatusTooManyRequests, models.ErrorResponseWithData(http.StatusTooManyRequests, message,
+		models.RateLimitError{RetryAfter: seconds}))
+	c.Abort()
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AI任务类型
+const (
+	AIJobSummarize = "summarize"
+	// 按提示词生成图片、生成已有图片的变体、放大已有图片，结果保存为备忘录附件
+	AIJobImageGenerate  = "image_generate"
+	AIJobImageVariation = "image_variation"
+	AIJobImageUpscale   = "image_upscale"
+)
+
+// AI任务状态
+const (
+	AIJobQueued    = "queued"
+	AIJobRunning   = "running"
+	AIJobSucceeded = "succeeded"
+	AIJobFailed    = "failed"
+	AIJobCanceled  = "canceled"
+)
+
+// AIJob 后台AI任务：提交时预扣算力，由工作协程租用执行，成功后确认扣费，失败或取消时退回
+type AIJob struct {
+	ID          primitive.ObjectID     `bson:"_id,omitempty" json:"id"`
+	UserID      primitive.ObjectID     `bson:"user_id" json:"-"`
+	MemoID      primitive.ObjectID     `bson:"memo_id" json:"memoId"`
+	Type        string                 `bson:"type" json:"type"`
+	Params      map[string]interface{} `bson:"params,omitempty" json:"params,omitempty"`
+	Cost        int                    `bson:"cost" json:"cost"`
+	Status      string                 `bson:"status" json:"status"`
+	Progress    int                    `bson:"progress" json:"progress"` // 0-100
+	Attempts    int                    `bson:"attempts" json:"attempts"`
+	MaxAttempts int                    `bson:"max_attempts" json:"maxAttempts"`
+	Result      map[string]interface{} `bson:"result,omitempty" json:"result,omitempty"`
+	Error       string                 `bson:"error,omitempty" json:"error,omitempty"`
+	// 运行中的任务请求取消后由执行者中止
+	CancelRequested bool                `bson:"cancel_requested,omitempty" json:"cancelRequested,omitempty"`
+	HoldID          *primitive.ObjectID `bson:"hold_id,omitempty" json:"-"`
+	NextRunAt       time.Time           `bson:"next_run_at" json:"nextRunAt"`
+	LeaseOwner      string              `bson:"lease_owner,omitempty" json:"-"`
+	LeaseExpiresAt  *time.Time          `bson:"lease_expires_at,omitempty" json:"-"`
+	CreatedAt       time.Time           `bson:"created_at" json:"createTime"`
+	UpdatedAt       time.Time           `bson:"updated_at" json:"updateTime"`
+	StartedAt       *time.Time          `bson:"started_at,omitempty" json:"startedAt,omitempty"`
+	FinishedAt      *time.Time          `bson:"finished_at,omitempty" json:"finishedAt,omitempty"`
+}
+
+// CreateAIJobRequest 提交AI任务请求模型，费用由服务端按任务类型确定
+type CreateAIJobRequest struct {
+	Type   string                 `json:"type" binding:"required"`
+	MemoID string                 `json:"memoId" binding:"required"`
+	Params map[string]interface{} `json:"params"`
+}
+
+// AIJobListQuery AI任务列表查询参数
+type AIJobListQuery struct {
+	Status string
+	MemoID *primitive.ObjectID
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Attachment 备忘录附件元数据，文件内容保存在存储后端
+type Attachment struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID        primitive.ObjectID `bson:"user_id" json:"-"`
+	MemoID        primitive.ObjectID `bson:"memo_id" json:"memoId"`
+	Filename      string             `bson:"filename" json:"filename"`
+	ContentType   string             `bson:"content_type" json:"contentType"`
+	Size          int64              `bson:"size" json:"size"`
+	StorageKey    string             `bson:"storage_key" json:"-"`
+	ThumbnailKey  string             `bson:"thumbnail_key,omitempty" json:"-"`
+	ThumbnailType string             `bson:"thumbnail_type,omitempty" json:"-"`
+	HasThumbnail  bool               `bson:"has_thumbnail" json:"hasThumbnail"`
+	Width         int                `bson:"width,omitempty" json:"width,omitempty"`
+	Height        int                `bson:"height,omitempty" json:"height,omitempty"`
+	CreatedAt     time.Time          `bson:"created_at" json:"createTime"`
+}
+
+// AttachmentQuotaError 附件容量不足错误响应模型
+type AttachmentQuotaError struct {
+	UsedBytes     int64 `json:"usedBytes"`
+	QuotaBytes    int64 `json:"quotaBytes"`
+	RequiredBytes int64 `json:"requiredBytes"`
+}
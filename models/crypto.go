@@ -0,0 +1,69 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// 端到端加密的信封格式版本
+const EnvelopeVersion = 1
+
+// 支持的对称加密算法，二进制字段均为标准 Base64 编码
+const (
+	CipherAESGCM            = "AES-256-GCM"
+	CipherXChaCha20Poly1305 = "XChaCha20-Poly1305"
+)
+
+// 口令派生包裹密钥的算法
+const (
+	KDFArgon2id     = "argon2id"
+	KDFPBKDF2SHA256 = "pbkdf2-sha256"
+)
+
+// MemoEnvelope 加密备忘录的密文信封。标题、正文和清单条目在客户端加密后整体放入 Ciphertext，
+// 服务端只校验格式，无法解密
+type MemoEnvelope struct {
+	Version   int    `bson:"v" json:"version"`
+	Algorithm string `bson:"alg" json:"algorithm"`
+	// KeyID 加密所用密钥的ID，对应备忘录所有者的一条密钥材料
+	KeyID      string `bson:"key_id" json:"keyId"`
+	Nonce      string `bson:"nonce" json:"nonce"`
+	Ciphertext string `bson:"ciphertext" json:"ciphertext"`
+}
+
+// WrappedKey 被包裹（加密）的备忘录密钥
+type WrappedKey struct {
+	Algorithm  string `bson:"alg" json:"algorithm"`
+	Nonce      string `bson:"nonce" json:"nonce"`
+	Ciphertext string `bson:"ciphertext" json:"ciphertext"`
+}
+
+// KeyDerivation 由口令派生包裹密钥的参数，Memory 以 KiB 为单位，仅 argon2id 使用
+type KeyDerivation struct {
+	Name        string `bson:"name" json:"name"`
+	Salt        string `bson:"salt" json:"salt"`
+	Iterations  int    `bson:"iterations" json:"iterations"`
+	Memory      int    `bson:"memory,omitempty" json:"memory,omitempty"`
+	Parallelism int    `bson:"parallelism,omitempty" json:"parallelism,omitempty"`
+}
+
+// UserKey 用户的加密密钥材料。备忘录密钥在客户端生成，由口令派生的密钥包裹后保存；
+// Recovery 为恢复密钥包裹的副本，用于忘记口令时找回
+type UserKey struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	UserID     primitive.ObjectID `bson:"user_id" json:"-"`
+	KeyID      string             `bson:"key_id" json:"keyId"`
+	WrappedKey WrappedKey         `bson:"wrapped_key" json:"wrappedKey"`
+	KDF        KeyDerivation      `bson:"kdf" json:"kdf"`
+	Recovery   *WrappedKey        `bson:"recovery,omitempty" json:"recovery,omitempty"`
+	CreatedAt  time.Time          `bson:"created_at" json:"createTime"`
+	UpdatedAt  time.Time          `bson:"updated_at" json:"updateTime"`
+}
+
+// UserKeyRequest 保存密钥材料请求模型，修改口令时以新口令重新包裹后整体替换
+type UserKeyRequest struct {
+	WrappedKey WrappedKey    `json:"wrappedKey"`
+	KDF        KeyDerivation `json:"kdf"`
+	Recovery   *WrappedKey   `json:"recovery"`
+}
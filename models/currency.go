@@ -14,6 +14,8 @@ type CurrencyBalance struct {
 	LastUpdateTime time.Time          `bson:"last_update_time" json:"lastUpdateTime"`
 	CreatedAt      time.Time          `bson:"created_at" json:"createdAt"`
 	UpdatedAt      time.Time          `bson:"updated_at" json:"updatedAt"`
+	// PendingRefunds 已退回余额但尚未标记为 released 的预扣ID，防止重试时重复退回
+	PendingRefunds []primitive.ObjectID `bson:"pending_refunds,omitempty" json:"-"`
 }
 
 // CurrencyTransaction 算力交易记录模型
@@ -29,6 +31,41 @@ type CurrencyTransaction struct {
 	CreatedAt     time.Time          `bson:"created_at" json:"createdAt"`
 }
 
+// 算力交易类型
+const (
+	TransactionDeduct   = "deduct"
+	TransactionRecharge = "recharge"
+	// TransactionHold 预扣，确认后改为 deduct
+	TransactionHold = "hold"
+	// TransactionRefund 预扣失败或超时后退回
+	TransactionRefund = "refund"
+)
+
+// 预扣状态
+const (
+	HoldPending  = "held"
+	HoldCaptured = "captured"
+	HoldReleased = "released"
+	// HoldReleasing 正在退回，退回中断时由超时任务继续完成
+	HoldReleasing = "releasing"
+)
+
+// CurrencyHold 算力预扣记录：调用计费功能前先从余额中扣除，功能成功后确认，失败或超时退回
+type CurrencyHold struct {
+	ID            primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	UserID        primitive.ObjectID  `bson:"user_id" json:"-"`
+	Amount        int                 `bson:"amount" json:"amount"`
+	Reason        string              `bson:"reason" json:"reason"`
+	MemoID        *primitive.ObjectID `bson:"memo_id,omitempty" json:"memoId,omitempty"`
+	Status        string              `bson:"status" json:"status"`
+	TransactionID string              `bson:"transaction_id" json:"transactionId"`
+	CreatedAt     time.Time           `bson:"created_at" json:"createdAt"`
+	ExpiresAt     time.Time           `bson:"expires_at" json:"expiresAt"`
+	SettledAt     *time.Time          `bson:"settled_at,omitempty" json:"settledAt,omitempty"`
+	// ReleasingAt 进入 releasing 状态或最近一次重试退回的时间
+	ReleasingAt *time.Time `bson:"releasing_at,omitempty" json:"-"`
+}
+
 // DeductRequest 扣减算力请求模型
 type DeductRequest struct {
 	Amount int                `json:"amount" binding:"required,min=1"`
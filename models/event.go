@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+)
+
+// 实时事件类型
+const (
+	EventMemoCreated    = "memo.created"
+	EventMemoUpdated    = "memo.updated"
+	EventMemoDeleted    = "memo.deleted"
+	EventBalanceChanged = "balance.changed"
+
+	EventNotificationCreated = "notification.created"
+	EventAIJobUpdated        = "ai_job.updated"
+)
+
+// Event 推送给客户端的实时事件
+type Event struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data,omitempty"`
+	Time time.Time   `json:"time"`
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// 导出任务状态
+const (
+	ExportPending = "pending"
+	ExportRunning = "running"
+	ExportDone    = "done"
+	ExportFailed  = "failed"
+	ExportExpired = "expired"
+)
+
+// ExportJob 后台导出任务
+type ExportJob struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID     primitive.ObjectID `bson:"user_id" json:"-"`
+	Format     string             `bson:"format" json:"format"`
+	Status     string             `bson:"status" json:"status"`
+	MemoCount  int                `bson:"memo_count" json:"memoCount"`
+	Size       int64              `bson:"size" json:"size"`
+	Filename   string             `bson:"filename" json:"filename"`
+	StorageKey string             `bson:"storage_key,omitempty" json:"-"`
+	Error      string             `bson:"error,omitempty" json:"error,omitempty"`
+	CreatedAt  time.Time          `bson:"created_at" json:"createTime"`
+	FinishedAt *time.Time         `bson:"finished_at,omitempty" json:"finishedAt,omitempty"`
+	ExpiresAt  *time.Time         `bson:"expires_at,omitempty" json:"expiresAt,omitempty"`
+
+	// 执行任务的实例持有租约并定期续期，实例退出后租约过期，任务由其他实例重新执行
+	LeaseOwner     string     `bson:"lease_owner,omitempty" json:"-"`
+	LeaseExpiresAt *time.Time `bson:"lease_expires_at,omitempty" json:"-"`
+	Attempts       int        `bson:"attempts" json:"-"`
+
+	// 下载地址，仅在导出完成后返回
+	DownloadURL string `bson:"-" json:"downloadUrl,omitempty"`
+}
+
+// CreateExportRequest 创建导出请求模型，async 为 true 时总是以后台任务方式导出
+type CreateExportRequest struct {
+	Format string `json:"format" binding:"required,oneof=markdown json"`
+	Async  bool   `json:"async"`
+}
+
+// MemoExport JSON导出文件格式
+type MemoExport struct {
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exportedAt"`
+	Memos      []Memo    `json:"memos"`
+}
@@ -0,0 +1,34 @@
+package models
+
+// 导入文件格式
+const (
+	ImportMarkdown = "markdown"
+	ImportJSON     = "json"
+	ImportENEX     = "enex"
+)
+
+// 单条导入结果状态
+const (
+	ImportCreated   = "created"
+	ImportDuplicate = "duplicate"
+	ImportError     = "error"
+)
+
+// ImportResult 单条备忘录的导入结果，Source 为文件中的路径或标题
+type ImportResult struct {
+	Source  string `json:"source"`
+	Status  string `json:"status"`
+	ID      string `json:"id,omitempty"`
+	Title   string `json:"title,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// ImportResponse 导入响应模型
+type ImportResponse struct {
+	Format     string         `json:"format"`
+	Total      int            `json:"total"`
+	Created    int            `json:"created"`
+	Duplicates int            `json:"duplicates"`
+	Failed     int            `json:"failed"`
+	Results    []ImportResult `json:"results"`
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// 备忘录链接类型：按标题链接 [[标题]] 或按ID链接 [[备忘录ID]]
+const (
+	LinkByTitle = "title"
+	LinkByID    = "id"
+)
+
+// MemoLink 备忘录之间的链接索引，由正文中的 [[...]] 解析生成。
+// 链接只在同一用户的备忘录之间解析，目标不存在或已删除时 TargetID 为空
+type MemoLink struct {
+	ID        primitive.ObjectID  `bson:"_id,omitempty" json:"-"`
+	UserID    primitive.ObjectID  `bson:"user_id" json:"-"`
+	SourceID  primitive.ObjectID  `bson:"source_id" json:"-"`
+	Kind      string              `bson:"kind" json:"kind"`
+	Ref       string              `bson:"ref" json:"-"`
+	Text      string              `bson:"text" json:"text"`
+	TargetID  *primitive.ObjectID `bson:"target_id" json:"-"`
+	CreatedAt time.Time           `bson:"created_at" json:"-"`
+}
+
+// LinkedMemo 链接两端备忘录的摘要信息
+type LinkedMemo struct {
+	ID        primitive.ObjectID `json:"id"`
+	Title     string             `json:"title"`
+	UpdatedAt time.Time          `json:"updateTime"`
+}
+
+// OutgoingLink 备忘录正文中的一个链接，Memo 为空表示目标不存在
+type OutgoingLink struct {
+	Text string      `json:"text"`
+	Kind string      `json:"kind"`
+	Memo *LinkedMemo `json:"memo,omitempty"`
+}
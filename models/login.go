@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// 登录尝试结果
+const (
+	LoginSucceeded = "succeeded"
+	LoginFailed    = "failed"
+	// 账号或IP被锁定，未校验密码
+	LoginBlocked = "blocked"
+)
+
+// LoginAttempt 登录尝试记录，用户名不存在时 UserID 为空，只供管理员排查
+type LoginAttempt struct {
+	ID        primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	UserID    *primitive.ObjectID `bson:"user_id,omitempty" json:"-"`
+	Username  string              `bson:"username" json:"username"`
+	IP        string              `bson:"ip" json:"ip"`
+	UserAgent string              `bson:"user_agent" json:"userAgent"`
+	Result    string              `bson:"result" json:"result"`
+	// Suspicious 成功登录但来自新的IP或之前有多次密码错误，SuspiciousReasons 为判断依据
+	Suspicious        bool      `bson:"suspicious,omitempty" json:"suspicious"`
+	SuspiciousReasons []string  `bson:"suspicious_reasons,omitempty" json:"suspiciousReasons,omitempty"`
+	CreatedAt         time.Time `bson:"created_at" json:"createTime"`
+}
+
+// LoginLock 账号或IP的登录失败计数，Key 为 "user:用户名" 或 "ip:地址"
+type LoginLock struct {
+	Key         string     `bson:"_id" json:"key"`
+	Failures    int        `bson:"failures" json:"failures"`
+	Lockouts    int        `bson:"lockouts" json:"lockouts"`
+	LockedUntil *time.Time `bson:"locked_until,omitempty" json:"lockedUntil,omitempty"`
+	// NextAttemptAt 多次失败后下一次允许尝试的时间
+	NextAttemptAt *time.Time `bson:"next_attempt_at,omitempty" json:"nextAttemptAt,omitempty"`
+	UpdatedAt     time.Time  `bson:"updated_at" json:"updateTime"`
+	ExpiresAt     time.Time  `bson:"expires_at" json:"-"`
+}
+
+// UnlockLoginRequest 管理员解除登录锁定，用户名和IP至少指定一个
+type UnlockLoginRequest struct {
+	Username string `json:"username"`
+	IP       string `json:"ip"`
+}
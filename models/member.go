@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// 备忘录协作角色，权限依次递增
+const (
+	RoleViewer = "viewer"
+	RoleEditor = "editor"
+	RoleOwner  = "owner"
+)
+
+// 协作邀请状态
+const (
+	MemberPending  = "pending"
+	MemberAccepted = "accepted"
+)
+
+// MemoMember 备忘录协作成员，备忘录创建者不在此集合中，始终拥有 owner 权限
+type MemoMember struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	MemoID     primitive.ObjectID `bson:"memo_id" json:"memoId"`
+	OwnerID    primitive.ObjectID `bson:"owner_id" json:"-"`
+	UserID     primitive.ObjectID `bson:"user_id" json:"userId"`
+	Username   string             `bson:"username" json:"username"`
+	Role       string             `bson:"role" json:"role"`
+	Status     string             `bson:"status" json:"status"`
+	InvitedBy  string             `bson:"invited_by" json:"invitedBy"`
+	CreatedAt  time.Time          `bson:"created_at" json:"createTime"`
+	AcceptedAt *time.Time         `bson:"accepted_at,omitempty" json:"acceptTime,omitempty"`
+}
+
+// InviteMemberRequest 邀请协作者请求模型
+type InviteMemberRequest struct {
+	Username string `json:"username" binding:"required"`
+	Role     string `json:"role" binding:"required,oneof=viewer editor owner"`
+}
+
+// UpdateMemberRequest 修改协作者角色请求模型
+type UpdateMemberRequest struct {
+	Role string `json:"role" binding:"required,oneof=viewer editor owner"`
+}
+
+// MemoInvitation 待处理的协作邀请
+type MemoInvitation struct {
+	ID        primitive.ObjectID `json:"id"`
+	MemoID    primitive.ObjectID `json:"memoId"`
+	MemoTitle string             `json:"memoTitle"`
+	Role      string             `json:"role"`
+	InvitedBy string             `json:"invitedBy"`
+	CreatedAt time.Time          `json:"createTime"`
+}
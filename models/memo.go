@@ -3,6 +3,8 @@ package models
 import (
 	"time"
 
+	"mjbackend/utils"
+
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
@@ -13,21 +15,313 @@ type Memo struct {
 	Content    string             `bson:"content" json:"content"`
 	CreatedAt  time.Time          `bson:"created_at" json:"createTime"`
 	UpdatedAt  time.Time          `bson:"updated_at" json:"updateTime"`
+	SyncSeq    int64              `bson:"sync_seq" json:"version"`
+	CreatedSeq int64              `bson:"created_seq" json:"-"`
+	ClientID   string             `bson:"client_id,omitempty" json:"-"`
+	DeletedAt  *time.Time         `bson:"deleted_at,omitempty" json:"deletedAt,omitempty"`
+	// 状态标记只存储 true，取消标记时删除字段，保证排序和查询只需区分 true 与缺失
+	Pinned   bool `bson:"pinned,omitempty" json:"pinned"`
+	Archived bool `bson:"archived,omitempty" json:"archived"`
+	Favorite bool `bson:"favorite,omitempty" json:"favorite"`
+
+	// 备忘录类型，为空表示普通备忘录
+	Type string `bson:"type,omitempty" json:"type,omitempty"`
+	// 清单条目按 Position 顺序存储，Progress 为冗余的完成计数，供列表直接展示
+	Items    []ChecklistItem    `bson:"items,omitempty" json:"items,omitempty"`
+	Progress *ChecklistProgress `bson:"progress,omitempty" json:"progress,omitempty"`
+
+	// 标签和所在文件夹，文件夹为空表示根目录
+	Tags   []string `bson:"tags,omitempty" json:"tags,omitempty"`
+	Folder string   `bson:"folder,omitempty" json:"folder,omitempty"`
+
+	// 正文的纯文本摘要，由服务端根据 Markdown 生成，供列表预览
+	Excerpt string `bson:"excerpt,omitempty" json:"excerpt,omitempty"`
+
+	// 截止时间和提醒设置
+	DueAt    *time.Time    `bson:"due_at,omitempty" json:"dueAt,omitempty"`
+	Reminder *MemoReminder `bson:"reminder,omitempty" json:"reminder,omitempty"`
+
+	// AI 生成的摘要
+	Summary *MemoSummary `bson:"summary,omitempty" json:"summary,omitempty"`
+
+	// 端到端加密备忘录的密文信封，加密备忘录的标题、正文和清单条目均为空
+	Encrypted *MemoEnvelope `bson:"encrypted,omitempty" json:"encrypted,omitempty"`
+
+	// 导入去重键，由导入内容计算，仅导入的备忘录有该字段
+	ImportKey string `bson:"import_key,omitempty" json:"-"`
+
+	// 当前用户对该备忘录的角色，仅在响应中返回
+	Role string `bson:"-" json:"role,omitempty"`
+
+	// 全文索引字段，由分析器根据标题和内容生成
+	SearchTitle    string `bson:"search_title,omitempty" json:"-"`
+	SearchText     string `bson:"search_text,omitempty" json:"-"`
+	SearchItems    string `bson:"search_items,omitempty" json:"-"`
+	SearchAnalyzer string `bson:"search_analyzer,omitempty" json:"-"`
+	// 按链接规则归一化的标题（小写、合并空白），按标题解析链接时精确匹配
+	TitleLower string `bson:"title_lower,omitempty" json:"-"`
+}
+
+// 备忘录类型
+const (
+	MemoTypeNote      = "note"
+	MemoTypeChecklist = "checklist"
+)
+
+// ChecklistItem 清单条目，Position 为从0开始的顺序
+type ChecklistItem struct {
+	ID       primitive.ObjectID `bson:"id" json:"id"`
+	Text     string             `bson:"text" json:"text"`
+	Checked  bool               `bson:"checked" json:"checked"`
+	Position int                `bson:"position" json:"position"`
+}
+
+// ChecklistProgress 清单完成情况
+type ChecklistProgress struct {
+	Total   int `bson:"total" json:"total"`
+	Checked int `bson:"checked" json:"checked"`
+}
+
+// MemoSummary AI 生成的摘要，ContentHash 为生成时正文的哈希，正文未变化时重复请求直接返回
+type MemoSummary struct {
+	Text        string    `bson:"text" json:"text"`
+	Provider    string    `bson:"provider" json:"provider"`
+	ContentHash string    `bson:"content_hash" json:"-"`
+	CreatedAt   time.Time `bson:"created_at" json:"createTime"`
+}
+
+// MemoSummaryResponse 生成摘要响应模型，Charged 为本次消耗的算力，使用已有摘要时为0
+type MemoSummaryResponse struct {
+	Summary *MemoSummary `json:"summary"`
+	Charged int          `json:"charged"`
+	Cached  bool         `json:"cached"`
+}
+
+// MemoReminder 备忘录提醒设置
+type MemoReminder struct {
+	// At 首次提醒时间，也是重复提醒的起点
+	At time.Time `bson:"at" json:"at"`
+	// Repeat 重复规则：daily、weekly、monthly、yearly 或 RRULE（如 FREQ=WEEKLY;BYDAY=MO,WE）
+	Repeat string `bson:"repeat,omitempty" json:"repeat,omitempty"`
+	// Timezone 计算重复提醒使用的IANA时区，为空时使用UTC
+	Timezone string `bson:"timezone,omitempty" json:"timezone,omitempty"`
+	// NextAt 下一次提醒时间，没有后续提醒时为空
+	NextAt      *time.Time `bson:"next_at,omitempty" json:"nextAt,omitempty"`
+	LastFiredAt *time.Time `bson:"last_fired_at,omitempty" json:"lastFiredAt,omitempty"`
+}
+
+// SetReminderRequest 设置截止时间和提醒请求模型，字段为空表示清除
+type SetReminderRequest struct {
+	DueAt    *time.Time `json:"dueAt"`
+	RemindAt *time.Time `json:"remindAt"`
+	Repeat   string     `json:"repeat" binding:"max=200"`
+	Timezone string     `json:"timezone" binding:"max=64"`
 }
 
 type CreateMemoRequest struct {
-	Title   string `json:"title" binding:"required"`
+	Title   string `json:"title" binding:"required_without_all=TemplateID Encrypted"`
 	Content string `json:"content"`
+	// Type 为 checklist 时可通过 Items 提供初始条目文本
+	Type   string   `json:"type" binding:"omitempty,oneof=note checklist"`
+	Items  []string `json:"items" binding:"max=500,dive,required,max=1000"`
+	Tags   []string `json:"tags" binding:"max=50,dive,required,max=50"`
+	Folder string   `json:"folder" binding:"max=200"`
+
+	// 使用模板创建时，请求中非空的字段覆盖模板中的对应字段，Variables 为模板占位符的取值，
+	// Timezone 为生成日期、时间占位符使用的IANA时区
+	TemplateID string            `json:"templateId"`
+	Variables  map[string]string `json:"variables" binding:"max=50,dive,keys,max=50,endkeys,max=1000"`
+	Timezone   string            `json:"timezone" binding:"max=64"`
+
+	// Encrypted 不为空时创建加密备忘录，不能同时提供标题、正文、清单或模板
+	Encrypted *MemoEnvelope `json:"encrypted"`
+}
+
+// AddChecklistItemRequest 添加清单条目请求模型，Position 为空时添加到末尾
+type AddChecklistItemRequest struct {
+	Text     string `json:"text" binding:"required,max=1000"`
+	Checked  bool   `json:"checked"`
+	Position *int   `json:"position" binding:"omitempty,min=0"`
+}
+
+// UpdateChecklistItemRequest 修改清单条目请求模型，只修改提供的字段
+type UpdateChecklistItemRequest struct {
+	Text     *string `json:"text" binding:"omitempty,min=1,max=1000"`
+	Checked  *bool   `json:"checked"`
+	Position *int    `json:"position" binding:"omitempty,min=0"`
+}
+
+// ReorderChecklistRequest 清单重新排序请求模型，需包含全部条目ID
+type ReorderChecklistRequest struct {
+	ItemIDs []string `json:"itemIds" binding:"required,max=500"`
 }
 
 type UpdateMemoRequest struct {
-	Title   string `json:"title" binding:"required"`
+	Title   string `json:"title" binding:"required_without=Encrypted"`
 	Content string `json:"content"`
+	// Encrypted 不为空时以密文替换备忘录内容，加密备忘录只能以密文更新
+	Encrypted *MemoEnvelope `json:"encrypted"`
+}
+
+// MemoListQuery 备忘录列表查询条件
+type MemoListQuery struct {
+	Page    int
+	Limit   int
+	Keyword string
+	// Sort 排序方式：created、updated、title 或 pinned（置顶优先）
+	Sort string
+	// Order 排序方向：asc 或 desc，为空时使用各排序方式的默认方向
+	Order string
+	// 状态筛选，为 nil 时不筛选
+	Pinned   *bool
+	Archived *bool
+	Favorite *bool
+	// 按标签和文件夹筛选，Folder 为 nil 时不筛选，为空字符串时只返回根目录
+	Tag    string
+	Folder *string
+	// UseCursor 为 true 时使用游标分页，忽略 Page
+	UseCursor bool
+	Cursor    string
+	// Fields 要返回的字段，为空时返回摘要字段集，为 all 时返回完整备忘录
+	Fields []string
 }
 
 type MemoListResponse struct {
-	List  []Memo `json:"list"`
-	Total int64  `json:"total"`
-	Page  int    `json:"page"`
-	Limit int    `json:"limit"`
-}
\ No newline at end of file
+	// List 为 []Memo；备忘录列表接口除 fields=all 外为只包含选中字段的对象列表
+	List       interface{} `json:"list"`
+	Total      int64       `json:"total"`
+	Page       int         `json:"page"`
+	Limit      int         `json:"limit"`
+	HasMore    bool        `json:"hasMore"`
+	NextCursor string      `json:"nextCursor,omitempty"`
+}
+
+// BulkMemoFilter 批量操作的筛选条件，字段含义与列表查询相同
+type BulkMemoFilter struct {
+	Keyword  string  `json:"keyword"`
+	Tag      string  `json:"tag"`
+	Folder   *string `json:"folder"`
+	Pinned   *bool   `json:"pinned"`
+	Archived *bool   `json:"archived"`
+	Favorite *bool   `json:"favorite"`
+	// All 为 true 时才允许不带任何条件的筛选，即操作全部备忘录
+	All bool `json:"all"`
+}
+
+// BulkMemoRequest 批量操作请求模型，ids 与 filter 二选一
+type BulkMemoRequest struct {
+	Action string          `json:"action" binding:"required,oneof=delete restore archive unarchive tag untag move"`
+	IDs    []string        `json:"ids" binding:"max=1000"`
+	Filter *BulkMemoFilter `json:"filter"`
+	// Tags 用于 tag / untag 操作
+	Tags []string `json:"tags" binding:"max=50,dive,required,max=50"`
+	// Folder 用于 move 操作，为空表示移动到根目录
+	Folder string `json:"folder" binding:"max=200"`
+}
+
+// BulkMemoResult 单个备忘录的批量操作结果，status 为 applied、not_found 或 error
+type BulkMemoResult struct {
+	ID      string `json:"id"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// BulkMemoResponse 批量操作响应模型
+type BulkMemoResponse struct {
+	Action   string           `json:"action"`
+	Matched  int              `json:"matched"`
+	Modified int64            `json:"modified"`
+	Results  []BulkMemoResult `json:"results"`
+}
+
+// MemoTombstone 已删除备忘录的墓碑记录，用于增量同步
+type MemoTombstone struct {
+	ID        primitive.ObjectID `json:"id"`
+	DeletedAt time.Time          `json:"deletedAt"`
+	Version   int64              `json:"version"`
+}
+
+// MemoChangesResponse 增量同步响应模型
+type MemoChangesResponse struct {
+	Created []Memo          `json:"created"`
+	Updated []Memo          `json:"updated"`
+	Deleted []MemoTombstone `json:"deleted"`
+	Cursor  string          `json:"cursor"`
+	HasMore bool            `json:"hasMore"`
+}
+
+// SyncChange 客户端离线编辑的单条变更
+type SyncChange struct {
+	Op          string `json:"op" binding:"required,oneof=create update delete"`
+	ClientID    string `json:"clientId"`
+	ID          string `json:"id"`
+	BaseVersion int64  `json:"baseVersion"`
+	Title       string `json:"title"`
+	Content     string `json:"content"`
+	// Encrypted 不为空时表示加密备忘录的密文，此时忽略标题和正文
+	Encrypted *MemoEnvelope `json:"encrypted"`
+}
+
+// SyncPushRequest 批量推送离线变更请求模型
+type SyncPushRequest struct {
+	Changes []SyncChange `json:"changes" binding:"required,max=500,dive"`
+}
+
+// SyncChangeResult 单条变更的处理结果，status 为 applied、conflict 或 error
+type SyncChangeResult struct {
+	ClientID string `json:"clientId,omitempty"`
+	ID       string `json:"id,omitempty"`
+	Status   string `json:"status"`
+	Message  string `json:"message,omitempty"`
+	Memo     *Memo  `json:"memo,omitempty"`
+}
+
+// SyncPushResponse 批量推送响应模型
+type SyncPushResponse struct {
+	Results []SyncChangeResult `json:"results"`
+}
+
+// MemoSearchHit 全文搜索命中结果，高亮区间以字符为单位
+type MemoSearchHit struct {
+	Memo            Memo              `json:"memo"`
+	Score           float64           `json:"score"`
+	Snippet         string            `json:"snippet"`
+	Highlights      []utils.TextRange `json:"highlights"`
+	TitleHighlights []utils.TextRange `json:"titleHighlights"`
+}
+
+// MemoSearchResponse 全文搜索响应模型
+type MemoSearchResponse struct {
+	List  []MemoSearchHit `json:"list"`
+	Total int64           `json:"total"`
+	Page  int             `json:"page"`
+	Limit int             `json:"limit"`
+}
+
+// MemoEmbedding 备忘录的文本向量，保存在 memo_embeddings 集合中，_id 与备忘录相同
+type MemoEmbedding struct {
+	MemoID      primitive.ObjectID `bson:"_id" json:"-"`
+	UserID      primitive.ObjectID `bson:"user_id" json:"-"`
+	Model       string             `bson:"model" json:"-"`
+	Vector      []float32          `bson:"vector" json:"-"`
+	ContentHash string             `bson:"content_hash" json:"-"`
+	UpdatedAt   time.Time          `bson:"updated_at" json:"-"`
+}
+
+// SemanticSearchHit 语义搜索命中结果，score 为余弦相似度
+type SemanticSearchHit struct {
+	Memo  Memo    `json:"memo"`
+	Score float64 `json:"score"`
+}
+
+// SemanticSearchResponse 语义搜索响应模型，engine 为 vector_index（数据库向量索引）或 brute_force（进程内逐条比较）
+type SemanticSearchResponse struct {
+	List   []SemanticSearchHit `json:"list"`
+	Engine string              `json:"engine"`
+}
+
+// MemoHTMLResponse 带渲染结果的备忘录，HTML 已经过白名单过滤
+type MemoHTMLResponse struct {
+	Memo
+	HTML string `json:"html"`
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// 通知类型
+const (
+	NotificationMemoReminder = "memo.reminder"
+	NotificationExportReady  = "export.ready"
+	NotificationExportFailed = "export.failed"
+	NotificationLoginAlert   = "security.login"
+)
+
+// Notification 站内通知
+type Notification struct {
+	ID     primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	UserID primitive.ObjectID  `bson:"user_id" json:"-"`
+	Type   string              `bson:"type" json:"type"`
+	Title  string              `bson:"title" json:"title"`
+	Body   string              `bson:"body" json:"body"`
+	MemoID *primitive.ObjectID `bson:"memo_id,omitempty" json:"memoId,omitempty"`
+	// FireAt 触发通知的提醒时间
+	FireAt *time.Time `bson:"fire_at,omitempty" json:"fireAt,omitempty"`
+	// DedupeKey 去重键，同一提醒在多实例或重试时只会生成一条通知
+	DedupeKey string     `bson:"dedupe_key,omitempty" json:"-"`
+	ReadAt    *time.Time `bson:"read_at,omitempty" json:"readAt,omitempty"`
+	CreatedAt time.Time  `bson:"created_at" json:"createTime"`
+}
+
+// NotificationListResponse 通知列表响应模型
+type NotificationListResponse struct {
+	List   []Notification `json:"list"`
+	Total  int64          `json:"total"`
+	Unread int64          `json:"unread"`
+	Page   int            `json:"page"`
+	Limit  int            `json:"limit"`
+}
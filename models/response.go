@@ -12,6 +12,11 @@ type ErrorResponse struct {
 	Data    interface{} `json:"data"`
 }
 
+// RateLimitError 请求被限流时返回的数据，RetryAfter 为建议等待的秒数，与 Retry-After 响应头一致
+type RateLimitError struct {
+	RetryAfter int `json:"retryAfter"`
+}
+
 // 成功响应
 func SuccessResponse(data interface{}) Response {
 	return Response{
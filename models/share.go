@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MemoShare 备忘录公开分享链接
+type MemoShare struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID       primitive.ObjectID `bson:"user_id" json:"-"`
+	MemoID       primitive.ObjectID `bson:"memo_id" json:"memoId"`
+	Token        string             `bson:"token" json:"token"`
+	PasswordHash string             `bson:"password_hash,omitempty" json:"-"`
+	HasPassword  bool               `bson:"has_password" json:"hasPassword"`
+	ExpiresAt    *time.Time         `bson:"expires_at,omitempty" json:"expiresAt,omitempty"`
+	Views        int64              `bson:"views" json:"views"`
+	LastViewedAt *time.Time         `bson:"last_viewed_at,omitempty" json:"lastViewedAt,omitempty"`
+	RevokedAt    *time.Time         `bson:"revoked_at,omitempty" json:"revokedAt,omitempty"`
+	CreatedAt    time.Time          `bson:"created_at" json:"createTime"`
+}
+
+// CreateShareRequest 创建分享链接请求模型，expiresInHours 为0表示永不过期
+type CreateShareRequest struct {
+	ExpiresInHours int    `json:"expiresInHours" binding:"min=0,max=8760"`
+	Password       string `json:"password" binding:"omitempty,min=4,max=64"`
+}
+
+// SharedMemoResponse 通过分享链接访问的只读备忘录
+type SharedMemoResponse struct {
+	Title     string          `json:"title"`
+	Content   string          `json:"content"`
+	Type      string          `json:"type,omitempty"`
+	Items     []ChecklistItem `json:"items,omitempty"`
+	Encrypted *MemoEnvelope   `json:"encrypted,omitempty"`
+	CreatedAt time.Time       `json:"createTime"`
+	UpdatedAt time.Time       `json:"updateTime"`
+	Views     int64           `json:"views"`
+	ExpiresAt *time.Time      `json:"expiresAt,omitempty"`
+}
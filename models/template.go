@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MemoTemplate 备忘录模板，标题和内容中可以使用 {{变量}} 占位符。
+// 内置模板不存储在数据库中，Builtin 为 true 且不能修改或删除
+type MemoTemplate struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID      primitive.ObjectID `bson:"user_id" json:"-"`
+	Name        string             `bson:"name" json:"name"`
+	Description string             `bson:"description,omitempty" json:"description,omitempty"`
+	Title       string             `bson:"title" json:"title"`
+	Content     string             `bson:"content" json:"content"`
+	Type        string             `bson:"type,omitempty" json:"type,omitempty"`
+	Items       []string           `bson:"items,omitempty" json:"items,omitempty"`
+	Tags        []string           `bson:"tags,omitempty" json:"tags,omitempty"`
+	Folder      string             `bson:"folder,omitempty" json:"folder,omitempty"`
+	Builtin     bool               `bson:"-" json:"builtin"`
+	CreatedAt   time.Time          `bson:"created_at" json:"createTime"`
+	UpdatedAt   time.Time          `bson:"updated_at" json:"updateTime"`
+}
+
+// TemplateRequest 创建或更新模板请求模型
+type TemplateRequest struct {
+	Name        string   `json:"name" binding:"required,max=100"`
+	Description string   `json:"description" binding:"max=500"`
+	Title       string   `json:"title" binding:"required,max=200"`
+	Content     string   `json:"content"`
+	Type        string   `json:"type" binding:"omitempty,oneof=note checklist"`
+	Items       []string `json:"items" binding:"max=500,dive,required,max=1000"`
+	Tags        []string `json:"tags" binding:"max=50,dive,required,max=50"`
+	Folder      string   `json:"folder" binding:"max=200"`
+}
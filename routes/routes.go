@@ -1,6 +1,9 @@
 package routes
 
 import (
+	"net/http"
+
+	"mjbackend/config"
 	"mjbackend/controllers"
 	"mjbackend/middleware"
 	"mjbackend/services"
@@ -19,6 +22,23 @@ func SetupRoutes(r *gin.Engine) {
 	authController := controllers.NewAuthController()
 	memoController := controllers.NewMemoController()
 	currencyController := controllers.NewCurrencyController(currencyService)
+	eventController := controllers.NewEventController(services.Events)
+	attachmentController := controllers.NewAttachmentController()
+	shareController := controllers.NewShareController()
+	memberController := controllers.NewMemberController()
+	reminderController := controllers.NewReminderController()
+	notificationController := controllers.NewNotificationController()
+	checklistController := controllers.NewChecklistController()
+	exportController := controllers.NewExportController()
+	importController := controllers.NewImportController()
+	templateController := controllers.NewTemplateController()
+	keyController := controllers.NewKeyController()
+	aiJobController := controllers.NewAIJobController(currencyService)
+
+	// 限流：已认证的接口按用户计数，登录、注册和公开分享链接按客户端IP计数
+	limiter := services.NewRateLimiter()
+	apiLimit := middleware.RateLimit(limiter, config.RateLimitAPI)
+	aiLimit := middleware.RateLimit(limiter, config.RateLimitAI)
 
 	// API路由组
 	api := r.Group("/api")
@@ -26,31 +46,159 @@ func SetupRoutes(r *gin.Engine) {
 		// 认证路由
 		auth := api.Group("/auth")
 		{
-			auth.POST("/login", authController.Login)
-			auth.POST("/register", authController.Register)
+			auth.POST("/login", middleware.RateLimit(limiter, config.RateLimitLogin), authController.Login)
+			auth.POST("/register", middleware.RateLimit(limiter, config.RateLimitRegister), authController.Register)
+			auth.GET("/login-attempts", middleware.AuthMiddleware(), apiLimit, authController.ListLoginAttempts)
 		}
 
 		// 备忘录路由（需要认证）
 		memos := api.Group("/memos")
-		memos.Use(middleware.AuthMiddleware())
+		memos.Use(middleware.AuthMiddleware(), apiLimit)
 		{
 			memos.GET("", memoController.GetMemoList)
 			memos.POST("", memoController.CreateMemo)
+			memos.GET("/search", memoController.SearchMemos)
+			memos.GET("/semantic-search", memoController.SemanticSearchMemos)
+			memos.GET("/shared", memoController.GetSharedWithMe)
+			memos.GET("/changes", memoController.GetMemoChanges)
+			memos.POST("/sync", memoController.SyncMemos)
+			memos.POST("/bulk", memoController.BulkMemos)
 			memos.GET("/:id", memoController.GetMemoByID)
 			memos.PUT("/:id", memoController.UpdateMemo)
+			memos.PATCH("/:id", memoController.PatchMemo)
 			memos.DELETE("/:id", memoController.DeleteMemo)
+			memos.POST("/:id/pin", memoController.PinMemo)
+			memos.DELETE("/:id/pin", memoController.UnpinMemo)
+			memos.POST("/:id/archive", memoController.ArchiveMemo)
+			memos.DELETE("/:id/archive", memoController.UnarchiveMemo)
+			memos.POST("/:id/favorite", memoController.FavoriteMemo)
+			memos.DELETE("/:id/favorite", memoController.UnfavoriteMemo)
+			memos.PUT("/:id/reminder", reminderController.SetReminder)
+			memos.DELETE("/:id/reminder", reminderController.ClearReminder)
+			memos.GET("/:id/backlinks", memoController.GetBacklinks)
+			memos.GET("/:id/links", memoController.GetOutgoingLinks)
+			memos.POST("/:id/summarize", aiLimit, memoController.SummarizeMemo)
+
+			// 清单条目
+			memos.POST("/:id/items", checklistController.AddItem)
+			memos.PUT("/:id/items/order", checklistController.ReorderItems)
+			memos.PUT("/:id/items/:itemId", checklistController.UpdateItem)
+			memos.POST("/:id/items/:itemId/toggle", checklistController.ToggleItem)
+			memos.DELETE("/:id/items/:itemId", checklistController.DeleteItem)
+
+			// 附件
+			memos.POST("/:id/attachments", attachmentController.UploadAttachment)
+			memos.GET("/:id/attachments", attachmentController.ListAttachments)
+			memos.GET("/:id/attachments/:attachmentId", attachmentController.DownloadAttachment)
+			memos.DELETE("/:id/attachments/:attachmentId", attachmentController.DeleteAttachment)
+
+			// 分享链接
+			memos.POST("/:id/shares", shareController.CreateShare)
+			memos.GET("/:id/shares", shareController.ListShares)
+			memos.DELETE("/:id/shares/:shareId", shareController.RevokeShare)
+
+			// 协作者
+			memos.POST("/:id/members", memberController.InviteMember)
+			memos.GET("/:id/members", memberController.ListMembers)
+			memos.PUT("/:id/members/:memberId", memberController.UpdateMember)
+			memos.DELETE("/:id/members/:memberId", memberController.RemoveMember)
+		}
+
+		// 协作邀请路由（需要认证）
+		invitations := api.Group("/invitations")
+		invitations.Use(middleware.AuthMiddleware(), apiLimit)
+		{
+			invitations.GET("", memberController.ListInvitations)
+			invitations.POST("/:id/accept", memberController.AcceptInvitation)
+			invitations.POST("/:id/decline", memberController.DeclineInvitation)
+		}
+
+		// 模板路由（需要认证）
+		templates := api.Group("/templates")
+		templates.Use(middleware.AuthMiddleware(), apiLimit)
+		{
+			templates.GET("", templateController.ListTemplates)
+			templates.POST("", templateController.CreateTemplate)
+			templates.GET("/:id", templateController.GetTemplate)
+			templates.PUT("/:id", templateController.UpdateTemplate)
+			templates.DELETE("/:id", templateController.DeleteTemplate)
+		}
+
+		// 加密密钥材料路由（需要认证）
+		keys := api.Group("/keys")
+		keys.Use(middleware.AuthMiddleware(), apiLimit)
+		{
+			keys.GET("", keyController.ListKeys)
+			keys.PUT("/:keyId", keyController.PutKey)
+			keys.DELETE("/:keyId", keyController.DeleteKey)
+		}
+
+		// 导出路由（需要认证，下载链接支持通过access_token查询参数传递token）
+		exports := api.Group("/exports")
+		exports.Use(middleware.QueryTokenMiddleware(), middleware.AuthMiddleware(), apiLimit)
+		{
+			exports.POST("", exportController.CreateExport)
+			exports.GET("", exportController.ListExports)
+			exports.GET("/:id", exportController.GetExport)
+			exports.GET("/:id/download", exportController.DownloadExport)
+		}
+
+		// 导入路由（需要认证）
+		imports := api.Group("/imports")
+		imports.Use(middleware.AuthMiddleware(), apiLimit)
+		{
+			imports.POST("", importController.ImportMemos)
+		}
+
+		// 站内通知路由（需要认证）
+		notifications := api.Group("/notifications")
+		notifications.Use(middleware.AuthMiddleware(), apiLimit)
+		{
+			notifications.GET("", notificationController.ListNotifications)
+			notifications.POST("/read-all", notificationController.MarkAllRead)
+			notifications.POST("/:id/read", notificationController.MarkRead)
+			notifications.DELETE("/:id", notificationController.DeleteNotification)
 		}
 
 		// 算力管理路由（需要认证）
 		currency := api.Group("/currency")
-		currency.Use(middleware.AuthMiddleware())
+		currency.Use(middleware.AuthMiddleware(), apiLimit)
 		{
 			currency.GET("/balance", currencyController.GetBalance)
-			currency.POST("/deduct", currencyController.DeductBalance)
+			currency.POST("/deduct", middleware.RateLimit(limiter, config.RateLimitDeduct), currencyController.DeductBalance)
 			currency.POST("/recharge", currencyController.RechargeBalance)
 		}
+
+		// 后台AI任务路由（需要认证）
+		aiJobs := api.Group("/ai/jobs")
+		aiJobs.Use(middleware.AuthMiddleware(), apiLimit)
+		{
+			aiJobs.POST("", aiLimit, aiJobController.CreateJob)
+			aiJobs.GET("", aiJobController.ListJobs)
+			aiJobs.GET("/:id", aiJobController.GetJob)
+			aiJobs.POST("/:id/cancel", aiJobController.CancelJob)
+		}
+
+		// 管理员路由（需要认证和管理员权限）
+		admin := api.Group("/admin")
+		admin.Use(middleware.AuthMiddleware(), middleware.AdminMiddleware(), apiLimit)
+		{
+			admin.GET("/login-locks", authController.ListLoginLocks)
+			admin.POST("/login-locks/unlock", authController.UnlockLogin)
+		}
+
+		// 实时事件推送路由（需要认证，支持通过access_token查询参数传递token）
+		events := api.Group("/events")
+		events.Use(middleware.QueryTokenMiddleware(), middleware.AuthMiddleware(), apiLimit)
+		{
+			events.GET("", eventController.Stream)
+		}
 	}
 
+	// 公开分享链接（无需认证），访问密码错误另按链接和IP严格限流，防止暴力猜测密码
+	sharePasswordLimit := middleware.RateLimitFailures(limiter, config.RateLimitSharePassword, http.StatusForbidden)
+	r.GET("/s/:token", apiLimit, sharePasswordLimit, shareController.ViewSharedMemo)
+
 	// 健康检查
 	r.GET("/health", func(c *gin.Context) {
 		c.JSON(200, gin.H{
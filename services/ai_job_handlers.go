@@ -0,0 +1,97 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"mjbackend/config"
+	"mjbackend/models"
+)
+
+// aiJobHandler 一种AI任务的实现
+type aiJobHandler struct {
+	// 记录在算力交易中的扣费原因
+	reason string
+	// 任务消耗的算力，参数已通过校验
+	cost func(params map[string]interface{}) int
+	// 提交时检查备忘录和参数
+	validate func(ctx context.Context, memo *models.Memo, params map[string]interface{}) error
+	// 执行任务，report 上报进度（0-100），返回的结果写入任务
+	run func(ctx context.Context, job *models.AIJob, report func(int)) (map[string]interface{}, error)
+}
+
+// aiJobError 不可重试的任务错误，例如备忘录已删除或已加密
+type aiJobError struct {
+	message string
+}
+
+func (e *aiJobError) Error() string { return e.message }
+
+func permanentJobError(err error) error {
+	return &aiJobError{message: err.Error()}
+}
+
+func (s *AIJobService) registerHandlers() map[string]aiJobHandler {
+	return map[string]aiJobHandler{
+		models.AIJobSummarize: {
+			reason:   "AI摘要",
+			cost:     func(map[string]interface{}) int { return config.AppConfig.AISummaryCost },
+			validate: validateSummarizeJob,
+			run:      s.runSummarize,
+		},
+		models.AIJobImageGenerate: {
+			reason:   "AI图片生成",
+			cost:     imageJobCost(models.AIJobImageGenerate),
+			validate: s.validateImageJob(models.AIJobImageGenerate),
+			run:      s.runImageGenerate,
+		},
+		models.AIJobImageVariation: {
+			reason:   "AI图片变体",
+			cost:     imageJobCost(models.AIJobImageVariation),
+			validate: s.validateImageJob(models.AIJobImageVariation),
+			run:      s.runImageVariation,
+		},
+		models.AIJobImageUpscale: {
+			reason:   "AI图片放大",
+			cost:     imageJobCost(models.AIJobImageUpscale),
+			validate: s.validateImageJob(models.AIJobImageUpscale),
+			run:      s.runImageUpscale,
+		},
+	}
+}
+
+func validateSummarizeJob(ctx context.Context, memo *models.Memo, params map[string]interface{}) error {
+	if len(params) > 0 {
+		return errors.New("该任务类型不接受参数")
+	}
+	_, err := summarySource(memo)
+	return err
+}
+
+// runSummarize 生成摘要并写入备忘录，与同步接口不同，总是重新生成
+func (s *AIJobService) runSummarize(ctx context.Context, job *models.AIJob, report func(int)) (map[string]interface{}, error) {
+	memo, err := authorizeMemo(ctx, job.UserID, job.MemoID, models.RoleEditor)
+	if err != nil {
+		if err.Error() == "备忘录不存在" || err.Error() == "没有操作权限" {
+			return nil, permanentJobError(err)
+		}
+		return nil, err
+	}
+	source, err := summarySource(memo)
+	if err != nil {
+		return nil, permanentJobError(err)
+	}
+	report(20)
+
+	summary, err := s.memoService.generateSummary(ctx, memo, source, summaryHash(memo.Title, source))
+	if err != nil {
+		if err.Error() == "备忘录不存在" {
+			return nil, permanentJobError(err)
+		}
+		return nil, err
+	}
+	return map[string]interface{}{
+		"summary":  summary.Text,
+		"provider": summary.Provider,
+	}, nil
+}
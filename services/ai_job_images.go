@@ -0,0 +1,308 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"mjbackend/config"
+	"mjbackend/models"
+	"mjbackend/utils"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// 支持的图片尺寸及计价单位，1024x1024 为 2 个单位，即 AI_IMAGE_COST 对应的标准尺寸
+var imageSizeUnits = map[string]int{
+	"512x512":   1,
+	"1024x1024": 2,
+	"1024x1792": 3,
+	"1792x1024": 3,
+}
+
+const (
+	defaultImageSize = "1024x1024"
+	// 每个任务最多生成的图片数
+	maxImagesPerJob = 4
+	// 提示词上限（字符）
+	maxImagePrompt = 1000
+	// 放大后图片的最长边上限（像素）
+	maxUpscaledSide = 4096
+)
+
+// imageJobParams 图片任务参数。生成任务使用 prompt、negativePrompt、size、n、seed；
+// 变体任务使用 attachmentId、n；放大任务使用 attachmentId、scale
+type imageJobParams struct {
+	Prompt         string `json:"prompt"`
+	NegativePrompt string `json:"negativePrompt"`
+	Size           string `json:"size"`
+	N              int    `json:"n"`
+	Seed           int64  `json:"seed"`
+	AttachmentID   string `json:"attachmentId"`
+	Scale          int    `json:"scale"`
+
+	attachmentID primitive.ObjectID
+	width        int
+	height       int
+}
+
+// parseImageParams 解析并校验图片任务参数，未提供的参数取默认值
+func parseImageParams(jobType string, raw map[string]interface{}) (*imageJobParams, error) {
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, errors.New("无效的任务参数")
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var p imageJobParams
+	if err := decoder.Decode(&p); err != nil {
+		return nil, errors.New("无效的任务参数：" + strings.TrimPrefix(err.Error(), "json: "))
+	}
+
+	switch jobType {
+	case models.AIJobImageGenerate:
+		p.Prompt = strings.TrimSpace(p.Prompt)
+		p.NegativePrompt = strings.TrimSpace(p.NegativePrompt)
+		switch {
+		case p.Prompt == "":
+			return nil, errors.New("无效的任务参数：prompt 不能为空")
+		case utf8.RuneCountInString(p.Prompt) > maxImagePrompt || utf8.RuneCountInString(p.NegativePrompt) > maxImagePrompt:
+			return nil, fmt.Errorf("无效的任务参数：提示词不能超过 %d 个字符", maxImagePrompt)
+		case p.AttachmentID != "" || p.Scale != 0:
+			return nil, errors.New("无效的任务参数：生成图片不接受 attachmentId 和 scale")
+		}
+		if p.Size == "" {
+			p.Size = defaultImageSize
+		}
+		if imageSizeUnits[p.Size] == 0 {
+			return nil, fmt.Errorf("无效的任务参数：不支持的尺寸 %s", p.Size)
+		}
+		p.width, p.height = parseImageSize(p.Size)
+	case models.AIJobImageVariation, models.AIJobImageUpscale:
+		if p.Prompt != "" || p.NegativePrompt != "" || p.Size != "" || p.Seed != 0 {
+			return nil, errors.New("无效的任务参数：变体和放大只接受 attachmentId、n 和 scale")
+		}
+		id, err := primitive.ObjectIDFromHex(p.AttachmentID)
+		if err != nil {
+			return nil, errors.New("无效的任务参数：attachmentId 无效")
+		}
+		p.attachmentID = id
+	}
+
+	if jobType == models.AIJobImageUpscale {
+		if p.N != 0 {
+			return nil, errors.New("无效的任务参数：放大不接受 n")
+		}
+		if p.Scale == 0 {
+			p.Scale = 2
+		}
+		if p.Scale != 2 && p.Scale != 4 {
+			return nil, errors.New("无效的任务参数：scale 只能为 2 或 4")
+		}
+	} else {
+		if p.Scale != 0 {
+			return nil, errors.New("无效的任务参数：只有放大接受 scale")
+		}
+		if p.N == 0 {
+			p.N = 1
+		}
+		if p.N < 1 || p.N > maxImagesPerJob {
+			return nil, fmt.Errorf("无效的任务参数：n 必须在 1 到 %d 之间", maxImagesPerJob)
+		}
+	}
+	return &p, nil
+}
+
+func parseImageSize(size string) (int, int) {
+	w, h, _ := strings.Cut(size, "x")
+	width, _ := strconv.Atoi(w)
+	height, _ := strconv.Atoi(h)
+	return width, height
+}
+
+// imageJobCost 图片任务的计价规则：生成按张数和尺寸计价，1024x1024 每张 AI_IMAGE_COST，
+// 512x512 减半，竖版和横版为 1.5 倍；变体按标准尺寸计价；放大 2 倍为半价，4 倍为全价
+func imageJobCost(jobType string) func(params map[string]interface{}) int {
+	return func(params map[string]interface{}) int {
+		p, err := parseImageParams(jobType, params)
+		if err != nil {
+			return 0
+		}
+		price := config.AppConfig.AIImageCost
+		switch jobType {
+		case models.AIJobImageGenerate:
+			return (price*imageSizeUnits[p.Size]*p.N + 1) / 2
+		case models.AIJobImageVariation:
+			return price * p.N
+		default:
+			return (price*p.Scale/2 + 1) / 2
+		}
+	}
+}
+
+// validateImageJob 提交时检查图片任务。生成的图片保存为附件，与上传附件一样需要编辑及以上权限
+func (s *AIJobService) validateImageJob(jobType string) func(ctx context.Context, memo *models.Memo, params map[string]interface{}) error {
+	return func(ctx context.Context, memo *models.Memo, params map[string]interface{}) error {
+		p, err := parseImageParams(jobType, params)
+		if err != nil {
+			return err
+		}
+		if jobType == models.AIJobImageGenerate {
+			return nil
+		}
+
+		attachment, err := s.attachmentService.getAttachment(ctx, memo.ID, p.attachmentID)
+		if err != nil {
+			return err
+		}
+		if !utils.ThumbnailSupported(attachment.ContentType) {
+			return errors.New("附件不是图片")
+		}
+		if attachment.Width*attachment.Height > utils.MaxImagePixels {
+			return utils.ErrImageTooLarge
+		}
+		return checkUpscaleSize(jobType, attachment.Width, attachment.Height, p.Scale)
+	}
+}
+
+func checkUpscaleSize(jobType string, width, height, scale int) error {
+	if jobType == models.AIJobImageUpscale && (width*scale > maxUpscaledSide || height*scale > maxUpscaledSide) {
+		return errors.New("图片尺寸过大")
+	}
+	return nil
+}
+
+// loadImageJob 执行前重新检查权限和参数，这些错误重试也无法恢复
+func (s *AIJobService) loadImageJob(ctx context.Context, job *models.AIJob) (*models.Memo, *imageJobParams, error) {
+	memo, err := authorizeMemo(ctx, job.UserID, job.MemoID, models.RoleEditor)
+	if err != nil {
+		if err.Error() == "备忘录不存在" || err.Error() == "没有操作权限" {
+			return nil, nil, permanentJobError(err)
+		}
+		return nil, nil, err
+	}
+	p, err := parseImageParams(job.Type, job.Params)
+	if err != nil {
+		return nil, nil, permanentJobError(err)
+	}
+	return memo, p, nil
+}
+
+// loadSourceImage 读取变体和放大任务的原图
+func (s *AIJobService) loadSourceImage(ctx context.Context, job *models.AIJob, p *imageJobParams) (*models.Attachment, []byte, error) {
+	attachment, data, err := s.attachmentService.readImage(ctx, job.MemoID, p.attachmentID)
+	if err != nil {
+		if err.Error() == "附件不存在" || err.Error() == "附件不是图片" {
+			return nil, nil, permanentJobError(err)
+		}
+		return nil, nil, err
+	}
+	return attachment, data, nil
+}
+
+func (s *AIJobService) runImageGenerate(ctx context.Context, job *models.AIJob, report func(int)) (map[string]interface{}, error) {
+	memo, p, err := s.loadImageJob(ctx, job)
+	if err != nil {
+		return nil, err
+	}
+	report(10)
+
+	images, err := s.images.Generate(ctx, &ImageRequest{
+		Prompt:         p.Prompt,
+		NegativePrompt: p.NegativePrompt,
+		Width:          p.width,
+		Height:         p.height,
+		N:              p.N,
+		Seed:           p.Seed,
+	})
+	if err != nil {
+		return nil, imageError(err)
+	}
+	report(80)
+	return s.saveJobImages(ctx, job, memo.UserID, images)
+}
+
+func (s *AIJobService) runImageVariation(ctx context.Context, job *models.AIJob, report func(int)) (map[string]interface{}, error) {
+	memo, p, err := s.loadImageJob(ctx, job)
+	if err != nil {
+		return nil, err
+	}
+	source, data, err := s.loadSourceImage(ctx, job, p)
+	if err != nil {
+		return nil, err
+	}
+	report(10)
+
+	images, err := s.images.Vary(ctx, data, source.ContentType, p.N)
+	if err != nil {
+		return nil, imageError(err)
+	}
+	report(80)
+	return s.saveJobImages(ctx, job, memo.UserID, images)
+}
+
+func (s *AIJobService) runImageUpscale(ctx context.Context, job *models.AIJob, report func(int)) (map[string]interface{}, error) {
+	memo, p, err := s.loadImageJob(ctx, job)
+	if err != nil {
+		return nil, err
+	}
+	source, data, err := s.loadSourceImage(ctx, job, p)
+	if err != nil {
+		return nil, err
+	}
+	// 元数据中的尺寸可能缺失，以实际图片为准
+	cfg, err := utils.DecodeImageConfig(data, source.ContentType)
+	if err != nil {
+		return nil, permanentJobError(errors.New("附件不是图片"))
+	}
+	if err := checkUpscaleSize(job.Type, cfg.Width, cfg.Height, p.Scale); err != nil {
+		return nil, permanentJobError(err)
+	}
+	report(10)
+
+	upscaled, err := s.images.Upscale(ctx, data, source.ContentType, p.Scale)
+	if err != nil {
+		return nil, imageError(err)
+	}
+	report(80)
+	return s.saveJobImages(ctx, job, memo.UserID, [][]byte{upscaled})
+}
+
+// imageError 图片服务不支持的操作和像素过多的原图不重试，其余错误按可恢复处理
+func imageError(err error) error {
+	if errors.Is(err, errImageUnsupported) || errors.Is(err, utils.ErrImageTooLarge) {
+		return permanentJobError(err)
+	}
+	return err
+}
+
+// saveJobImages 将生成的图片保存为备忘录附件，附件记在创建者 ownerID 名下。
+// 任一张保存失败时删除本次已保存的附件，避免重试后重复
+func (s *AIJobService) saveJobImages(ctx context.Context, job *models.AIJob, ownerID primitive.ObjectID, images [][]byte) (map[string]interface{}, error) {
+	saved := make([]*models.Attachment, 0, len(images))
+	ids := make([]string, 0, len(images))
+	for i, data := range images {
+		filename := fmt.Sprintf("%s-%s-%d.png", job.Type, job.ID.Hex(), i+1)
+		attachment, err := s.attachmentService.storeAttachment(ctx, ownerID, job.MemoID, filename, data)
+		if err != nil {
+			for _, a := range saved {
+				s.attachmentService.removeAttachment(context.Background(), a)
+			}
+			if err.Error() == "附件容量不足" || err.Error() == "附件大小超过限制" {
+				return nil, permanentJobError(err)
+			}
+			return nil, err
+		}
+		saved = append(saved, attachment)
+		ids = append(ids, attachment.ID.Hex())
+	}
+	return map[string]interface{}{
+		"attachments": ids,
+		"provider":    s.images.Name(),
+	}, nil
+}
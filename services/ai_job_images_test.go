@@ -0,0 +1,44 @@
+package services
+
+import (
+	"testing"
+
+	"mjbackend/config"
+	"mjbackend/models"
+)
+
+func TestImageJobCost(t *testing.T) {
+	saved := config.AppConfig
+	config.AppConfig = &config.Config{AIImageCost: 10}
+	defer func() { config.AppConfig = saved }()
+
+	attachmentID := "64b000000000000000000001"
+	tests := []struct {
+		name    string
+		jobType string
+		params  map[string]interface{}
+		want    int
+	}{
+		{name: "默认尺寸一张", jobType: models.AIJobImageGenerate, params: map[string]interface{}{"prompt": "猫"}, want: 10},
+		{name: "标准尺寸多张", jobType: models.AIJobImageGenerate, params: map[string]interface{}{"prompt": "猫", "n": 3}, want: 30},
+		{name: "小尺寸半价", jobType: models.AIJobImageGenerate, params: map[string]interface{}{"prompt": "猫", "size": "512x512"}, want: 5},
+		{name: "竖版1.5倍", jobType: models.AIJobImageGenerate, params: map[string]interface{}{"prompt": "猫", "size": "1024x1792", "n": 2}, want: 30},
+		{name: "横版1.5倍向上取整", jobType: models.AIJobImageGenerate, params: map[string]interface{}{"prompt": "猫", "size": "1792x1024"}, want: 15},
+		{name: "变体按张数", jobType: models.AIJobImageVariation, params: map[string]interface{}{"attachmentId": attachmentID, "n": 4}, want: 40},
+		{name: "变体默认一张", jobType: models.AIJobImageVariation, params: map[string]interface{}{"attachmentId": attachmentID}, want: 10},
+		{name: "放大2倍半价", jobType: models.AIJobImageUpscale, params: map[string]interface{}{"attachmentId": attachmentID}, want: 5},
+		{name: "放大4倍全价", jobType: models.AIJobImageUpscale, params: map[string]interface{}{"attachmentId": attachmentID, "scale": 4}, want: 10},
+		{name: "无效参数不计价", jobType: models.AIJobImageGenerate, params: map[string]interface{}{"prompt": ""}, want: 0},
+		{name: "不支持的尺寸不计价", jobType: models.AIJobImageGenerate, params: map[string]interface{}{"prompt": "猫", "size": "100x100"}, want: 0},
+		{name: "张数超限不计价", jobType: models.AIJobImageGenerate, params: map[string]interface{}{"prompt": "猫", "n": 5}, want: 0},
+		{name: "放大倍数无效不计价", jobType: models.AIJobImageUpscale, params: map[string]interface{}{"attachmentId": attachmentID, "scale": 3}, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := imageJobCost(tt.jobType)(tt.params); got != tt.want {
+				t.Errorf("imageJobCost(%s)(%v) = %d, want %d", tt.jobType, tt.params, got, tt.want)
+			}
+		})
+	}
+}
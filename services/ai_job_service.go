@@ -0,0 +1,249 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"mjbackend/database"
+	"mjbackend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// 每个用户排队和执行中的AI任务上限
+const maxActiveAIJobs = 20
+
+// 每次执行失败后最多重试的总次数（含首次执行）
+const aiJobMaxAttempts = 3
+
+// 排队超过该时长仍未完成的任务不再执行，预扣的期限略长于此，防止任务丢失时算力被永久占用
+const (
+	aiJobMaxAge      = 12 * time.Hour
+	aiJobHoldTimeout = aiJobMaxAge + time.Hour
+)
+
+var aiJobStatuses = map[string]bool{
+	models.AIJobQueued:    true,
+	models.AIJobRunning:   true,
+	models.AIJobSucceeded: true,
+	models.AIJobFailed:    true,
+	models.AIJobCanceled:  true,
+}
+
+type AIJobService struct {
+	memoService       *MemoService
+	currencyService   *CurrencyService
+	attachmentService *AttachmentService
+	images            ImageGenerator
+	handlers          map[string]aiJobHandler
+	queue             *leasedJobQueue
+}
+
+func NewAIJobService() *AIJobService {
+	s := &AIJobService{
+		memoService:       NewMemoService(),
+		currencyService:   NewCurrencyService(),
+		attachmentService: NewAttachmentService(),
+		images:            NewImageGenerator(),
+	}
+	s.handlers = s.registerHandlers()
+	s.queue = s.jobQueue()
+	return s
+}
+
+// JobCost 返回指定类型和参数的任务消耗的算力，类型不存在时返回 0
+func (s *AIJobService) JobCost(jobType string, params map[string]interface{}) int {
+	handler, ok := s.handlers[jobType]
+	if !ok {
+		return 0
+	}
+	return handler.cost(params)
+}
+
+// SubmitJob 校验并提交AI任务，提交时按任务类型预扣算力，余额不足时不创建任务
+func (s *AIJobService) SubmitJob(userID primitive.ObjectID, req *models.CreateAIJobRequest) (*models.AIJob, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	handler, ok := s.handlers[req.Type]
+	if !ok {
+		return nil, errors.New("不支持的任务类型")
+	}
+	memoID, err := primitive.ObjectIDFromHex(req.MemoID)
+	if err != nil {
+		return nil, errors.New("无效的备忘录ID")
+	}
+	memo, err := authorizeMemo(ctx, userID, memoID, models.RoleEditor)
+	if err != nil {
+		return nil, err
+	}
+	if err := handler.validate(ctx, memo, req.Params); err != nil {
+		return nil, err
+	}
+
+	collection := database.GetCollection("ai_jobs")
+	active, err := collection.CountDocuments(ctx, bson.M{
+		"user_id": userID,
+		"status":  bson.M{"$in": bson.A{models.AIJobQueued, models.AIJobRunning}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if active >= maxActiveAIJobs {
+		return nil, errors.New("进行中的AI任务过多")
+	}
+
+	now := time.Now()
+	job := &models.AIJob{
+		ID:          primitive.NewObjectID(),
+		UserID:      userID,
+		MemoID:      memoID,
+		Type:        req.Type,
+		Params:      req.Params,
+		Cost:        handler.cost(req.Params),
+		Status:      models.AIJobQueued,
+		MaxAttempts: aiJobMaxAttempts,
+		NextRunAt:   now,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	var hold *models.CurrencyHold
+	if job.Cost > 0 {
+		hold, err = s.currencyService.HoldBalance(ctx, userID, job.Cost, handler.reason, &memoID, aiJobHoldTimeout)
+		if err != nil {
+			return nil, err
+		}
+		job.HoldID = &hold.ID
+	}
+
+	if _, err := collection.InsertOne(ctx, job); err != nil {
+		if hold != nil {
+			if releaseErr := s.currencyService.ReleaseHold(context.Background(), hold); releaseErr != nil {
+				log.Printf("退回预扣 %s 失败: %v", hold.ID.Hex(), releaseErr)
+			}
+		}
+		return nil, err
+	}
+
+	Events.publishLocal(userID, models.EventAIJobUpdated, job)
+	wakeAIJobWorkers()
+	return job, nil
+}
+
+// ListJobs 获取当前用户最近的AI任务，可按状态和备忘录过滤
+func (s *AIJobService) ListJobs(userID primitive.ObjectID, query *models.AIJobListQuery) ([]models.AIJob, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"user_id": userID}
+	if query.Status != "" {
+		if !aiJobStatuses[query.Status] {
+			return nil, errors.New("无效的任务状态")
+		}
+		filter["status"] = query.Status
+	}
+	if query.MemoID != nil {
+		filter["memo_id"] = *query.MemoID
+	}
+
+	cursor, err := database.GetCollection("ai_jobs").Find(ctx, filter,
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(50))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	jobs := []models.AIJob{}
+	if err = cursor.All(ctx, &jobs); err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// GetJob 获取AI任务的状态和进度
+func (s *AIJobService) GetJob(userID, jobID primitive.ObjectID) (*models.AIJob, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return s.getJob(ctx, userID, jobID)
+}
+
+func (s *AIJobService) getJob(ctx context.Context, userID, jobID primitive.ObjectID) (*models.AIJob, error) {
+	var job models.AIJob
+	err := database.GetCollection("ai_jobs").FindOne(ctx, bson.M{"_id": jobID, "user_id": userID}).Decode(&job)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("AI任务不存在")
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+// CancelJob 取消AI任务：排队中的任务立即取消并退回算力，执行中的任务标记取消，由执行者中止后退回
+func (s *AIJobService) CancelJob(userID, jobID primitive.ObjectID) (*models.AIJob, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	collection := database.GetCollection("ai_jobs")
+	now := time.Now()
+	var job models.AIJob
+	err := collection.FindOneAndUpdate(ctx,
+		bson.M{"_id": jobID, "user_id": userID, "status": models.AIJobQueued},
+		bson.M{"$set": bson.M{"status": models.AIJobCanceled, "finished_at": now, "updated_at": now}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&job)
+	if err == nil {
+		s.settleJobHold(ctx, &job, false)
+		Events.publishLocal(userID, models.EventAIJobUpdated, job)
+		return &job, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+
+	err = collection.FindOneAndUpdate(ctx,
+		bson.M{"_id": jobID, "user_id": userID, "status": models.AIJobRunning},
+		bson.M{"$set": bson.M{"cancel_requested": true, "updated_at": now}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&job)
+	if err == nil {
+		Events.publishLocal(userID, models.EventAIJobUpdated, job)
+		return &job, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+
+	if _, err := s.getJob(ctx, userID, jobID); err != nil {
+		return nil, err
+	}
+	return nil, errors.New("AI任务已结束")
+}
+
+// settleJobHold 结算任务的预扣：capture 为 true 时确认扣费，否则退回
+func (s *AIJobService) settleJobHold(ctx context.Context, job *models.AIJob, capture bool) {
+	if job.HoldID == nil {
+		return
+	}
+	hold, err := s.currencyService.getHold(ctx, *job.HoldID)
+	if err != nil {
+		log.Printf("读取AI任务 %s 的预扣失败: %v", job.ID.Hex(), err)
+		return
+	}
+	if capture {
+		// 预扣已超时退回时任务结果仍然有效，只是不再扣费
+		err = s.currencyService.CaptureHold(ctx, hold)
+	} else {
+		err = s.currencyService.ReleaseHold(ctx, hold)
+	}
+	if err != nil {
+		log.Printf("结算AI任务 %s 的预扣失败: %v", job.ID.Hex(), err)
+	}
+}
@@ -0,0 +1,256 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+
+	"mjbackend/config"
+	"mjbackend/database"
+	"mjbackend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// 没有可执行任务时的轮询间隔，提交新任务时会立即唤醒
+	aiJobPollInterval = 5 * time.Second
+	// 任务租约时长，执行者崩溃后租约过期，任务由其他执行者接管
+	aiJobLeaseTTL = time.Minute
+	// 续租间隔，同时检查任务是否被请求取消
+	aiJobHeartbeat = 10 * time.Second
+	// 单次执行的超时时间
+	aiJobRunTimeout = 5 * time.Minute
+	// 重试退避的初始间隔和上限
+	aiJobRetryBase = 10 * time.Second
+	aiJobRetryMax  = 10 * time.Minute
+)
+
+// aiJobWake 提交任务后唤醒一个空闲的工作协程
+var aiJobWake = make(chan struct{}, 1)
+
+func wakeAIJobWorkers() {
+	select {
+	case aiJobWake <- struct{}{}:
+	default:
+	}
+}
+
+// StartAIJobWorkers 启动AI任务工作协程，多实例部署时各实例通过任务租约分担任务
+func StartAIJobWorkers() {
+	workers := config.AppConfig.AIJobWorkers
+	if workers <= 0 {
+		log.Println("AI任务工作协程数为 0，当前实例不执行AI任务")
+		return
+	}
+	service := NewAIJobService()
+	for i := 0; i < workers; i++ {
+		owner := fmt.Sprintf("%s-%d", instanceID, i)
+		go service.queue.work(owner, func(job interface{}) {
+			service.process(job.(*models.AIJob), owner)
+		})
+	}
+}
+
+// jobQueue 返回AI任务队列。排队任务到达 next_run_at 后可以租用，执行次数达到任务的 max_attempts 后不再租用
+func (s *AIJobService) jobQueue() *leasedJobQueue {
+	return &leasedJobQueue{
+		collection: "ai_jobs",
+		label:      "AI任务",
+		running:    models.AIJobRunning,
+		failed:     models.AIJobFailed,
+		ready: func(now time.Time) bson.M {
+			return bson.M{"status": models.AIJobQueued, "next_run_at": bson.M{"$lte": now}}
+		},
+		remaining:      bson.M{"$expr": bson.M{"$lt": bson.A{"$attempts", "$max_attempts"}}},
+		exhausted:      bson.M{"$expr": bson.M{"$gte": bson.A{"$attempts", "$max_attempts"}}},
+		exhaustedError: "任务多次执行中断",
+		sort:           bson.D{{Key: "next_run_at", Value: 1}},
+		leaseTTL:       aiJobLeaseTTL,
+		heartbeat:      aiJobHeartbeat,
+		pollInterval:   aiJobPollInterval,
+		wake:           aiJobWake,
+		leaseSet: func(now time.Time) bson.M {
+			return bson.M{"started_at": now, "updated_at": now}
+		},
+		failSet: func(now time.Time) bson.M {
+			return bson.M{"updated_at": now}
+		},
+		newJob: func() interface{} { return &models.AIJob{} },
+		onExhausted: func(ctx context.Context, job interface{}) {
+			// 执行中崩溃的任务不会被确认扣费，退回算力
+			failed := job.(*models.AIJob)
+			log.Printf("AI任务 %s 已执行 %d 次均中断，标记为失败", failed.ID.Hex(), failed.Attempts)
+			s.settleJobHold(ctx, failed, false)
+			Events.publishLocal(failed.UserID, models.EventAIJobUpdated, failed)
+		},
+	}
+}
+
+// process 执行已租用的任务，并根据结果确认扣费、安排重试或退回算力
+func (s *AIJobService) process(job *models.AIJob, owner string) {
+	Events.publishLocal(job.UserID, models.EventAIJobUpdated, job)
+
+	switch {
+	case job.CancelRequested:
+		s.finishJob(job, owner, models.AIJobCanceled, nil, "")
+		return
+	case time.Since(job.CreatedAt) > aiJobMaxAge:
+		s.finishJob(job, owner, models.AIJobFailed, nil, "任务超时")
+		return
+	}
+	handler, ok := s.handlers[job.Type]
+	if !ok {
+		s.finishJob(job, owner, models.AIJobFailed, nil, "不支持的任务类型")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), aiJobRunTimeout)
+	defer cancel()
+
+	// 续租时检查任务是否被请求取消
+	var canceled atomic.Bool
+	done := make(chan struct{})
+	go s.queue.keepLease(job.ID, owner, cancel, done, func(current interface{}) bool {
+		if current.(*models.AIJob).CancelRequested {
+			canceled.Store(true)
+			return true
+		}
+		return false
+	})
+
+	report := func(progress int) {
+		s.reportProgress(ctx, job, owner, progress)
+	}
+	result, err := runJobHandler(ctx, handler, job, report)
+	close(done)
+
+	var permanent *aiJobError
+	switch {
+	case err == nil:
+		s.finishJob(job, owner, models.AIJobSucceeded, result, "")
+	case canceled.Load():
+		s.finishJob(job, owner, models.AIJobCanceled, nil, "")
+	case errors.As(err, &permanent):
+		s.finishJob(job, owner, models.AIJobFailed, nil, err.Error())
+	case job.Attempts >= job.MaxAttempts:
+		log.Printf("AI任务 %s 第 %d 次执行失败，不再重试: %v", job.ID.Hex(), job.Attempts, err)
+		s.finishJob(job, owner, models.AIJobFailed, nil, err.Error())
+	default:
+		log.Printf("AI任务 %s 第 %d 次执行失败，稍后重试: %v", job.ID.Hex(), job.Attempts, err)
+		s.retryJob(job, owner, err.Error())
+	}
+}
+
+// runJobHandler 执行任务，处理函数 panic 时转为不可重试的错误，由调用方将任务标记为失败并退回算力
+func runJobHandler(ctx context.Context, handler aiJobHandler, job *models.AIJob, report func(int)) (result map[string]interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("AI任务 %s 执行时发生 panic: %v\n%s", job.ID.Hex(), r, debug.Stack())
+			result, err = nil, permanentJobError(errors.New("任务执行异常"))
+		}
+	}()
+	return handler.run(ctx, job, report)
+}
+
+func (s *AIJobService) reportProgress(ctx context.Context, job *models.AIJob, owner string, progress int) {
+	if progress < 0 {
+		progress = 0
+	}
+	if progress > 99 {
+		progress = 99
+	}
+	var updated models.AIJob
+	err := database.GetCollection("ai_jobs").FindOneAndUpdate(ctx,
+		s.queue.heldBy(job.ID, owner),
+		bson.M{"$set": bson.M{"progress": progress, "updated_at": time.Now()}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+	if err != nil {
+		if err != mongo.ErrNoDocuments {
+			log.Printf("更新AI任务 %s 进度失败: %v", job.ID.Hex(), err)
+		}
+		return
+	}
+	Events.publishLocal(job.UserID, models.EventAIJobUpdated, updated)
+}
+
+// finishJob 以租约持有者身份结束任务并结算预扣，租约已被其他执行者接管时放弃
+func (s *AIJobService) finishJob(job *models.AIJob, owner, status string, result map[string]interface{}, message string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	set := bson.M{"status": status, "finished_at": now, "updated_at": now}
+	unset := bson.M{"lease_owner": "", "lease_expires_at": ""}
+	if status == models.AIJobSucceeded {
+		set["progress"] = 100
+		set["result"] = result
+		unset["error"] = ""
+	} else if message != "" {
+		set["error"] = message
+	}
+
+	var updated models.AIJob
+	err := database.GetCollection("ai_jobs").FindOneAndUpdate(ctx,
+		s.queue.heldBy(job.ID, owner),
+		bson.M{"$set": set, "$unset": unset},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+	if err != nil {
+		if err != mongo.ErrNoDocuments {
+			log.Printf("更新AI任务 %s 状态失败: %v", job.ID.Hex(), err)
+		}
+		return
+	}
+
+	s.settleJobHold(ctx, &updated, status == models.AIJobSucceeded)
+	Events.publishLocal(updated.UserID, models.EventAIJobUpdated, updated)
+}
+
+// retryJob 释放租约并按指数退避安排下一次执行
+func (s *AIJobService) retryJob(job *models.AIJob, owner, message string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	var updated models.AIJob
+	err := database.GetCollection("ai_jobs").FindOneAndUpdate(ctx,
+		s.queue.heldBy(job.ID, owner),
+		bson.M{
+			"$set": bson.M{
+				"status":      models.AIJobQueued,
+				"next_run_at": now.Add(aiJobRetryDelay(job.Attempts)),
+				"error":       message,
+				"updated_at":  now,
+			},
+			"$unset": bson.M{"lease_owner": "", "lease_expires_at": ""},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+	if err != nil {
+		if err != mongo.ErrNoDocuments {
+			log.Printf("更新AI任务 %s 状态失败: %v", job.ID.Hex(), err)
+		}
+		return
+	}
+	Events.publishLocal(updated.UserID, models.EventAIJobUpdated, updated)
+}
+
+// aiJobRetryDelay 第 attempts 次执行失败后的等待时间
+func aiJobRetryDelay(attempts int) time.Duration {
+	delay := aiJobRetryBase
+	for i := 1; i < attempts && delay < aiJobRetryMax; i++ {
+		delay *= 2
+	}
+	if delay > aiJobRetryMax {
+		delay = aiJobRetryMax
+	}
+	return delay
+}
@@ -0,0 +1,27 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAIJobRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 0, want: aiJobRetryBase},
+		{attempts: 1, want: aiJobRetryBase},
+		{attempts: 2, want: 2 * aiJobRetryBase},
+		{attempts: 3, want: 4 * aiJobRetryBase},
+		{attempts: 6, want: 32 * aiJobRetryBase},
+		{attempts: 7, want: aiJobRetryMax},
+		{attempts: 100, want: aiJobRetryMax},
+	}
+
+	for _, tt := range tests {
+		if got := aiJobRetryDelay(tt.attempts); got != tt.want {
+			t.Errorf("aiJobRetryDelay(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
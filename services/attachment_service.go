@@ -0,0 +1,316 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"mjbackend/config"
+	"mjbackend/database"
+	"mjbackend/models"
+	"mjbackend/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// 缩略图最长边像素数
+const thumbnailSize = 256
+
+type AttachmentService struct {
+	storage BlobStorage
+}
+
+func NewAttachmentService() *AttachmentService {
+	return &AttachmentService{
+		storage: NewBlobStorage("attachments", config.AppConfig.AttachmentLocalDir),
+	}
+}
+
+// 上传附件，需要编辑及以上权限。文件类型由内容嗅探决定，图片会同时生成缩略图，
+// 附件记在备忘录创建者名下并占用其容量
+func (s *AttachmentService) UploadAttachment(userID, memoID primitive.ObjectID, filename string, r io.Reader) (*models.Attachment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	memo, err := authorizeMemo(ctx, userID, memoID, models.RoleEditor)
+	if err != nil {
+		return nil, err
+	}
+
+	// 多读一个字节用于判断是否超出大小限制
+	maxBytes := int64(config.AppConfig.AttachmentMaxSizeMB) << 20
+	data, err := io.ReadAll(io.LimitReader(r, maxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxBytes {
+		return nil, errors.New("附件大小超过限制")
+	}
+	if len(data) == 0 {
+		return nil, errors.New("附件内容不能为空")
+	}
+
+	return s.storeAttachment(ctx, memo.UserID, memoID, filename, data)
+}
+
+// storeAttachment 检查大小和 ownerID 的容量后保存附件内容、缩略图和元数据
+func (s *AttachmentService) storeAttachment(ctx context.Context, ownerID, memoID primitive.ObjectID, filename string, data []byte) (*models.Attachment, error) {
+	if int64(len(data)) > int64(config.AppConfig.AttachmentMaxSizeMB)<<20 {
+		return nil, errors.New("附件大小超过限制")
+	}
+
+	used, err := s.GetUsage(ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if used+int64(len(data)) > int64(config.AppConfig.AttachmentQuotaMB)<<20 {
+		return nil, errors.New("附件容量不足")
+	}
+
+	attachment := &models.Attachment{
+		ID:          primitive.NewObjectID(),
+		UserID:      ownerID,
+		MemoID:      memoID,
+		Filename:    filepath.Base(filename),
+		ContentType: http.DetectContentType(data),
+		Size:        int64(len(data)),
+		CreatedAt:   time.Now(),
+	}
+
+	attachment.StorageKey, err = s.storage.Save(ctx, attachment.Filename, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	if utils.ThumbnailSupported(attachment.ContentType) {
+		s.saveThumbnail(ctx, attachment, data)
+	}
+
+	if _, err = database.GetCollection("attachments").InsertOne(ctx, attachment); err != nil {
+		s.deleteBlobs(ctx, attachment)
+		return nil, err
+	}
+
+	return attachment, nil
+}
+
+// 生成并保存缩略图，失败时仅记录日志，不影响附件上传
+func (s *AttachmentService) saveThumbnail(ctx context.Context, attachment *models.Attachment, data []byte) {
+	thumb, thumbType, width, height, err := utils.MakeThumbnail(data, attachment.ContentType, thumbnailSize)
+	if err != nil {
+		log.Printf("生成附件 %s 缩略图失败: %v", attachment.ID.Hex(), err)
+		return
+	}
+
+	key, err := s.storage.Save(ctx, "thumb_"+attachment.Filename, bytes.NewReader(thumb))
+	if err != nil {
+		log.Printf("保存附件 %s 缩略图失败: %v", attachment.ID.Hex(), err)
+		return
+	}
+
+	attachment.ThumbnailKey = key
+	attachment.ThumbnailType = thumbType
+	attachment.HasThumbnail = true
+	attachment.Width = width
+	attachment.Height = height
+}
+
+// readImage 读取备忘录中的图片附件内容，调用方负责校验权限
+func (s *AttachmentService) readImage(ctx context.Context, memoID, attachmentID primitive.ObjectID) (*models.Attachment, []byte, error) {
+	attachment, err := s.getAttachment(ctx, memoID, attachmentID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !utils.ThumbnailSupported(attachment.ContentType) {
+		return nil, nil, errors.New("附件不是图片")
+	}
+
+	reader, err := s.storage.Open(ctx, attachment.StorageKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	return attachment, data, nil
+}
+
+// 获取备忘录的附件列表，创建者和协作者均可查看
+func (s *AttachmentService) ListAttachments(userID, memoID primitive.ObjectID) ([]models.Attachment, error) {
+	collection := database.GetCollection("attachments")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := authorizeMemo(ctx, userID, memoID, models.RoleViewer); err != nil {
+		return nil, err
+	}
+
+	cursor, err := collection.Find(ctx, bson.M{"memo_id": memoID},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	attachments := []models.Attachment{}
+	if err = cursor.All(ctx, &attachments); err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
+
+// 打开附件或其缩略图，创建者和协作者均可下载，调用方负责关闭返回的读取器
+func (s *AttachmentService) OpenAttachment(userID, memoID, attachmentID primitive.ObjectID, thumbnail bool) (*models.Attachment, io.ReadCloser, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := authorizeMemo(ctx, userID, memoID, models.RoleViewer); err != nil {
+		return nil, nil, err
+	}
+	attachment, err := s.getAttachment(ctx, memoID, attachmentID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key := attachment.StorageKey
+	if thumbnail {
+		if !attachment.HasThumbnail {
+			return nil, nil, errors.New("附件没有缩略图")
+		}
+		key = attachment.ThumbnailKey
+	}
+
+	reader, err := s.storage.Open(context.Background(), key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return attachment, reader, nil
+}
+
+// 删除附件，需要编辑及以上权限
+func (s *AttachmentService) DeleteAttachment(userID, memoID, attachmentID primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := authorizeMemo(ctx, userID, memoID, models.RoleEditor); err != nil {
+		return err
+	}
+	attachment, err := s.getAttachment(ctx, memoID, attachmentID)
+	if err != nil {
+		return err
+	}
+
+	return s.removeAttachment(ctx, attachment)
+}
+
+// removeAttachment 删除附件元数据和文件内容
+func (s *AttachmentService) removeAttachment(ctx context.Context, attachment *models.Attachment) error {
+	if _, err := database.GetCollection("attachments").DeleteOne(ctx, bson.M{"_id": attachment.ID}); err != nil {
+		return err
+	}
+	s.deleteBlobs(ctx, attachment)
+	return nil
+}
+
+// 删除备忘录的全部附件，用于彻底删除备忘录
+func (s *AttachmentService) DeleteMemoAttachments(memoID primitive.ObjectID) error {
+	collection := database.GetCollection("attachments")
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	cursor, err := collection.Find(ctx, bson.M{"memo_id": memoID})
+	if err != nil {
+		return err
+	}
+	var attachments []models.Attachment
+	if err = cursor.All(ctx, &attachments); err != nil {
+		return err
+	}
+
+	if _, err = collection.DeleteMany(ctx, bson.M{"memo_id": memoID}); err != nil {
+		return err
+	}
+	for i := range attachments {
+		s.deleteBlobs(ctx, &attachments[i])
+	}
+	return nil
+}
+
+// GetMemoOwnerUsage 获取备忘录创建者已使用的附件容量（字节），协作者上传的附件同样计入创建者
+func (s *AttachmentService) GetMemoOwnerUsage(memoID primitive.ObjectID) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var memo models.Memo
+	err := database.GetCollection("memos").FindOne(ctx, bson.M{"_id": memoID},
+		options.FindOne().SetProjection(bson.M{"user_id": 1})).Decode(&memo)
+	if err != nil {
+		return 0, err
+	}
+	return s.GetUsage(memo.UserID)
+}
+
+// 获取用户已使用的附件容量（字节）
+func (s *AttachmentService) GetUsage(userID primitive.ObjectID) (int64, error) {
+	collection := database.GetCollection("attachments")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"user_id": userID}}},
+		{{Key: "$group", Value: bson.M{"_id": nil, "total": bson.M{"$sum": "$size"}}}},
+	}
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var result []struct {
+		Total int64 `bson:"total"`
+	}
+	if err = cursor.All(ctx, &result); err != nil {
+		return 0, err
+	}
+	if len(result) == 0 {
+		return 0, nil
+	}
+	return result[0].Total, nil
+}
+
+func (s *AttachmentService) getAttachment(ctx context.Context, memoID, attachmentID primitive.ObjectID) (*models.Attachment, error) {
+	var attachment models.Attachment
+	err := database.GetCollection("attachments").FindOne(ctx, bson.M{
+		"_id":     attachmentID,
+		"memo_id": memoID,
+	}).Decode(&attachment)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("附件不存在")
+		}
+		return nil, err
+	}
+	return &attachment, nil
+}
+
+// 删除附件及缩略图的文件内容，失败时仅记录日志
+func (s *AttachmentService) deleteBlobs(ctx context.Context, attachment *models.Attachment) {
+	for _, key := range []string{attachment.StorageKey, attachment.ThumbnailKey} {
+		if key == "" {
+			continue
+		}
+		if err := s.storage.Delete(ctx, key); err != nil {
+			log.Printf("删除附件 %s 的文件 %s 失败: %v", attachment.ID.Hex(), key, err)
+		}
+	}
+}
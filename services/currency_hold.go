@@ -0,0 +1,300 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"mjbackend/database"
+	"mjbackend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// 同步调用的计费功能预扣未确认时自动退回的期限，应远大于功能的执行时间
+const holdTimeout = 10 * time.Minute
+
+// releasing 状态的预扣超过该时长未完成时视为退回中断，由超时任务重试
+const holdReleaseRetry = time.Minute
+
+// HoldBalance 预扣算力：余额足够时立即扣除并记录 hold 交易，
+// 调用方在功能成功后调用 CaptureHold 确认，失败时调用 ReleaseHold 退回，超过 ttl 未确认时自动退回
+func (s *CurrencyService) HoldBalance(ctx context.Context, userID primitive.ObjectID, amount int, reason string, memoID *primitive.ObjectID, ttl time.Duration) (*models.CurrencyHold, error) {
+	balanceCollection := database.GetCollection("currency_balances")
+
+	// 以余额作为条件原子扣减，并发预扣不会扣成负数
+	var balance models.CurrencyBalance
+	now := time.Now()
+	err := balanceCollection.FindOneAndUpdate(ctx,
+		bson.M{"user_id": userID, "balance": bson.M{"$gte": amount}},
+		bson.M{
+			"$inc": bson.M{"balance": -amount},
+			"$set": bson.M{"last_update_time": now, "updated_at": now},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&balance)
+	if err == mongo.ErrNoDocuments {
+		current, err := s.GetBalance(userID)
+		if err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("算力余额不足，当前余额: %d，需要: %d", current.Balance, amount)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("更新用户余额失败: %v", err)
+	}
+
+	hold := &models.CurrencyHold{
+		ID:            primitive.NewObjectID(),
+		UserID:        userID,
+		Amount:        amount,
+		Reason:        reason,
+		MemoID:        memoID,
+		Status:        models.HoldPending,
+		TransactionID: fmt.Sprintf("tx_%d", time.Now().UnixNano()),
+		CreatedAt:     now,
+		ExpiresAt:     now.Add(ttl),
+	}
+	kept, err := insertHoldRecords(ctx, hold)
+	if err != nil {
+		if kept {
+			// 预扣记录未能删除，由超时任务退回，这里再退回会重复
+			return nil, err
+		}
+		// 记录写入失败时退回已扣除的余额
+		if _, refundErr := balanceCollection.UpdateOne(context.Background(), bson.M{"user_id": userID},
+			bson.M{"$inc": bson.M{"balance": amount}}); refundErr != nil {
+			log.Printf("退回用户 %s 的预扣算力 %d 失败: %v", userID.Hex(), amount, refundErr)
+		}
+		return nil, err
+	}
+
+	Events.publishLocal(userID, models.EventBalanceChanged, models.BalanceResponse{
+		Balance:        balance.Balance,
+		LastUpdateTime: now,
+	})
+	return hold, nil
+}
+
+// insertHoldRecords 写入预扣记录和 hold 交易记录。交易记录写入失败时删除预扣记录，
+// 删除也失败时 kept 为 true，预扣记录仍然有效，超时后会自动退回
+func insertHoldRecords(ctx context.Context, hold *models.CurrencyHold) (kept bool, err error) {
+	if _, err := database.GetCollection("currency_holds").InsertOne(ctx, hold); err != nil {
+		return false, fmt.Errorf("创建预扣记录失败: %v", err)
+	}
+	_, err = database.GetCollection("currency_transactions").InsertOne(ctx, models.CurrencyTransaction{
+		UserID:        hold.UserID,
+		Type:          models.TransactionHold,
+		Amount:        hold.Amount,
+		Reason:        hold.Reason,
+		MemoID:        hold.MemoID,
+		TransactionID: hold.TransactionID,
+		CreatedAt:     hold.CreatedAt,
+	})
+	if err != nil {
+		if _, deleteErr := database.GetCollection("currency_holds").DeleteOne(context.Background(), bson.M{"_id": hold.ID}); deleteErr != nil {
+			log.Printf("删除预扣记录 %s 失败，将在超时后退回: %v", hold.ID.Hex(), deleteErr)
+			return true, fmt.Errorf("创建交易记录失败: %v", err)
+		}
+		return false, fmt.Errorf("创建交易记录失败: %v", err)
+	}
+	return false, nil
+}
+
+// getHold 按ID读取预扣记录
+func (s *CurrencyService) getHold(ctx context.Context, holdID primitive.ObjectID) (*models.CurrencyHold, error) {
+	var hold models.CurrencyHold
+	err := database.GetCollection("currency_holds").FindOne(ctx, bson.M{"_id": holdID}).Decode(&hold)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("算力预扣不存在")
+		}
+		return nil, err
+	}
+	return &hold, nil
+}
+
+// CaptureHold 确认预扣，对应的交易记录改为 deduct。预扣已超时退回时返回错误
+func (s *CurrencyService) CaptureHold(ctx context.Context, hold *models.CurrencyHold) error {
+	now := time.Now()
+	result, err := database.GetCollection("currency_holds").UpdateOne(ctx,
+		bson.M{"_id": hold.ID, "status": models.HoldPending},
+		bson.M{"$set": bson.M{"status": models.HoldCaptured, "settled_at": now}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errors.New("算力预扣已失效")
+	}
+
+	_, err = database.GetCollection("currency_transactions").UpdateOne(ctx,
+		bson.M{"transaction_id": hold.TransactionID},
+		bson.M{"$set": bson.M{"type": models.TransactionDeduct}},
+	)
+	if err != nil {
+		log.Printf("更新交易记录 %s 失败: %v", hold.TransactionID, err)
+	}
+	hold.Status = models.HoldCaptured
+	hold.SettledAt = &now
+	return nil
+}
+
+// ReleaseHold 退回预扣的算力并记录 refund 交易，已确认或已退回的预扣不重复处理。
+// 预扣先改为 releasing 再退回余额，中途失败时由超时任务继续完成，已扣的算力不会丢失
+func (s *CurrencyService) ReleaseHold(ctx context.Context, hold *models.CurrencyHold) error {
+	now := time.Now()
+	result, err := database.GetCollection("currency_holds").UpdateOne(ctx,
+		bson.M{"_id": hold.ID, "status": models.HoldPending},
+		bson.M{"$set": bson.M{"status": models.HoldReleasing, "releasing_at": now}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return nil
+	}
+	return s.finishRelease(ctx, hold)
+}
+
+// finishRelease 完成 releasing 状态的预扣。退回余额时把预扣ID记入余额的 pending_refunds，
+// 以此为条件退回，重试时已退回的预扣不会再次退回；标记为 released 后再从中移除
+func (s *CurrencyService) finishRelease(ctx context.Context, hold *models.CurrencyHold) error {
+	now := time.Now()
+	balanceCollection := database.GetCollection("currency_balances")
+
+	var balance models.CurrencyBalance
+	refunded := true
+	err := balanceCollection.FindOneAndUpdate(ctx,
+		bson.M{"user_id": hold.UserID, "pending_refunds": bson.M{"$ne": hold.ID}},
+		bson.M{
+			"$inc":      bson.M{"balance": hold.Amount},
+			"$set":      bson.M{"last_update_time": now, "updated_at": now},
+			"$addToSet": bson.M{"pending_refunds": hold.ID},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&balance)
+	if err == mongo.ErrNoDocuments {
+		// 上次重试已退回余额，只需完成后续步骤
+		refunded = false
+	} else if err != nil {
+		return fmt.Errorf("退回预扣算力失败: %v", err)
+	}
+
+	if refunded {
+		_, err = database.GetCollection("currency_transactions").InsertOne(ctx, models.CurrencyTransaction{
+			UserID:        hold.UserID,
+			Type:          models.TransactionRefund,
+			Amount:        hold.Amount,
+			Reason:        "退回 - " + hold.Reason,
+			MemoID:        hold.MemoID,
+			TransactionID: fmt.Sprintf("tx_%d", time.Now().UnixNano()),
+			CreatedAt:     now,
+		})
+		if err != nil {
+			log.Printf("记录用户 %s 的退回交易失败: %v", hold.UserID.Hex(), err)
+		}
+	}
+
+	_, err = database.GetCollection("currency_holds").UpdateOne(ctx,
+		bson.M{"_id": hold.ID, "status": models.HoldReleasing},
+		bson.M{"$set": bson.M{"status": models.HoldReleased, "settled_at": now}},
+	)
+	if err != nil {
+		return fmt.Errorf("更新预扣状态失败: %v", err)
+	}
+	// 移除失败只会在余额中留下一个不再使用的ID
+	if _, err := balanceCollection.UpdateOne(ctx,
+		bson.M{"user_id": hold.UserID},
+		bson.M{"$pull": bson.M{"pending_refunds": hold.ID}},
+	); err != nil {
+		log.Printf("清除用户 %s 的退回标记失败: %v", hold.UserID.Hex(), err)
+	}
+
+	hold.Status = models.HoldReleased
+	hold.SettledAt = &now
+
+	if refunded {
+		Events.publishLocal(hold.UserID, models.EventBalanceChanged, models.BalanceResponse{
+			Balance:        balance.Balance,
+			LastUpdateTime: now,
+		})
+	}
+	return nil
+}
+
+// StartHoldExpiry 启动超时预扣的退回任务，防止进程在确认前退出导致算力被永久占用
+func StartHoldExpiry() {
+	service := NewCurrencyService()
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for {
+			service.releaseExpiredHolds()
+			<-ticker.C
+		}
+	}()
+}
+
+func (s *CurrencyService) releaseExpiredHolds() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	cursor, err := database.GetCollection("currency_holds").Find(ctx, bson.M{
+		"status":     models.HoldPending,
+		"expires_at": bson.M{"$lt": time.Now()},
+	}, options.Find().SetLimit(100))
+	if err != nil {
+		log.Printf("查询超时预扣失败: %v", err)
+		return
+	}
+	var holds []models.CurrencyHold
+	if err := cursor.All(ctx, &holds); err != nil {
+		log.Printf("读取超时预扣失败: %v", err)
+		return
+	}
+	for i := range holds {
+		if err := s.ReleaseHold(ctx, &holds[i]); err != nil {
+			log.Printf("退回超时预扣 %s 失败: %v", holds[i].ID.Hex(), err)
+		}
+	}
+
+	s.retryStalledReleases(ctx)
+}
+
+// retryStalledReleases 继续完成中断的退回。先以条件更新刷新 releasing_at 认领，
+// 多个实例同时执行时同一预扣只由一个实例重试
+func (s *CurrencyService) retryStalledReleases(ctx context.Context) {
+	collection := database.GetCollection("currency_holds")
+	cursor, err := collection.Find(ctx, bson.M{
+		"status":       models.HoldReleasing,
+		"releasing_at": bson.M{"$lt": time.Now().Add(-holdReleaseRetry)},
+	}, options.Find().SetLimit(100))
+	if err != nil {
+		log.Printf("查询中断的预扣退回失败: %v", err)
+		return
+	}
+	var holds []models.CurrencyHold
+	if err := cursor.All(ctx, &holds); err != nil {
+		log.Printf("读取中断的预扣退回失败: %v", err)
+		return
+	}
+	for i := range holds {
+		hold := &holds[i]
+		result, err := collection.UpdateOne(ctx,
+			bson.M{"_id": hold.ID, "status": models.HoldReleasing, "releasing_at": hold.ReleasingAt},
+			bson.M{"$set": bson.M{"releasing_at": time.Now()}},
+		)
+		if err != nil || result.MatchedCount == 0 {
+			continue
+		}
+		if err := s.finishRelease(ctx, hold); err != nil {
+			log.Printf("重试退回预扣 %s 失败: %v", hold.ID.Hex(), err)
+		}
+	}
+}
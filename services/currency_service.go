@@ -125,6 +125,10 @@ func (s *CurrencyService) DeductBalance(userID primitive.ObjectID, request *mode
 		return nil, err
 	}
 	
+	Events.publishLocal(userID, models.EventBalanceChanged, models.BalanceResponse{
+		Balance:        result.RemainingBalance,
+		LastUpdateTime: time.Now(),
+	})
 	return result, nil
 }
 
@@ -223,5 +227,9 @@ func (s *CurrencyService) RechargeBalance(userID primitive.ObjectID, request *mo
 		return nil, err
 	}
 	
+	Events.publishLocal(userID, models.EventBalanceChanged, models.BalanceResponse{
+		Balance:        result.NewBalance,
+		LastUpdateTime: time.Now(),
+	})
 	return result, nil
 }
\ No newline at end of file
@@ -0,0 +1,178 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"mjbackend/config"
+	"mjbackend/utils"
+)
+
+// 提交给向量模型的文本上限（字符），超出部分截断
+const maxEmbeddingInput = 8000
+
+// 本地哈希向量的维度
+const hashingDimensions = 256
+
+// EmbeddingProvider 文本向量模型，Name 记录在向量中，模型变更后旧向量需要重建。
+// 返回的向量均已归一化，余弦相似度即为点积
+type EmbeddingProvider interface {
+	Name() string
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// NewEmbeddingProvider 根据配置创建向量模型：stub（本地特征哈希，用于开发和测试）或 openai（兼容 OpenAI 的接口）
+func NewEmbeddingProvider() EmbeddingProvider {
+	switch config.AppConfig.EmbeddingProvider {
+	case "openai":
+		if config.AppConfig.AIAPIKey == "" {
+			log.Println("未配置 AI_API_KEY，使用本地哈希向量")
+			return newHashingEmbedder()
+		}
+		return &OpenAIEmbedder{
+			baseURL: strings.TrimRight(config.AppConfig.AIBaseURL, "/"),
+			apiKey:  config.AppConfig.AIAPIKey,
+			model:   config.AppConfig.EmbeddingModel,
+			client:  &http.Client{Timeout: 30 * time.Second},
+		}
+	default:
+		return newHashingEmbedder()
+	}
+}
+
+// HashingEmbedder 将分词结果按特征哈希映射到固定维度，不调用外部服务。
+// 只能匹配字面上相近的文本，但结果确定，适合测试
+type HashingEmbedder struct {
+	analyzer utils.Analyzer
+}
+
+func newHashingEmbedder() *HashingEmbedder {
+	return &HashingEmbedder{analyzer: utils.CJKAnalyzer{}}
+}
+
+func (e *HashingEmbedder) Name() string { return fmt.Sprintf("hash-%d", hashingDimensions) }
+
+func (e *HashingEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vector := make([]float32, hashingDimensions)
+		for _, token := range e.analyzer.IndexTokens(text) {
+			h := fnv.New32a()
+			h.Write([]byte(token))
+			sum := h.Sum32()
+			// 最高位决定符号，减少哈希冲突带来的偏差
+			if sum&(1<<31) != 0 {
+				vector[sum%hashingDimensions]--
+			} else {
+				vector[sum%hashingDimensions]++
+			}
+		}
+		vectors[i] = normalizeVector(vector)
+	}
+	return vectors, nil
+}
+
+// OpenAIEmbedder 调用兼容 OpenAI Embeddings 的接口
+type OpenAIEmbedder struct {
+	baseURL string
+	apiKey  string
+	model   string
+	client  *http.Client
+}
+
+type embeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type embeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (e *OpenAIEmbedder) Name() string { return "openai:" + e.model }
+
+func (e *OpenAIEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	body, err := json.Marshal(embeddingRequest{Model: e.model, Input: texts})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.baseURL+"/embeddings", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+e.apiKey)
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result embeddingResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("向量服务返回状态码 %d", resp.StatusCode)
+	}
+	if resp.StatusCode >= 300 {
+		if result.Error != nil {
+			return nil, fmt.Errorf("向量服务返回错误: %s", result.Error.Message)
+		}
+		return nil, fmt.Errorf("向量服务返回状态码 %d", resp.StatusCode)
+	}
+	if len(result.Data) != len(texts) {
+		return nil, errors.New("向量服务返回的结果数量不匹配")
+	}
+
+	vectors := make([][]float32, len(texts))
+	for _, item := range result.Data {
+		if item.Index < 0 || item.Index >= len(texts) {
+			return nil, errors.New("向量服务返回的结果序号无效")
+		}
+		vectors[item.Index] = normalizeVector(item.Embedding)
+	}
+	return vectors, nil
+}
+
+// normalizeVector 将向量缩放为单位长度，零向量原样返回
+func normalizeVector(vector []float32) []float32 {
+	var sum float64
+	for _, v := range vector {
+		sum += float64(v) * float64(v)
+	}
+	if sum == 0 {
+		return vector
+	}
+	norm := float32(math.Sqrt(sum))
+	for i := range vector {
+		vector[i] /= norm
+	}
+	return vector
+}
+
+// dotProduct 计算两个向量的点积，维度不同时返回 0
+func dotProduct(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var sum float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
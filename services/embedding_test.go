@@ -0,0 +1,47 @@
+package services
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+func TestHashingEmbedder(t *testing.T) {
+	embedder := newHashingEmbedder()
+	vectors, err := embedder.Embed(context.Background(), []string{
+		"周末去超市买牛奶",
+		"周末去超市买牛奶",
+		"项目周会纪要",
+		"",
+	})
+	if err != nil {
+		t.Fatalf("Embed 返回错误: %v", err)
+	}
+	if len(vectors) != 4 {
+		t.Fatalf("期望 4 个向量，得到 %d", len(vectors))
+	}
+	for i, vector := range vectors[:3] {
+		if len(vector) != hashingDimensions {
+			t.Fatalf("向量 %d 维度为 %d，期望 %d", i, len(vector), hashingDimensions)
+		}
+		if norm := dotProduct(vector, vector); math.Abs(norm-1) > 1e-5 {
+			t.Errorf("向量 %d 未归一化: %v", i, norm)
+		}
+	}
+
+	if sim := dotProduct(vectors[0], vectors[1]); math.Abs(sim-1) > 1e-5 {
+		t.Errorf("相同文本的相似度应为 1，得到 %v", sim)
+	}
+	if same, other := dotProduct(vectors[0], vectors[1]), dotProduct(vectors[0], vectors[2]); other >= same {
+		t.Errorf("不相关文本的相似度 %v 不应高于相同文本 %v", other, same)
+	}
+	if norm := dotProduct(vectors[3], vectors[3]); norm != 0 {
+		t.Errorf("空文本应得到零向量，得到模长平方 %v", norm)
+	}
+}
+
+func TestDotProductDimensionMismatch(t *testing.T) {
+	if got := dotProduct([]float32{1, 0}, []float32{1}); got != 0 {
+		t.Errorf("维度不同应返回 0，得到 %v", got)
+	}
+}
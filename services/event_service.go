@@ -0,0 +1,250 @@
+package services
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"mjbackend/config"
+	"mjbackend/database"
+	"mjbackend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// 每个订阅者的事件缓冲区大小，缓冲区满时丢弃新事件以免阻塞写操作
+const eventBufferSize = 64
+
+// EventBus 进程内事件总线，按用户分发实时事件
+type EventBus struct {
+	mu          sync.RWMutex
+	subscribers map[primitive.ObjectID]map[chan models.Event]struct{}
+	// 变更流可用时由变更流统一发布事件，服务层的本地发布被跳过
+	changeStream atomic.Bool
+}
+
+// Events 全局事件总线
+var Events = NewEventBus()
+
+func NewEventBus() *EventBus {
+	return &EventBus{
+		subscribers: make(map[primitive.ObjectID]map[chan models.Event]struct{}),
+	}
+}
+
+// Subscribe 订阅指定用户的事件，返回事件通道和取消订阅函数
+func (b *EventBus) Subscribe(userID primitive.ObjectID) (<-chan models.Event, func()) {
+	ch := make(chan models.Event, eventBufferSize)
+
+	b.mu.Lock()
+	if b.subscribers[userID] == nil {
+		b.subscribers[userID] = make(map[chan models.Event]struct{})
+	}
+	b.subscribers[userID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers[userID], ch)
+			if len(b.subscribers[userID]) == 0 {
+				delete(b.subscribers, userID)
+			}
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// Publish 向指定用户的所有本地订阅者发布事件
+func (b *EventBus) Publish(userID primitive.ObjectID, eventType string, data interface{}) {
+	event := models.Event{Type: eventType, Data: data, Time: time.Now()}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for ch := range b.subscribers[userID] {
+		select {
+		case ch <- event:
+		default:
+			log.Printf("用户 %s 的事件缓冲区已满，丢弃事件 %s", userID.Hex(), eventType)
+		}
+	}
+}
+
+// publishLocal 由服务层在写操作后调用，变更流可用时跳过以避免重复推送
+func (b *EventBus) publishLocal(userID primitive.ObjectID, eventType string, data interface{}) {
+	if b.changeStream.Load() {
+		return
+	}
+	b.Publish(userID, eventType, data)
+}
+
+// publishMemo 向备忘录创建者和所有协作者发布事件
+func (b *EventBus) publishMemo(ownerID, memoID primitive.ObjectID, eventType string, data interface{}) {
+	// 没有任何订阅者时跳过协作者查询
+	b.mu.RLock()
+	idle := len(b.subscribers) == 0
+	b.mu.RUnlock()
+	if idle {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for _, userID := range memoAudience(ctx, ownerID, memoID) {
+		b.Publish(userID, eventType, data)
+	}
+}
+
+// publishMemoLocal 由服务层在备忘录写操作后调用，变更流可用时跳过
+func (b *EventBus) publishMemoLocal(ownerID, memoID primitive.ObjectID, eventType string, data interface{}) {
+	if b.changeStream.Load() {
+		return
+	}
+	b.publishMemo(ownerID, memoID, eventType, data)
+}
+
+// UsingChangeStream 返回当前是否由MongoDB变更流驱动事件
+func (b *EventBus) UsingChangeStream() bool {
+	return b.changeStream.Load()
+}
+
+// changeEvent MongoDB变更流事件
+type changeEvent struct {
+	OperationType string `bson:"operationType"`
+	Ns            struct {
+		Coll string `bson:"coll"`
+	} `bson:"ns"`
+	FullDocument      bson.Raw `bson:"fullDocument"`
+	UpdateDescription struct {
+		UpdatedFields bson.M `bson:"updatedFields"`
+	} `bson:"updateDescription"`
+}
+
+// StartEventWatcher 启动MongoDB变更流监听，单机部署不支持变更流时回退到进程内事件总线
+func StartEventWatcher() {
+	if !config.AppConfig.ChangeStreamEnabled {
+		log.Println("变更流已禁用，使用进程内事件总线")
+		return
+	}
+	go Events.watch()
+}
+
+func (b *EventBus) watch() {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"ns.coll":       bson.M{"$in": bson.A{"memos", "currency_balances", "notifications", "ai_jobs"}},
+			"operationType": bson.M{"$in": bson.A{"insert", "update", "replace"}},
+		}}},
+	}
+
+	var resumeToken bson.Raw
+	started := false
+	for {
+		opts := options.ChangeStream().SetFullDocument(options.UpdateLookup)
+		if resumeToken != nil {
+			opts.SetResumeAfter(resumeToken)
+		}
+
+		stream, err := database.DB.Watch(context.Background(), pipeline, opts)
+		if err != nil {
+			if !started {
+				log.Printf("MongoDB变更流不可用，使用进程内事件总线: %v", err)
+				return
+			}
+			// 已运行过的变更流中断后回退到本地发布并定期重试
+			b.changeStream.Store(false)
+			log.Printf("重新打开MongoDB变更流失败，稍后重试: %v", err)
+			time.Sleep(5 * time.Second)
+			continue
+		}
+
+		started = true
+		b.changeStream.Store(true)
+		log.Println("已启用MongoDB变更流推送实时事件")
+
+		for stream.Next(context.Background()) {
+			var event changeEvent
+			if err := stream.Decode(&event); err != nil {
+				log.Printf("解析变更流事件失败: %v", err)
+				continue
+			}
+			b.dispatchChange(&event)
+			resumeToken = stream.ResumeToken()
+		}
+
+		log.Printf("MongoDB变更流中断: %v", stream.Err())
+		stream.Close(context.Background())
+		b.changeStream.Store(false)
+		time.Sleep(time.Second)
+	}
+}
+
+func (b *EventBus) dispatchChange(event *changeEvent) {
+	if event.FullDocument == nil {
+		return
+	}
+
+	switch event.Ns.Coll {
+	case "memos":
+		var memo models.Memo
+		if err := bson.Unmarshal(event.FullDocument, &memo); err != nil {
+			log.Printf("解析备忘录变更失败: %v", err)
+			return
+		}
+		switch {
+		case memo.DeletedAt != nil:
+			b.publishMemo(memo.UserID, memo.ID, models.EventMemoDeleted, memoTombstone(&memo))
+		case event.OperationType == "insert":
+			b.publishMemo(memo.UserID, memo.ID, models.EventMemoCreated, memo)
+		default:
+			b.publishMemo(memo.UserID, memo.ID, models.EventMemoUpdated, memo)
+		}
+	case "notifications":
+		// 通知只推送新建事件，已读等状态变更不推送
+		if event.OperationType != "insert" {
+			return
+		}
+		var notification models.Notification
+		if err := bson.Unmarshal(event.FullDocument, &notification); err != nil {
+			log.Printf("解析通知变更失败: %v", err)
+			return
+		}
+		b.Publish(notification.UserID, models.EventNotificationCreated, notification)
+	case "ai_jobs":
+		// 只有租约续期的更新不推送
+		if fields := event.UpdateDescription.UpdatedFields; len(fields) == 1 && fields["lease_expires_at"] != nil {
+			return
+		}
+		var job models.AIJob
+		if err := bson.Unmarshal(event.FullDocument, &job); err != nil {
+			log.Printf("解析AI任务变更失败: %v", err)
+			return
+		}
+		b.Publish(job.UserID, models.EventAIJobUpdated, job)
+	case "currency_balances":
+		var balance models.CurrencyBalance
+		if err := bson.Unmarshal(event.FullDocument, &balance); err != nil {
+			log.Printf("解析余额变更失败: %v", err)
+			return
+		}
+		b.Publish(balance.UserID, models.EventBalanceChanged, models.BalanceResponse{
+			Balance:        balance.Balance,
+			LastUpdateTime: balance.LastUpdateTime,
+		})
+	}
+}
+
+func memoTombstone(memo *models.Memo) models.MemoTombstone {
+	return models.MemoTombstone{
+		ID:        memo.ID,
+		DeletedAt: *memo.DeletedAt,
+		Version:   memo.SyncSeq,
+	}
+}
@@ -0,0 +1,551 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"mjbackend/config"
+	"mjbackend/database"
+	"mjbackend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gopkg.in/yaml.v3"
+)
+
+// JSON导出格式版本
+const exportFormatVersion = 1
+
+// 单个导出任务的最长执行时间，超时仍未结束的任务由清理任务标记为失败
+const exportJobTimeout = 30 * time.Minute
+
+const (
+	// 没有待执行任务时的轮询间隔，创建新任务时会立即唤醒
+	exportPollInterval = 10 * time.Second
+	// 任务租约时长和续租间隔，执行中的实例退出后租约过期，任务由其他实例重新执行
+	exportLeaseTTL  = time.Minute
+	exportHeartbeat = 15 * time.Second
+	// 执行中断（实例退出或崩溃）达到该次数后标记为失败，不再重新执行
+	exportMaxAttempts = 3
+	// 每个实例同时执行的导出任务数
+	exportWorkers = 2
+)
+
+// exportWake 创建任务后唤醒当前实例的导出协程
+var exportWake = make(chan struct{}, 1)
+
+// 导出文件名中标题的最大字符数
+const exportTitleLength = 80
+
+// memoFrontMatter Markdown 导出文件的 YAML 头信息，导入时按相同字段解析
+type memoFrontMatter struct {
+	ID       string     `yaml:"id,omitempty"`
+	Title    string     `yaml:"title"`
+	Type     string     `yaml:"type,omitempty"`
+	Created  time.Time  `yaml:"created"`
+	Updated  time.Time  `yaml:"updated"`
+	Tags     []string   `yaml:"tags,omitempty"`
+	Folder   string     `yaml:"folder,omitempty"`
+	Pinned   bool       `yaml:"pinned,omitempty"`
+	Archived bool       `yaml:"archived,omitempty"`
+	Favorite bool       `yaml:"favorite,omitempty"`
+	Due      *time.Time `yaml:"due,omitempty"`
+}
+
+type ExportService struct {
+	storage BlobStorage
+	queue   *leasedJobQueue
+}
+
+func NewExportService() *ExportService {
+	s := &ExportService{
+		storage: NewBlobStorage("exports", config.AppConfig.ExportLocalDir),
+	}
+	s.queue = s.jobQueue()
+	return s
+}
+
+// 统计可导出的备忘录数，用于决定直接下载还是创建后台任务
+func (s *ExportService) CountMemos(userID primitive.ObjectID) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return database.GetCollection("memos").CountDocuments(ctx, exportFilter(userID))
+}
+
+// 导出当前用户创建的全部未删除备忘录（含已归档）
+func exportFilter(userID primitive.ObjectID) bson.M {
+	return bson.M{"user_id": userID, "deleted_at": nil}
+}
+
+// ExportFilename 生成导出文件名
+func ExportFilename(format string, now time.Time) string {
+	ext := ".zip"
+	if format == "json" {
+		ext = ".json"
+	}
+	return "memos-" + now.Format("20060102-150405") + ext
+}
+
+// 将备忘录逐条写入 w，返回导出的备忘录数
+func (s *ExportService) WriteExport(ctx context.Context, userID primitive.ObjectID, format string, w io.Writer) (int, error) {
+	cursor, err := database.GetCollection("memos").Find(ctx, exportFilter(userID),
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}))
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	if format == "json" {
+		return writeJSONExport(ctx, cursor, w)
+	}
+	return writeMarkdownExport(ctx, cursor, w)
+}
+
+// writeJSONExport 按 models.MemoExport 格式逐条输出，避免一次性加载全部备忘录
+func writeJSONExport(ctx context.Context, cursor *mongo.Cursor, w io.Writer) (int, error) {
+	exportedAt, err := json.Marshal(time.Now())
+	if err != nil {
+		return 0, err
+	}
+	header := `{"version":` + strconv.Itoa(exportFormatVersion) + `,"exportedAt":` + string(exportedAt) + `,"memos":[`
+	if _, err := io.WriteString(w, header); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for cursor.Next(ctx) {
+		var memo models.Memo
+		if err := cursor.Decode(&memo); err != nil {
+			return count, err
+		}
+		data, err := json.Marshal(memo)
+		if err != nil {
+			return count, err
+		}
+		if count > 0 {
+			data = append([]byte(","), data...)
+		}
+		if _, err := w.Write(data); err != nil {
+			return count, err
+		}
+		count++
+	}
+	if err := cursor.Err(); err != nil {
+		return count, err
+	}
+
+	_, err = io.WriteString(w, "]}")
+	return count, err
+}
+
+// writeMarkdownExport 每条备忘录输出为一个带 YAML 头信息的 Markdown 文件，文件夹对应zip中的目录
+func writeMarkdownExport(ctx context.Context, cursor *mongo.Cursor, w io.Writer) (int, error) {
+	zw := zip.NewWriter(w)
+	used := make(map[string]bool)
+
+	count := 0
+	for cursor.Next(ctx) {
+		var memo models.Memo
+		if err := cursor.Decode(&memo); err != nil {
+			return count, err
+		}
+		// 加密备忘录没有可读的 Markdown 内容，只能通过JSON格式导出密文
+		if memo.Encrypted != nil {
+			continue
+		}
+		data, err := renderMarkdown(&memo)
+		if err != nil {
+			return count, err
+		}
+
+		f, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     exportPath(&memo, used),
+			Method:   zip.Deflate,
+			Modified: memo.UpdatedAt,
+		})
+		if err != nil {
+			return count, err
+		}
+		if _, err := f.Write(data); err != nil {
+			return count, err
+		}
+		count++
+	}
+	if err := cursor.Err(); err != nil {
+		return count, err
+	}
+	return count, zw.Close()
+}
+
+// renderMarkdown 生成单条备忘录带 YAML 头信息的 Markdown 文件内容
+func renderMarkdown(memo *models.Memo) ([]byte, error) {
+	front, err := yaml.Marshal(memoFrontMatter{
+		ID:       memo.ID.Hex(),
+		Title:    memo.Title,
+		Type:     memo.Type,
+		Created:  memo.CreatedAt,
+		Updated:  memo.UpdatedAt,
+		Tags:     memo.Tags,
+		Folder:   memo.Folder,
+		Pinned:   memo.Pinned,
+		Archived: memo.Archived,
+		Favorite: memo.Favorite,
+		Due:      memo.DueAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("---\n")
+	buf.Write(front)
+	buf.WriteString("---\n\n")
+	buf.WriteString(memoMarkdown(memo))
+	return buf.Bytes(), nil
+}
+
+// memoMarkdown 返回备忘录的 Markdown 正文，清单条目以任务列表形式追加在正文之后
+func memoMarkdown(memo *models.Memo) string {
+	if len(memo.Items) == 0 {
+		return memo.Content
+	}
+
+	var buf strings.Builder
+	buf.WriteString(memo.Content)
+	// 正文与清单之间空一行
+	if memo.Content != "" {
+		if !strings.HasSuffix(memo.Content, "\n") {
+			buf.WriteString("\n")
+		}
+		buf.WriteString("\n")
+	}
+	for _, item := range memo.Items {
+		if item.Checked {
+			buf.WriteString("- [x] ")
+		} else {
+			buf.WriteString("- [ ] ")
+		}
+		buf.WriteString(item.Text)
+		buf.WriteString("\n")
+	}
+	return buf.String()
+}
+
+// exportPath 生成备忘录在zip中的路径，重名时追加序号
+func exportPath(memo *models.Memo, used map[string]bool) string {
+	var segments []string
+	for _, segment := range strings.Split(memo.Folder, "/") {
+		if segment = sanitizeFilename(segment, exportTitleLength); segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	dir := path.Join(segments...)
+
+	base := sanitizeFilename(memo.Title, exportTitleLength)
+	if base == "" {
+		base = "未命名"
+	}
+
+	name := path.Join(dir, base+".md")
+	for i := 2; used[strings.ToLower(name)]; i++ {
+		name = path.Join(dir, base+" ("+strconv.Itoa(i)+").md")
+	}
+	used[strings.ToLower(name)] = true
+	return name
+}
+
+// sanitizeFilename 替换文件名中的非法字符并截断长度
+func sanitizeFilename(name string, maxLen int) string {
+	runes := make([]rune, 0, len(name))
+	for _, r := range strings.TrimSpace(name) {
+		switch {
+		case unicode.IsControl(r), strings.ContainsRune(`/\:*?"<>|`, r):
+			runes = append(runes, '_')
+		default:
+			runes = append(runes, r)
+		}
+		if len(runes) >= maxLen {
+			break
+		}
+	}
+	// 避免生成 . 或 .. 这样的路径
+	return strings.Trim(string(runes), ". ")
+}
+
+// 创建后台导出任务
+func (s *ExportService) CreateJob(userID primitive.ObjectID, format string) (*models.ExportJob, error) {
+	collection := database.GetCollection("export_jobs")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	job := &models.ExportJob{
+		ID:        primitive.NewObjectID(),
+		UserID:    userID,
+		Format:    format,
+		Status:    models.ExportPending,
+		Filename:  ExportFilename(format, now),
+		CreatedAt: now,
+	}
+	if _, err := collection.InsertOne(ctx, job); err != nil {
+		return nil, err
+	}
+
+	select {
+	case exportWake <- struct{}{}:
+	default:
+	}
+	return job, nil
+}
+
+// StartExportWorker 启动导出任务执行协程。任务保存在数据库中由各实例租用执行，
+// 实例重启或退出时未完成的任务在租约过期后由其他实例重新执行
+func StartExportWorker() {
+	service := NewExportService()
+	for i := 0; i < exportWorkers; i++ {
+		owner := fmt.Sprintf("%s-export-%d", instanceID, i)
+		go service.queue.work(owner, func(job interface{}) {
+			service.runJob(job.(*models.ExportJob), owner)
+		})
+	}
+}
+
+// jobQueue 返回导出任务队列，执行中断达到 exportMaxAttempts 次的任务标记为失败并通知用户
+func (s *ExportService) jobQueue() *leasedJobQueue {
+	return &leasedJobQueue{
+		collection: "export_jobs",
+		label:      "导出任务",
+		running:    models.ExportRunning,
+		failed:     models.ExportFailed,
+		ready: func(now time.Time) bson.M {
+			return bson.M{"status": models.ExportPending}
+		},
+		remaining:      bson.M{"attempts": bson.M{"$not": bson.M{"$gte": exportMaxAttempts}}},
+		exhausted:      bson.M{"attempts": bson.M{"$gte": exportMaxAttempts}},
+		exhaustedError: "导出多次执行中断",
+		sort:           bson.D{{Key: "created_at", Value: 1}},
+		leaseTTL:       exportLeaseTTL,
+		heartbeat:      exportHeartbeat,
+		pollInterval:   exportPollInterval,
+		wake:           exportWake,
+		newJob:         func() interface{} { return &models.ExportJob{} },
+		onExhausted: func(ctx context.Context, job interface{}) {
+			failed := job.(*models.ExportJob)
+			log.Printf("导出任务 %s 已执行 %d 次均中断，标记为失败", failed.ID.Hex(), failed.Attempts)
+			s.notifyJob(ctx, failed, false, time.Now())
+		},
+	}
+}
+
+// runJob 执行已租用的导出任务，导出内容通过管道直接写入文件存储
+func (s *ExportService) runJob(job *models.ExportJob, owner string) {
+	collection := database.GetCollection("export_jobs")
+	ctx, cancel := context.WithTimeout(context.Background(), exportJobTimeout)
+	defer cancel()
+
+	done := make(chan struct{})
+	go s.queue.keepLease(job.ID, owner, cancel, done, nil)
+	defer close(done)
+
+	pr, pw := io.Pipe()
+	counted := make(chan int, 1)
+	go func() {
+		count, err := s.WriteExport(ctx, job.UserID, job.Format, pw)
+		pw.CloseWithError(err)
+		counted <- count
+	}()
+
+	reader := &countingReader{r: pr}
+	key, err := s.storage.Save(ctx, job.Filename, reader)
+	// 存储失败时关闭管道，避免写入端阻塞
+	pr.CloseWithError(errors.New("导出已中止"))
+	count := <-counted
+
+	now := time.Now()
+	set := bson.M{"finished_at": now}
+	if err != nil {
+		log.Printf("导出任务 %s 失败: %v", job.ID.Hex(), err)
+		set["status"] = models.ExportFailed
+		set["error"] = "导出失败"
+	} else {
+		set["status"] = models.ExportDone
+		set["storage_key"] = key
+		set["memo_count"] = count
+		set["size"] = reader.n
+		set["expires_at"] = now.Add(time.Duration(config.AppConfig.ExportExpireHours) * time.Hour)
+	}
+
+	updateCtx, updateCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer updateCancel()
+	// 只有仍持有租约时才写入结果，租约已被接管时由接管的实例完成任务
+	result, updateErr := collection.UpdateOne(updateCtx,
+		s.queue.heldBy(job.ID, owner),
+		bson.M{"$set": set, "$unset": bson.M{"lease_owner": "", "lease_expires_at": ""}})
+	if updateErr != nil || result.MatchedCount == 0 {
+		if updateErr != nil {
+			log.Printf("更新导出任务 %s 状态失败: %v", job.ID.Hex(), updateErr)
+		}
+		if err == nil {
+			if deleteErr := s.storage.Delete(updateCtx, key); deleteErr != nil {
+				log.Printf("删除导出文件 %s 失败: %v", job.ID.Hex(), deleteErr)
+			}
+		}
+		return
+	}
+
+	s.notifyJob(updateCtx, job, err == nil, now)
+}
+
+// notifyJob 通知用户导出任务已完成或失败
+func (s *ExportService) notifyJob(ctx context.Context, job *models.ExportJob, succeeded bool, now time.Time) {
+	notification := &models.Notification{
+		ID:        primitive.NewObjectID(),
+		UserID:    job.UserID,
+		Type:      models.NotificationExportReady,
+		Title:     "备忘录导出已完成",
+		Body:      job.Filename,
+		CreatedAt: now,
+	}
+	if !succeeded {
+		notification.Type = models.NotificationExportFailed
+		notification.Title = "备忘录导出失败"
+	}
+	if _, err := sendNotification(ctx, notification); err != nil {
+		log.Printf("发送导出任务 %s 通知失败: %v", job.ID.Hex(), err)
+	}
+}
+
+// countingReader 统计读取的字节数
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// 获取最近的导出任务
+func (s *ExportService) ListJobs(userID primitive.ObjectID) ([]models.ExportJob, error) {
+	collection := database.GetCollection("export_jobs")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cursor, err := collection.Find(ctx, bson.M{"user_id": userID},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(20))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	jobs := []models.ExportJob{}
+	if err = cursor.All(ctx, &jobs); err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// 获取导出任务详情
+func (s *ExportService) GetJob(userID, jobID primitive.ObjectID) (*models.ExportJob, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return s.getJob(ctx, userID, jobID)
+}
+
+func (s *ExportService) getJob(ctx context.Context, userID, jobID primitive.ObjectID) (*models.ExportJob, error) {
+	var job models.ExportJob
+	err := database.GetCollection("export_jobs").FindOne(ctx, bson.M{"_id": jobID, "user_id": userID}).Decode(&job)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("导出任务不存在")
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+// 打开已完成的导出文件，调用方负责关闭返回的读取器
+func (s *ExportService) OpenExport(userID, jobID primitive.ObjectID) (*models.ExportJob, io.ReadCloser, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	job, err := s.getJob(ctx, userID, jobID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if job.Status != models.ExportDone || (job.ExpiresAt != nil && job.ExpiresAt.Before(time.Now())) {
+		return nil, nil, errors.New("导出文件不可下载")
+	}
+
+	reader, err := s.storage.Open(context.Background(), job.StorageKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return job, reader, nil
+}
+
+// StartExportCleanup 定期删除过期的导出文件，并将超时未完成的任务标记为失败
+func StartExportCleanup() {
+	service := NewExportService()
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for {
+			service.cleanup()
+			<-ticker.C
+		}
+	}()
+}
+
+func (s *ExportService) cleanup() {
+	collection := database.GetCollection("export_jobs")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	now := time.Now()
+	_, err := collection.UpdateMany(ctx, bson.M{
+		"status":     bson.M{"$in": bson.A{models.ExportPending, models.ExportRunning}},
+		"created_at": bson.M{"$lt": now.Add(-2 * exportJobTimeout)},
+	}, bson.M{"$set": bson.M{"status": models.ExportFailed, "error": "导出超时", "finished_at": now}})
+	if err != nil {
+		log.Printf("清理超时导出任务失败: %v", err)
+	}
+
+	cursor, err := collection.Find(ctx, bson.M{"status": models.ExportDone, "expires_at": bson.M{"$lt": now}})
+	if err != nil {
+		log.Printf("查询过期导出文件失败: %v", err)
+		return
+	}
+	var jobs []models.ExportJob
+	if err := cursor.All(ctx, &jobs); err != nil {
+		log.Printf("读取过期导出文件失败: %v", err)
+		return
+	}
+	for _, job := range jobs {
+		if err := s.storage.Delete(ctx, job.StorageKey); err != nil {
+			log.Printf("删除导出文件 %s 失败: %v", job.ID.Hex(), err)
+			continue
+		}
+		collection.UpdateOne(ctx, bson.M{"_id": job.ID}, bson.M{
+			"$set":   bson.M{"status": models.ExportExpired},
+			"$unset": bson.M{"storage_key": ""},
+		})
+	}
+}
@@ -0,0 +1,235 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"image/color"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"mjbackend/config"
+	"mjbackend/utils"
+)
+
+// errImageUnsupported 图片服务不支持的操作，任务不重试
+var errImageUnsupported = errors.New("图片服务不支持该操作")
+
+// ImageRequest 图片生成参数
+type ImageRequest struct {
+	Prompt         string
+	NegativePrompt string
+	Width          int
+	Height         int
+	N              int
+	Seed           int64
+}
+
+// ImageGenerator 图片生成服务，所有方法返回 PNG 数据
+type ImageGenerator interface {
+	Name() string
+	// Generate 按提示词生成 N 张图片
+	Generate(ctx context.Context, req *ImageRequest) ([][]byte, error)
+	// Vary 生成与原图相似的 n 张变体
+	Vary(ctx context.Context, source []byte, contentType string, n int) ([][]byte, error)
+	// Upscale 将原图放大 scale 倍
+	Upscale(ctx context.Context, source []byte, contentType string, scale int) ([]byte, error)
+}
+
+// NewImageGenerator 根据配置创建图片生成服务：stub（本地生成占位图，用于开发和测试）或 openai（兼容 OpenAI 的接口）
+func NewImageGenerator() ImageGenerator {
+	switch config.AppConfig.ImageProvider {
+	case "openai":
+		if config.AppConfig.AIAPIKey == "" {
+			log.Println("未配置 AI_API_KEY，使用本地占位图片生成")
+			return &StubImageGenerator{}
+		}
+		return &OpenAIImageGenerator{
+			baseURL: strings.TrimRight(config.AppConfig.AIBaseURL, "/"),
+			apiKey:  config.AppConfig.AIAPIKey,
+			model:   config.AppConfig.ImageModel,
+			client:  &http.Client{Timeout: 3 * time.Minute},
+		}
+	default:
+		return &StubImageGenerator{}
+	}
+}
+
+// StubImageGenerator 不调用外部服务，按提示词的哈希生成渐变占位图，相同输入总是得到相同结果
+type StubImageGenerator struct{}
+
+func (g *StubImageGenerator) Name() string { return "stub" }
+
+func (g *StubImageGenerator) Generate(ctx context.Context, req *ImageRequest) ([][]byte, error) {
+	images := make([][]byte, 0, req.N)
+	for i := 0; i < req.N; i++ {
+		seed := stubImageSeed(req.Prompt, req.NegativePrompt, strconv.FormatInt(req.Seed, 10), strconv.Itoa(i))
+		data, err := utils.EncodePNG(utils.Gradient(req.Width, req.Height, seedColor(seed), seedColor(seed>>8|seed<<24)))
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, data)
+	}
+	return images, nil
+}
+
+func (g *StubImageGenerator) Vary(ctx context.Context, source []byte, contentType string, n int) ([][]byte, error) {
+	src, err := utils.DecodeImage(source, contentType)
+	if err != nil {
+		return nil, err
+	}
+	images := make([][]byte, 0, n)
+	for i := 0; i < n; i++ {
+		seed := stubImageSeed(string(source), strconv.Itoa(i))
+		data, err := utils.EncodePNG(utils.Tint(src, seedColor(seed), 64))
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, data)
+	}
+	return images, nil
+}
+
+func (g *StubImageGenerator) Upscale(ctx context.Context, source []byte, contentType string, scale int) ([]byte, error) {
+	src, err := utils.DecodeImage(source, contentType)
+	if err != nil {
+		return nil, err
+	}
+	return utils.EncodePNG(utils.ScaleUp(src, scale))
+}
+
+func stubImageSeed(parts ...string) uint32 {
+	h := fnv.New32a()
+	for _, part := range parts {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return h.Sum32()
+}
+
+func seedColor(seed uint32) color.NRGBA {
+	return color.NRGBA{R: uint8(seed), G: uint8(seed >> 8), B: uint8(seed >> 16), A: 255}
+}
+
+// OpenAIImageGenerator 调用兼容 OpenAI Images 的接口，不支持放大
+type OpenAIImageGenerator struct {
+	baseURL string
+	apiKey  string
+	model   string
+	client  *http.Client
+}
+
+type imageGenerationRequest struct {
+	Model          string `json:"model"`
+	Prompt         string `json:"prompt"`
+	N              int    `json:"n"`
+	Size           string `json:"size"`
+	ResponseFormat string `json:"response_format"`
+}
+
+type imageResponse struct {
+	Data []struct {
+		B64JSON string `json:"b64_json"`
+	} `json:"data"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (g *OpenAIImageGenerator) Name() string { return "openai:" + g.model }
+
+func (g *OpenAIImageGenerator) Generate(ctx context.Context, req *ImageRequest) ([][]byte, error) {
+	prompt := req.Prompt
+	if req.NegativePrompt != "" {
+		prompt += "\n\nAvoid: " + req.NegativePrompt
+	}
+	body, err := json.Marshal(imageGenerationRequest{
+		Model:          g.model,
+		Prompt:         prompt,
+		N:              req.N,
+		Size:           fmt.Sprintf("%dx%d", req.Width, req.Height),
+		ResponseFormat: "b64_json",
+	})
+	if err != nil {
+		return nil, err
+	}
+	return g.do(ctx, "/images/generations", "application/json", body)
+}
+
+func (g *OpenAIImageGenerator) Vary(ctx context.Context, source []byte, contentType string, n int) ([][]byte, error) {
+	// 变体接口只接受 PNG
+	if contentType != "image/png" {
+		src, err := utils.DecodeImage(source, contentType)
+		if err != nil {
+			return nil, err
+		}
+		if source, err = utils.EncodePNG(src); err != nil {
+			return nil, err
+		}
+	}
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("image", "image.png")
+	if err != nil {
+		return nil, err
+	}
+	part.Write(source)
+	writer.WriteField("n", strconv.Itoa(n))
+	writer.WriteField("response_format", "b64_json")
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return g.do(ctx, "/images/variations", writer.FormDataContentType(), body.Bytes())
+}
+
+func (g *OpenAIImageGenerator) Upscale(ctx context.Context, source []byte, contentType string, scale int) ([]byte, error) {
+	return nil, errImageUnsupported
+}
+
+func (g *OpenAIImageGenerator) do(ctx context.Context, path, contentType string, body []byte) ([][]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Authorization", "Bearer "+g.apiKey)
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result imageResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("图片服务返回状态码 %d", resp.StatusCode)
+	}
+	if resp.StatusCode >= 300 {
+		if result.Error != nil {
+			return nil, fmt.Errorf("图片服务返回错误: %s", result.Error.Message)
+		}
+		return nil, fmt.Errorf("图片服务返回状态码 %d", resp.StatusCode)
+	}
+	if len(result.Data) == 0 {
+		return nil, errors.New("图片服务没有返回结果")
+	}
+
+	images := make([][]byte, 0, len(result.Data))
+	for _, item := range result.Data {
+		data, err := base64.StdEncoding.DecodeString(item.B64JSON)
+		if err != nil {
+			return nil, fmt.Errorf("解析图片数据失败: %v", err)
+		}
+		images = append(images, data)
+	}
+	return images, nil
+}
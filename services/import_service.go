@@ -0,0 +1,709 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"mjbackend/database"
+	"mjbackend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gopkg.in/yaml.v3"
+)
+
+// 单次导入最多处理的备忘录数
+const maxImportMemos = 1000
+
+// 单条导入备忘录的正文大小上限
+const maxImportContentBytes = 1 << 20
+
+// zip 解压后的总大小上限，避免压缩炸弹
+const maxImportTotalBytes = 100 << 20
+
+// Evernote 导出文件中的时间格式
+const enexTimeLayout = "20060102T150405Z"
+
+// importEntry 从导入文件中解析出的一条备忘录，解析失败时 err 不为空
+type importEntry struct {
+	source string
+	memo   *models.Memo
+	// 来自本应用导出文件时的原备忘录ID，用于识别重复导入
+	originID primitive.ObjectID
+	err      error
+}
+
+type ImportService struct {
+	memoService *MemoService
+}
+
+func NewImportService() *ImportService {
+	return &ImportService{
+		memoService: NewMemoService(),
+	}
+}
+
+// ImportFormat 根据文件扩展名推断导入格式，无法识别时返回空字符串
+func ImportFormat(filename string) string {
+	switch strings.ToLower(path.Ext(filename)) {
+	case ".zip", ".md", ".markdown":
+		return models.ImportMarkdown
+	case ".json":
+		return models.ImportJSON
+	case ".enex":
+		return models.ImportENEX
+	}
+	return ""
+}
+
+// 导入备忘录，已导入过的内容标记为重复，逐条返回导入结果
+func (s *ImportService) ImportMemos(userID primitive.ObjectID, format, filename string, r io.ReaderAt, size int64) (*models.ImportResponse, error) {
+	var entries []importEntry
+	var err error
+	switch format {
+	case models.ImportMarkdown:
+		if strings.ToLower(path.Ext(filename)) == ".zip" {
+			entries, err = parseMarkdownZip(r, size)
+		} else {
+			entries, err = parseMarkdownFile(filename, io.NewSectionReader(r, 0, size))
+		}
+	case models.ImportJSON:
+		entries, err = parseJSONExport(io.NewSectionReader(r, 0, size))
+	case models.ImportENEX:
+		entries, err = parseENEX(io.NewSectionReader(r, 0, size))
+	default:
+		return nil, errors.New("不支持的导入格式")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, errors.New("导入文件中没有备忘录")
+	}
+	if len(entries) > maxImportMemos {
+		return nil, errors.New("导入的备忘录过多，请拆分后导入")
+	}
+
+	collection := database.GetCollection("memos")
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	for i := range entries {
+		if entries[i].err == nil {
+			entries[i].err = s.prepareImport(ctx, userID, entries[i].memo)
+		}
+	}
+	duplicates, err := findImportDuplicates(ctx, userID, entries)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &models.ImportResponse{
+		Format:  format,
+		Total:   len(entries),
+		Results: make([]models.ImportResult, len(entries)),
+	}
+	var docs []interface{}
+	var targets []int
+	for i, entry := range entries {
+		result := &resp.Results[i]
+		result.Source = entry.source
+		if entry.memo != nil {
+			result.Title = entry.memo.Title
+		}
+		switch {
+		case entry.err != nil:
+			result.Status = models.ImportError
+			result.Message = entry.err.Error()
+		case duplicates[entry.memo.ImportKey] || (!entry.originID.IsZero() && duplicates[entry.originID.Hex()]):
+			result.Status = models.ImportDuplicate
+		default:
+			// 同一文件中内容相同的备忘录只导入一次
+			duplicates[entry.memo.ImportKey] = true
+			docs = append(docs, entry.memo)
+			targets = append(targets, i)
+		}
+	}
+
+	failed := make(map[int]string)
+	skipped := make(map[int]bool)
+	if len(docs) > 0 {
+		keys := make([]string, 0, len(targets))
+		for _, i := range targets {
+			keys = append(keys, entries[i].memo.ImportKey)
+		}
+		if err := releaseDeletedImportKeys(ctx, userID, keys); err != nil {
+			return nil, err
+		}
+
+		lastSeq, err := database.NextSequence(ctx, memoSyncCounter, int64(len(docs)))
+		if err != nil {
+			return nil, err
+		}
+		seq := lastSeq - int64(len(docs))
+		for _, i := range targets {
+			seq++
+			entries[i].memo.SyncSeq = seq
+			entries[i].memo.CreatedSeq = seq
+		}
+
+		_, err = collection.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+		if err != nil {
+			var bulkErr mongo.BulkWriteException
+			if !errors.As(err, &bulkErr) || len(bulkErr.WriteErrors) == 0 {
+				return nil, err
+			}
+			for _, writeErr := range bulkErr.WriteErrors {
+				// 去重键唯一索引冲突说明并发导入已写入相同内容，按重复跳过
+				if mongo.IsDuplicateKeyError(writeErr.WriteError) {
+					skipped[targets[writeErr.Index]] = true
+					continue
+				}
+				failed[targets[writeErr.Index]] = writeErr.Message
+			}
+		}
+	}
+
+	for i := range resp.Results {
+		result := &resp.Results[i]
+		switch {
+		case result.Status != "":
+		case skipped[i]:
+			result.Status = models.ImportDuplicate
+		case failed[i] != "":
+			result.Status = models.ImportError
+			result.Message = failed[i]
+		default:
+			result.Status = models.ImportCreated
+			result.ID = entries[i].memo.ID.Hex()
+			s.memoService.refreshMemoLinks(ctx, entries[i].memo)
+			queueEmbedding(entries[i].memo.ID)
+			Events.publishMemoLocal(userID, entries[i].memo.ID, models.EventMemoCreated, entries[i].memo)
+		}
+
+		switch result.Status {
+		case models.ImportCreated:
+			resp.Created++
+		case models.ImportDuplicate:
+			resp.Duplicates++
+		default:
+			resp.Failed++
+		}
+	}
+	return resp, nil
+}
+
+// prepareImport 校验解析出的备忘录并补全归属、时间、索引和去重键
+func (s *ImportService) prepareImport(ctx context.Context, userID primitive.ObjectID, memo *models.Memo) error {
+	// 加密备忘录按原样导入密文，要求导入用户拥有对应的密钥
+	if memo.Encrypted != nil {
+		if err := checkMemoEnvelope(ctx, userID, memo.Encrypted); err != nil {
+			return err
+		}
+		memo.Title = ""
+		memo.Content = ""
+		memo.Type = ""
+		memo.Items = nil
+	} else if memo.Title = strings.TrimSpace(memo.Title); memo.Title == "" {
+		memo.Title = "未命名"
+	}
+	if len(memo.Content) > maxImportContentBytes {
+		return errors.New("备忘录内容过长")
+	}
+	if len(memo.Items) > maxChecklistItems {
+		return errors.New("清单条目数量超过上限")
+	}
+
+	now := time.Now()
+	memo.ID = primitive.NewObjectID()
+	memo.UserID = userID
+	// 来源没有时间时去重键不含时间，否则每次导入的创建时间不同，重复导入无法识别
+	hasTimestamp := !memo.CreatedAt.IsZero()
+	if memo.CreatedAt.IsZero() {
+		memo.CreatedAt = now
+	}
+	if memo.UpdatedAt.IsZero() || memo.UpdatedAt.Before(memo.CreatedAt) {
+		memo.UpdatedAt = memo.CreatedAt
+	}
+	memo.Tags = normalizeTags(memo.Tags)
+	if len(memo.Tags) == 0 {
+		memo.Tags = nil
+	}
+	memo.Folder = strings.Trim(strings.TrimSpace(memo.Folder), "/")
+	if len([]rune(memo.Folder)) > 200 {
+		return errors.New("文件夹名称过长")
+	}
+	if memo.Type == models.MemoTypeChecklist {
+		memo.Progress = checklistProgress(memo.Items)
+	} else {
+		memo.Type = ""
+		memo.Items = nil
+	}
+	memo.ImportKey = importKey(memo, hasTimestamp)
+	s.memoService.indexMemo(memo)
+	return nil
+}
+
+// importKey 由标题、创建时间和内容计算导入去重键，同一条笔记重复导入时得到相同的键。
+// withTime 为 false 时只使用标题和内容
+func importKey(memo *models.Memo, withTime bool) string {
+	h := sha256.New()
+	h.Write([]byte(memo.Title))
+	h.Write([]byte{0})
+	if withTime {
+		h.Write([]byte(strconv.FormatInt(memo.CreatedAt.Unix(), 10)))
+	}
+	h.Write([]byte{0})
+	h.Write([]byte(memo.Content))
+	if memo.Encrypted != nil {
+		h.Write([]byte{0})
+		h.Write([]byte(memo.Encrypted.Ciphertext))
+	}
+	for _, item := range memo.Items {
+		h.Write([]byte{0})
+		h.Write([]byte(item.Text))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// findImportDuplicates 查找已存在的导入去重键和原备忘录ID，已删除的备忘录不视为重复
+func findImportDuplicates(ctx context.Context, userID primitive.ObjectID, entries []importEntry) (map[string]bool, error) {
+	keys := []string{}
+	origins := []primitive.ObjectID{}
+	for _, entry := range entries {
+		if entry.err != nil {
+			continue
+		}
+		keys = append(keys, entry.memo.ImportKey)
+		if !entry.originID.IsZero() {
+			origins = append(origins, entry.originID)
+		}
+	}
+
+	duplicates := make(map[string]bool)
+	if len(keys) == 0 {
+		return duplicates, nil
+	}
+
+	filter := bson.M{
+		"user_id":    userID,
+		"deleted_at": nil,
+		"$or": []bson.M{
+			{"import_key": bson.M{"$in": keys}},
+			{"_id": bson.M{"$in": origins}},
+		},
+	}
+	cursor, err := database.GetCollection("memos").Find(ctx, filter,
+		options.Find().SetProjection(bson.M{"_id": 1, "import_key": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var found []models.Memo
+	if err = cursor.All(ctx, &found); err != nil {
+		return nil, err
+	}
+	for _, memo := range found {
+		duplicates[memo.ID.Hex()] = true
+		if memo.ImportKey != "" {
+			duplicates[memo.ImportKey] = true
+		}
+	}
+	return duplicates, nil
+}
+
+// releaseDeletedImportKeys 清除已删除的备忘录上与本次导入相同的去重键。
+// 去重键在未彻底删除的备忘录上仍然保留，而唯一索引不区分是否已删除，不清除时无法重新导入已删除的内容
+func releaseDeletedImportKeys(ctx context.Context, userID primitive.ObjectID, keys []string) error {
+	_, err := database.GetCollection("memos").UpdateMany(ctx,
+		bson.M{"user_id": userID, "import_key": bson.M{"$in": keys}, "deleted_at": bson.M{"$ne": nil}},
+		bson.M{"$unset": bson.M{"import_key": ""}},
+	)
+	return err
+}
+
+// parseMarkdownZip 解析zip中的全部 Markdown 文件，目录对应备忘录所在文件夹
+func parseMarkdownZip(r io.ReaderAt, size int64) ([]importEntry, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, errors.New("导入文件格式错误")
+	}
+
+	remaining := int64(maxImportTotalBytes)
+	var entries []importEntry
+	for _, f := range zr.File {
+		name := f.Name
+		if f.FileInfo().IsDir() || !isMarkdownFile(name) || isHiddenPath(name) {
+			continue
+		}
+		if len(entries) >= maxImportMemos {
+			return nil, errors.New("导入的备忘录过多，请拆分后导入")
+		}
+
+		data, err := readZipFile(f, &remaining)
+		if remaining < 0 {
+			return nil, errors.New("导入文件解压后过大")
+		}
+		if err != nil {
+			entries = append(entries, importEntry{source: name, err: err})
+			continue
+		}
+		entry := parseMarkdown(name, data, f.Modified)
+		if entry.err == nil && entry.memo.Folder == "" {
+			if dir := path.Dir(name); dir != "." {
+				entry.memo.Folder = dir
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// readZipFile 读取zip中的单个文件，超过单条大小上限时返回错误
+func readZipFile(f *zip.File, remaining *int64) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, errors.New("读取文件失败")
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(io.LimitReader(rc, maxImportContentBytes+1))
+	*remaining -= int64(len(data))
+	if err != nil {
+		return nil, errors.New("读取文件失败")
+	}
+	if len(data) > maxImportContentBytes {
+		return nil, errors.New("备忘录内容过长")
+	}
+	return data, nil
+}
+
+func isMarkdownFile(name string) bool {
+	ext := strings.ToLower(path.Ext(name))
+	return ext == ".md" || ext == ".markdown"
+}
+
+// 跳过隐藏文件和 macOS 压缩时附带的 __MACOSX 目录
+func isHiddenPath(name string) bool {
+	for _, segment := range strings.Split(name, "/") {
+		if strings.HasPrefix(segment, ".") || segment == "__MACOSX" {
+			return true
+		}
+	}
+	return false
+}
+
+// parseMarkdownFile 解析单个上传的 Markdown 文件
+func parseMarkdownFile(filename string, r io.Reader) ([]importEntry, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxImportContentBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxImportContentBytes {
+		return []importEntry{{source: filename, err: errors.New("备忘录内容过长")}}, nil
+	}
+	return []importEntry{parseMarkdown(path.Base(filename), data, time.Time{})}, nil
+}
+
+// 清单条目行：- [ ] 文本 或 - [x] 文本
+var taskLinePattern = regexp.MustCompile(`^[-*] \[([ xX])\] (.*)$`)
+
+// parseMarkdown 解析带可选 YAML 头信息的 Markdown 文件。没有头信息时
+// 标题取首行一级标题，否则取文件名；清单备忘录的任务列表还原为清单条目
+func parseMarkdown(name string, data []byte, modified time.Time) importEntry {
+	entry := importEntry{source: name}
+	text := strings.ReplaceAll(string(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))), "\r\n", "\n")
+
+	var front memoFrontMatter
+	if rest, ok := strings.CutPrefix(text, "---\n"); ok {
+		header, body, found := strings.Cut("\n"+rest, "\n---\n")
+		if !found {
+			header, found = strings.CutSuffix("\n"+rest, "\n---")
+		}
+		if !found {
+			entry.err = errors.New("头信息格式错误")
+			return entry
+		}
+		if err := yaml.Unmarshal([]byte(header), &front); err != nil {
+			entry.err = errors.New("头信息格式错误")
+			return entry
+		}
+		text = strings.TrimLeft(body, "\n")
+	}
+
+	memo := &models.Memo{
+		Title:     front.Title,
+		CreatedAt: front.Created,
+		UpdatedAt: front.Updated,
+		Tags:      front.Tags,
+		Folder:    front.Folder,
+		Pinned:    front.Pinned,
+		Archived:  front.Archived,
+		Favorite:  front.Favorite,
+		DueAt:     front.Due,
+	}
+	if memo.Title == "" {
+		if strings.HasPrefix(text, "# ") {
+			line, rest, _ := strings.Cut(text, "\n")
+			memo.Title = strings.TrimSpace(line[2:])
+			text = strings.TrimLeft(rest, "\n")
+		} else {
+			memo.Title = strings.TrimSuffix(path.Base(name), path.Ext(name))
+		}
+	}
+	if memo.CreatedAt.IsZero() && !modified.IsZero() {
+		memo.CreatedAt = modified
+		memo.UpdatedAt = modified
+	}
+
+	if front.Type == models.MemoTypeChecklist {
+		memo.Type = models.MemoTypeChecklist
+		text, memo.Items = splitTaskList(text)
+	}
+	memo.Content = text
+
+	if front.ID != "" {
+		entry.originID, _ = primitive.ObjectIDFromHex(front.ID)
+	}
+	entry.memo = memo
+	return entry
+}
+
+// splitTaskList 将正文末尾连续的任务列表拆分为清单条目
+func splitTaskList(text string) (string, []models.ChecklistItem) {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	start := len(lines)
+	for start > 0 && taskLinePattern.MatchString(lines[start-1]) {
+		start--
+	}
+
+	var texts []string
+	var checked []bool
+	for _, line := range lines[start:] {
+		match := taskLinePattern.FindStringSubmatch(line)
+		texts = append(texts, match[2])
+		checked = append(checked, match[1] != " ")
+	}
+	items := newChecklistItems(texts)
+	for i := range items {
+		items[i].Checked = checked[i]
+	}
+	return strings.TrimRight(strings.Join(lines[:start], "\n"), "\n"), items
+}
+
+// parseJSONExport 解析本应用导出的JSON文件
+func parseJSONExport(r io.Reader) ([]importEntry, error) {
+	var export models.MemoExport
+	if err := json.NewDecoder(r).Decode(&export); err != nil {
+		return nil, errors.New("导入文件格式错误")
+	}
+	if export.Version < 1 || export.Version > exportFormatVersion {
+		return nil, errors.New("不支持的导入文件版本")
+	}
+
+	entries := make([]importEntry, 0, len(export.Memos))
+	for i := range export.Memos {
+		src := &export.Memos[i]
+		memo := &models.Memo{
+			Title:     src.Title,
+			Content:   src.Content,
+			CreatedAt: src.CreatedAt,
+			UpdatedAt: src.UpdatedAt,
+			Pinned:    src.Pinned,
+			Archived:  src.Archived,
+			Favorite:  src.Favorite,
+			Tags:      src.Tags,
+			Folder:    src.Folder,
+			DueAt:     src.DueAt,
+			Encrypted: src.Encrypted,
+		}
+		if src.Type == models.MemoTypeChecklist {
+			memo.Type = models.MemoTypeChecklist
+			texts := make([]string, 0, len(src.Items))
+			for _, item := range src.Items {
+				texts = append(texts, item.Text)
+			}
+			memo.Items = newChecklistItems(texts)
+			for j := range memo.Items {
+				memo.Items[j].Checked = src.Items[j].Checked
+			}
+		}
+
+		source := src.ID.Hex()
+		if src.ID.IsZero() {
+			source = "#" + strconv.Itoa(i+1)
+		}
+		entries = append(entries, importEntry{source: source, memo: memo, originID: src.ID})
+	}
+	return entries, nil
+}
+
+// enexNote Evernote 导出文件中的一条笔记，附件资源不导入
+type enexNote struct {
+	Title   string   `xml:"title"`
+	Content string   `xml:"content"`
+	Created string   `xml:"created"`
+	Updated string   `xml:"updated"`
+	Tags    []string `xml:"tag"`
+}
+
+// parseENEX 逐条解析 Evernote 导出的 .enex 文件，笔记正文由 ENML 转换为 Markdown
+func parseENEX(r io.Reader) ([]importEntry, error) {
+	decoder := xml.NewDecoder(r)
+	decoder.Strict = false
+
+	var entries []importEntry
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.New("导入文件格式错误")
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "note" {
+			continue
+		}
+		if len(entries) >= maxImportMemos {
+			return nil, errors.New("导入的备忘录过多，请拆分后导入")
+		}
+
+		var note enexNote
+		if err := decoder.DecodeElement(&note, &start); err != nil {
+			return nil, errors.New("导入文件格式错误")
+		}
+
+		source := strings.TrimSpace(note.Title)
+		if source == "" {
+			source = "#" + strconv.Itoa(len(entries)+1)
+		}
+		content, err := enmlToMarkdown(note.Content)
+		if err != nil {
+			entries = append(entries, importEntry{source: source, err: errors.New("笔记内容格式错误")})
+			continue
+		}
+
+		memo := &models.Memo{
+			Title:   note.Title,
+			Content: content,
+			Tags:    note.Tags,
+		}
+		memo.CreatedAt, _ = time.Parse(enexTimeLayout, strings.TrimSpace(note.Created))
+		memo.UpdatedAt, _ = time.Parse(enexTimeLayout, strings.TrimSpace(note.Updated))
+		entries = append(entries, importEntry{source: source, memo: memo})
+	}
+	if len(entries) == 0 {
+		return nil, errors.New("导入文件中没有备忘录")
+	}
+	return entries, nil
+}
+
+// 转换为 Markdown 时前后需要换行的 ENML 块级元素
+var enmlBlockElements = map[string]bool{
+	"div": true, "p": true, "li": true, "ul": true, "ol": true, "table": true, "tr": true,
+	"blockquote": true, "pre": true, "hr": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+}
+
+// 连续三个及以上的换行压缩为一个空行
+var blankLinesPattern = regexp.MustCompile(`\n{3,}`)
+
+// enmlToMarkdown 将 Evernote 的 ENML（XHTML 子集）转换为 Markdown 文本，
+// 保留标题、列表、待办事项和链接，其他格式只保留文本
+func enmlToMarkdown(enml string) (string, error) {
+	decoder := xml.NewDecoder(strings.NewReader(enml))
+	decoder.Strict = false
+	decoder.AutoClose = xml.HTMLAutoClose
+	decoder.Entity = xml.HTMLEntity
+
+	var buf strings.Builder
+	newline := func() {
+		if buf.Len() > 0 && !strings.HasSuffix(buf.String(), "\n") {
+			buf.WriteString("\n")
+		}
+	}
+	var links []string
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			name := strings.ToLower(t.Name.Local)
+			if enmlBlockElements[name] {
+				newline()
+			}
+			switch name {
+			case "br":
+				buf.WriteString("\n")
+			case "hr":
+				buf.WriteString("---\n")
+			case "h1", "h2", "h3", "h4", "h5", "h6":
+				buf.WriteString(strings.Repeat("#", int(name[1]-'0')) + " ")
+			case "li":
+				buf.WriteString("- ")
+			case "en-todo":
+				newline()
+				if enmlAttr(t, "checked") == "true" {
+					buf.WriteString("- [x] ")
+				} else {
+					buf.WriteString("- [ ] ")
+				}
+			case "a":
+				href := enmlAttr(t, "href")
+				links = append(links, href)
+				if href != "" {
+					buf.WriteString("[")
+				}
+			}
+		case xml.EndElement:
+			name := strings.ToLower(t.Name.Local)
+			if name == "a" && len(links) > 0 {
+				if href := links[len(links)-1]; href != "" {
+					buf.WriteString("](" + href + ")")
+				}
+				links = links[:len(links)-1]
+			}
+			if enmlBlockElements[name] {
+				newline()
+			}
+		case xml.CharData:
+			text := string(t)
+			// 忽略元素之间用于排版的空白
+			if strings.TrimSpace(text) == "" && (buf.Len() == 0 || strings.HasSuffix(buf.String(), "\n")) {
+				continue
+			}
+			buf.WriteString(text)
+		}
+	}
+
+	return strings.TrimSpace(blankLinesPattern.ReplaceAllString(buf.String(), "\n\n")), nil
+}
+
+func enmlAttr(element xml.StartElement, name string) string {
+	for _, attr := range element.Attr {
+		if strings.EqualFold(attr.Name.Local, name) {
+			return attr.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,225 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"regexp"
+	"time"
+
+	"mjbackend/database"
+	"mjbackend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// 每个用户最多保存的密钥数
+const maxUserKeys = 20
+
+// 加密备忘录密文解码后的最大字节数
+const maxEnvelopeSize = 4 << 20
+
+// AEAD 认证标签长度，密文至少包含标签
+const aeadTagSize = 16
+
+// 各算法的随机数长度
+var cipherNonceSizes = map[string]int{
+	models.CipherAESGCM:            12,
+	models.CipherXChaCha20Poly1305: 24,
+}
+
+var keyIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+type KeyService struct{}
+
+func NewKeyService() *KeyService {
+	return &KeyService{}
+}
+
+// 获取用户的全部密钥材料，密钥均为包裹后的形式
+func (s *KeyService) ListKeys(userID primitive.ObjectID) ([]models.UserKey, error) {
+	collection := database.GetCollection("user_keys")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cursor, err := collection.Find(ctx, bson.M{"user_id": userID},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	keys := []models.UserKey{}
+	if err = cursor.All(ctx, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// 保存密钥材料，已存在时整体替换（例如修改口令后重新包裹）
+func (s *KeyService) PutKey(userID primitive.ObjectID, keyID string, req *models.UserKeyRequest) (*models.UserKey, error) {
+	collection := database.GetCollection("user_keys")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if !keyIDPattern.MatchString(keyID) {
+		return nil, errors.New("无效的密钥ID")
+	}
+	if err := validateUserKey(req); err != nil {
+		return nil, err
+	}
+
+	filter := bson.M{"user_id": userID, "key_id": keyID}
+	exists, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if exists == 0 {
+		count, err := collection.CountDocuments(ctx, bson.M{"user_id": userID})
+		if err != nil {
+			return nil, err
+		}
+		if count >= maxUserKeys {
+			return nil, errors.New("密钥数量超过上限")
+		}
+	}
+
+	now := time.Now()
+	set := bson.M{
+		"wrapped_key": req.WrappedKey,
+		"kdf":         req.KDF,
+		"updated_at":  now,
+	}
+	update := bson.M{
+		"$set":         set,
+		"$setOnInsert": bson.M{"created_at": now},
+	}
+	if req.Recovery != nil {
+		set["recovery"] = req.Recovery
+	} else {
+		update["$unset"] = bson.M{"recovery": ""}
+	}
+
+	var key models.UserKey
+	err = collection.FindOneAndUpdate(ctx, filter, update,
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)).Decode(&key)
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// 删除密钥材料，仍有加密备忘录使用该密钥时不能删除
+func (s *KeyService) DeleteKey(userID primitive.ObjectID, keyID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	inUse, err := database.GetCollection("memos").CountDocuments(ctx, bson.M{
+		"user_id":          userID,
+		"encrypted.key_id": keyID,
+	}, options.Count().SetLimit(1))
+	if err != nil {
+		return err
+	}
+	if inUse > 0 {
+		return errors.New("密钥仍被加密备忘录使用")
+	}
+
+	result, err := database.GetCollection("user_keys").DeleteOne(ctx, bson.M{"user_id": userID, "key_id": keyID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return errors.New("密钥不存在")
+	}
+	return nil
+}
+
+// checkMemoEnvelope 校验密文信封格式，并确认所用密钥属于备忘录所有者
+func checkMemoEnvelope(ctx context.Context, ownerID primitive.ObjectID, envelope *models.MemoEnvelope) error {
+	if err := validateEnvelope(envelope); err != nil {
+		return err
+	}
+	count, err := database.GetCollection("user_keys").CountDocuments(ctx, bson.M{
+		"user_id": ownerID,
+		"key_id":  envelope.KeyID,
+	})
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return errors.New("加密密钥不存在")
+	}
+	return nil
+}
+
+func validateEnvelope(envelope *models.MemoEnvelope) error {
+	if envelope.Version != models.EnvelopeVersion {
+		return errors.New("密文格式无效：不支持的版本")
+	}
+	if !keyIDPattern.MatchString(envelope.KeyID) {
+		return errors.New("密文格式无效：keyId 无效")
+	}
+	if err := validateCipher(envelope.Algorithm, envelope.Nonce, envelope.Ciphertext, maxEnvelopeSize); err != nil {
+		return errors.New("密文格式无效：" + err.Error())
+	}
+	return nil
+}
+
+// validateCipher 校验算法、随机数长度和密文长度，二进制字段为标准 Base64 编码
+func validateCipher(algorithm, nonce, ciphertext string, maxSize int) error {
+	nonceSize, ok := cipherNonceSizes[algorithm]
+	if !ok {
+		return errors.New("不支持的加密算法")
+	}
+	if len(ciphertext) > base64.StdEncoding.EncodedLen(maxSize+aeadTagSize) {
+		return errors.New("密文过大")
+	}
+	raw, err := base64.StdEncoding.DecodeString(nonce)
+	if err != nil || len(raw) != nonceSize {
+		return errors.New("nonce 长度与算法不匹配")
+	}
+	raw, err = base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return errors.New("ciphertext 不是有效的Base64")
+	}
+	if len(raw) < aeadTagSize || len(raw) > maxSize+aeadTagSize {
+		return errors.New("ciphertext 长度无效")
+	}
+	return nil
+}
+
+// validateUserKey 校验包裹后的密钥和口令派生参数，派生强度不得低于推荐下限
+func validateUserKey(req *models.UserKeyRequest) error {
+	// 包裹的是32字节的对称密钥，密文为密钥加认证标签
+	if err := validateCipher(req.WrappedKey.Algorithm, req.WrappedKey.Nonce, req.WrappedKey.Ciphertext, 32); err != nil {
+		return errors.New("密钥格式无效：" + err.Error())
+	}
+	if req.Recovery != nil {
+		if err := validateCipher(req.Recovery.Algorithm, req.Recovery.Nonce, req.Recovery.Ciphertext, 32); err != nil {
+			return errors.New("恢复密钥格式无效：" + err.Error())
+		}
+	}
+
+	kdf := req.KDF
+	salt, err := base64.StdEncoding.DecodeString(kdf.Salt)
+	if err != nil || len(salt) < 16 || len(salt) > 64 {
+		return errors.New("密钥格式无效：salt 应为16到64字节")
+	}
+	switch kdf.Name {
+	case models.KDFArgon2id:
+		if kdf.Iterations < 1 || kdf.Memory < 19456 || kdf.Parallelism < 1 {
+			return errors.New("密钥格式无效：argon2id 参数过弱")
+		}
+	case models.KDFPBKDF2SHA256:
+		if kdf.Iterations < 600000 {
+			return errors.New("密钥格式无效：pbkdf2-sha256 迭代次数过少")
+		}
+		if kdf.Memory != 0 || kdf.Parallelism != 0 {
+			return errors.New("密钥格式无效：pbkdf2-sha256 不使用 memory 和 parallelism")
+		}
+	default:
+		return errors.New("密钥格式无效：不支持的派生算法")
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"os"
+	"time"
+
+	"mjbackend/database"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// instanceID 当前进程的实例标识，用作租约持有者
+var instanceID = newInstanceID()
+
+func newInstanceID() string {
+	host, _ := os.Hostname()
+	buf := make([]byte, 4)
+	rand.Read(buf)
+	return host + "-" + hex.EncodeToString(buf)
+}
+
+// acquireLease 获取或续期名为 name 的租约，租约由其他实例持有且未过期时返回 false
+func acquireLease(ctx context.Context, name, owner string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	filter := bson.M{
+		"_id": name,
+		"$or": []bson.M{
+			{"owner": owner},
+			{"expires_at": bson.M{"$lt": now}},
+		},
+	}
+	update := bson.M{"$set": bson.M{"owner": owner, "expires_at": now.Add(ttl)}}
+
+	// 租约被他人持有时过滤条件不匹配，upsert 会因 _id 冲突失败
+	_, err := database.GetCollection("leases").UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// leasedJobQueue 以集合中的任务文档作为队列，多个执行者通过任务租约分担任务：
+// 租用时以条件更新占有任务并计入执行次数，执行期间定期续租，执行者退出后租约过期，
+// 任务由其他执行者重新租用；执行次数已用完且租约过期的任务不再租用，直接标记为失败
+type leasedJobQueue struct {
+	collection string
+	// label 日志中的任务名称
+	label   string
+	running string
+	failed  string
+	// ready 返回可以直接租用的任务条件，租约已过期的执行中任务总是可以重新租用
+	ready func(now time.Time) bson.M
+	// remaining 和 exhausted 分别为执行次数未用完和已用完的条件
+	remaining bson.M
+	exhausted bson.M
+	// exhaustedError 执行次数用完的任务记录的错误信息
+	exhaustedError string
+	sort           bson.D
+
+	leaseTTL     time.Duration
+	heartbeat    time.Duration
+	pollInterval time.Duration
+	wake         <-chan struct{}
+
+	// leaseSet、failSet 返回租用任务、标记失败时额外写入的字段，可以为 nil
+	leaseSet func(now time.Time) bson.M
+	failSet  func(now time.Time) bson.M
+	// newJob 返回用于解码任务文档的指针
+	newJob func() interface{}
+	// onExhausted 在任务因执行次数用完被标记为失败后调用，job 为更新后的任务
+	onExhausted func(ctx context.Context, job interface{})
+}
+
+// work 执行者的主循环：租用任务并交给 process 执行，没有可执行的任务时
+// 结束执行次数已用完的任务，等待唤醒或轮询间隔后继续
+func (q *leasedJobQueue) work(owner string, process func(job interface{})) {
+	for {
+		job, err := q.lease(owner)
+		if err != nil {
+			log.Printf("租用%s失败: %v", q.label, err)
+		}
+		if job != nil {
+			process(job)
+			continue
+		}
+		q.failExhausted()
+
+		select {
+		case <-q.wake:
+		case <-time.After(q.pollInterval):
+		}
+	}
+}
+
+// heldBy 返回任务仍由 owner 持有租约时的条件，结束或更新执行中的任务时使用
+func (q *leasedJobQueue) heldBy(id primitive.ObjectID, owner string) bson.M {
+	return bson.M{"_id": id, "status": q.running, "lease_owner": owner}
+}
+
+// lease 租用一个可执行的任务或租约已过期的执行中任务，没有可执行的任务时返回 nil
+func (q *leasedJobQueue) lease(owner string) (interface{}, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	filter := bson.M{"$or": []bson.M{
+		q.ready(now),
+		{"status": q.running, "lease_expires_at": bson.M{"$lt": now}},
+	}}
+	for key, value := range q.remaining {
+		filter[key] = value
+	}
+	set := bson.M{"status": q.running, "lease_owner": owner, "lease_expires_at": now.Add(q.leaseTTL)}
+	if q.leaseSet != nil {
+		for key, value := range q.leaseSet(now) {
+			set[key] = value
+		}
+	}
+
+	job := q.newJob()
+	err := database.GetCollection(q.collection).FindOneAndUpdate(ctx, filter,
+		bson.M{"$set": set, "$inc": bson.M{"attempts": 1}},
+		options.FindOneAndUpdate().SetSort(q.sort).SetReturnDocument(options.After),
+	).Decode(job)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return job, nil
+}
+
+// failExhausted 将执行次数已用完、租约又已过期的任务标记为失败。
+// 这类任务通常是执行时进程崩溃，继续租用可能使进程反复崩溃
+func (q *leasedJobQueue) failExhausted() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	collection := database.GetCollection(q.collection)
+	for {
+		now := time.Now()
+		filter := bson.M{"status": q.running, "lease_expires_at": bson.M{"$lt": now}}
+		for key, value := range q.exhausted {
+			filter[key] = value
+		}
+		set := bson.M{"status": q.failed, "error": q.exhaustedError, "finished_at": now}
+		if q.failSet != nil {
+			for key, value := range q.failSet(now) {
+				set[key] = value
+			}
+		}
+
+		job := q.newJob()
+		err := collection.FindOneAndUpdate(ctx, filter,
+			bson.M{"$set": set, "$unset": bson.M{"lease_owner": "", "lease_expires_at": ""}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(job)
+		if err != nil {
+			if err != mongo.ErrNoDocuments {
+				log.Printf("结束中断的%s失败: %v", q.label, err)
+			}
+			return
+		}
+		q.onExhausted(ctx, job)
+	}
+}
+
+// keepLease 定期续租直到 done 关闭，租约已失效时调用 cancel 中止执行。
+// stop 不为 nil 时以续租后的任务调用，返回 true 时同样中止执行
+func (q *leasedJobQueue) keepLease(id primitive.ObjectID, owner string, cancel context.CancelFunc, done <-chan struct{}, stop func(current interface{}) bool) {
+	ticker := time.NewTicker(q.heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		ctx, cancelUpdate := context.WithTimeout(context.Background(), 5*time.Second)
+		current := q.newJob()
+		err := database.GetCollection(q.collection).FindOneAndUpdate(ctx,
+			q.heldBy(id, owner),
+			bson.M{"$set": bson.M{"lease_expires_at": time.Now().Add(q.leaseTTL)}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(current)
+		cancelUpdate()
+		switch {
+		case err == mongo.ErrNoDocuments:
+			log.Printf("%s %s 的租约已失效，中止执行", q.label, id.Hex())
+			cancel()
+			return
+		case err != nil:
+			log.Printf("%s %s 续租失败: %v", q.label, id.Hex(), err)
+		case stop != nil && stop(current):
+			cancel()
+			return
+		}
+	}
+}
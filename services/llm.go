@@ -0,0 +1,160 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"mjbackend/config"
+	"mjbackend/utils"
+)
+
+// 摘要的目标长度（字符）
+const summaryLength = 200
+
+// 提交给模型的正文上限（字符），超出部分截断
+const maxSummaryInput = 8000
+
+// LLMProvider 大语言模型服务，Name 记录在生成结果中
+type LLMProvider interface {
+	Name() string
+	Summarize(ctx context.Context, title, content string) (string, error)
+}
+
+// NewLLMProvider 根据配置创建模型服务：stub（本地确定性实现，用于开发和测试）或 openai（兼容 OpenAI 的接口）
+func NewLLMProvider() LLMProvider {
+	switch config.AppConfig.AIProvider {
+	case "openai":
+		if config.AppConfig.AIAPIKey == "" {
+			log.Println("未配置 AI_API_KEY，使用本地 stub 模型")
+			return &StubLLM{}
+		}
+		return &OpenAILLM{
+			baseURL: strings.TrimRight(config.AppConfig.AIBaseURL, "/"),
+			apiKey:  config.AppConfig.AIAPIKey,
+			model:   config.AppConfig.AIModel,
+			client:  &http.Client{Timeout: 60 * time.Second},
+		}
+	default:
+		return &StubLLM{}
+	}
+}
+
+// StubLLM 不调用外部服务，取正文开头的若干句作为摘要，相同输入总是得到相同结果
+type StubLLM struct{}
+
+var sentenceEnd = regexp.MustCompile(`[。！？!?.]\s*|\n+`)
+
+func (p *StubLLM) Name() string { return "stub" }
+
+func (p *StubLLM) Summarize(ctx context.Context, title, content string) (string, error) {
+	text, err := utils.MarkdownText(content)
+	if err != nil {
+		return "", err
+	}
+
+	var summary strings.Builder
+	for _, sentence := range sentenceEnd.Split(text, -1) {
+		sentence = strings.TrimSpace(sentence)
+		if sentence == "" {
+			continue
+		}
+		if summary.Len() > 0 && utf8.RuneCountInString(summary.String())+utf8.RuneCountInString(sentence) > summaryLength {
+			break
+		}
+		summary.WriteString(sentence)
+		summary.WriteString("。")
+	}
+	if summary.Len() == 0 {
+		return title, nil
+	}
+	return truncateRunes(summary.String(), summaryLength), nil
+}
+
+// OpenAILLM 调用兼容 OpenAI Chat Completions 的接口
+type OpenAILLM struct {
+	baseURL string
+	apiKey  string
+	model   string
+	client  *http.Client
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatRequest struct {
+	Model       string        `json:"model"`
+	Messages    []chatMessage `json:"messages"`
+	Temperature float64       `json:"temperature"`
+}
+
+type chatResponse struct {
+	Choices []struct {
+		Message chatMessage `json:"message"`
+	} `json:"choices"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (p *OpenAILLM) Name() string { return "openai:" + p.model }
+
+func (p *OpenAILLM) Summarize(ctx context.Context, title, content string) (string, error) {
+	prompt := fmt.Sprintf("标题：%s\n\n%s", title, truncateRunes(content, maxSummaryInput))
+	body, err := json.Marshal(chatRequest{
+		Model: p.model,
+		Messages: []chatMessage{
+			{Role: "system", Content: fmt.Sprintf("你是笔记助手。用与笔记相同的语言为用户的笔记写一段不超过%d字的摘要，只输出摘要本身。", summaryLength)},
+			{Role: "user", Content: prompt},
+		},
+		Temperature: 0.2,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+p.apiKey)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var result chatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("模型服务返回状态码 %d", resp.StatusCode)
+	}
+	if resp.StatusCode >= 300 {
+		if result.Error != nil {
+			return "", fmt.Errorf("模型服务返回错误: %s", result.Error.Message)
+		}
+		return "", fmt.Errorf("模型服务返回状态码 %d", resp.StatusCode)
+	}
+	if len(result.Choices) == 0 {
+		return "", errors.New("模型服务没有返回结果")
+	}
+	return strings.TrimSpace(result.Choices[0].Message.Content), nil
+}
+
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
@@ -0,0 +1,55 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestStubLLMSummarize(t *testing.T) {
+	long := strings.Repeat("这是一句很长的句子，用来测试摘要长度的限制", 20)
+	tests := []struct {
+		name    string
+		title   string
+		content string
+		want    string
+	}{
+		{name: "取开头的句子", title: "标题", content: "第一句。第二句！第三句？", want: "第一句。第二句。第三句。"},
+		{name: "去除Markdown标记", title: "标题", content: "# 会议\n\n**重点**内容", want: "会议 重点内容。"},
+		{name: "英文句号分句", title: "title", content: "First line. Second line.", want: "First line。Second line。"},
+		{name: "正文为空时使用标题", title: "只有标题", content: "", want: "只有标题"},
+		{name: "正文只有空白时使用标题", title: "只有标题", content: "  \n\n  ", want: "只有标题"},
+		{name: "单句超长时截断", title: "标题", content: long, want: truncateRunes(long+"。", summaryLength)},
+	}
+
+	llm := &StubLLM{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := llm.Summarize(context.Background(), tt.title, tt.content)
+			if err != nil {
+				t.Fatalf("Summarize() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Summarize() = %q, want %q", got, tt.want)
+			}
+			if utf8.RuneCountInString(got) > summaryLength {
+				t.Errorf("Summarize() 长度 %d 超过 %d", utf8.RuneCountInString(got), summaryLength)
+			}
+		})
+	}
+}
+
+func TestStubLLMSummarizeStopsAtLength(t *testing.T) {
+	sentence := strings.Repeat("字", 90)
+	content := sentence + "。" + sentence + "。" + sentence + "。"
+
+	got, err := (&StubLLM{}).Summarize(context.Background(), "标题", content)
+	if err != nil {
+		t.Fatalf("Summarize() error = %v", err)
+	}
+	// 第三句会使摘要超过长度上限，只保留前两句
+	if want := sentence + "。" + sentence + "。"; got != want {
+		t.Errorf("Summarize() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,282 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"mjbackend/config"
+	"mjbackend/database"
+	"mjbackend/models"
+	"mjbackend/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// 账号连续失败达到该次数后，每次再尝试前需要等待，等待时长逐次加倍
+	loginDelayAfter = 3
+	loginMaxDelay   = 30 * time.Second
+	// 锁定时长最多加倍的次数，避免他人持续猜错密码使账号长时间无法登录
+	loginMaxLockoutDoublings = 2
+	// 最后一次失败后计数保留的时长，之后重新计数
+	loginFailureTTL = 24 * time.Hour
+	// 成功登录前有这么多次密码错误时视为可疑登录
+	suspiciousFailures = 3
+)
+
+// LoginThrottledError 账号或IP因多次登录失败被限制，RetryAfter 后可以再次尝试
+type LoginThrottledError struct {
+	Message    string
+	RetryAfter time.Duration
+}
+
+func (e *LoginThrottledError) Error() string { return e.Message }
+
+func accountLockKey(username string) string { return "user:" + username }
+func ipLockKey(ip string) string            { return "ip:" + ip }
+
+// checkLoginAllowed 校验密码前检查账号和IP是否被锁定或仍在等待期内。
+// knownIP 为 true 表示该IP曾成功登录过此账号，不受账号锁定和等待限制，只受IP锁定限制，
+// 这样他人反复猜错密码时账号所有者仍能从常用的IP登录
+func checkLoginAllowed(ctx context.Context, username, ip string, knownIP bool) (*models.LoginLock, error) {
+	cursor, err := database.GetCollection("login_failures").Find(ctx,
+		bson.M{"_id": bson.M{"$in": bson.A{accountLockKey(username), ipLockKey(ip)}}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var locks []models.LoginLock
+	if err := cursor.All(ctx, &locks); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var account *models.LoginLock
+	var throttled *LoginThrottledError
+	for i := range locks {
+		lock := &locks[i]
+		isAccount := lock.Key == accountLockKey(username)
+		if isAccount {
+			account = lock
+			if knownIP {
+				continue
+			}
+		}
+
+		var until time.Time
+		var message string
+		switch {
+		case lock.LockedUntil != nil && lock.LockedUntil.After(now):
+			until = *lock.LockedUntil
+			message = "该IP登录失败次数过多，已被临时锁定，请稍后再试"
+			if isAccount {
+				message = "账号登录失败次数过多，已被临时锁定，请稍后再试"
+			}
+		case lock.NextAttemptAt != nil && lock.NextAttemptAt.After(now):
+			until = *lock.NextAttemptAt
+			message = "登录尝试过于频繁，请稍后再试"
+		default:
+			continue
+		}
+		if throttled == nil || until.Sub(now) > throttled.RetryAfter {
+			throttled = &LoginThrottledError{Message: message, RetryAfter: until.Sub(now)}
+		}
+	}
+	if throttled != nil {
+		return account, throttled
+	}
+	return account, nil
+}
+
+// recordLoginFailure 累加失败计数，达到上限时锁定并清零计数，再次被锁定时锁定时长加倍，最多加倍 loginMaxLockoutDoublings 次。
+// 账号计数还会在达到 loginDelayAfter 次后设置下一次允许尝试的时间
+func recordLoginFailure(ctx context.Context, key string, maxFailures int, delay bool) error {
+	collection := database.GetCollection("login_failures")
+	now := time.Now()
+
+	var lock models.LoginLock
+	err := collection.FindOneAndUpdate(ctx,
+		bson.M{"_id": key},
+		bson.M{
+			"$inc": bson.M{"failures": 1},
+			"$set": bson.M{"updated_at": now, "expires_at": now.Add(loginFailureTTL)},
+		},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&lock)
+	if err != nil {
+		return err
+	}
+
+	if lock.Failures >= maxFailures {
+		lockout := config.AppConfig.LoginLockout << min(lock.Lockouts, loginMaxLockoutDoublings)
+		lockedUntil := now.Add(lockout)
+		// 只有计数未被并发修改时才锁定，避免同一轮失败重复加倍
+		_, err = collection.UpdateOne(ctx,
+			bson.M{"_id": key, "failures": lock.Failures},
+			bson.M{
+				"$set":   bson.M{"failures": 0, "locked_until": lockedUntil, "expires_at": lockedUntil.Add(loginFailureTTL)},
+				"$inc":   bson.M{"lockouts": 1},
+				"$unset": bson.M{"next_attempt_at": ""},
+			},
+		)
+		return err
+	}
+
+	if delay && lock.Failures >= loginDelayAfter {
+		wait := time.Second << (lock.Failures - loginDelayAfter)
+		if wait > loginMaxDelay {
+			wait = loginMaxDelay
+		}
+		_, err = collection.UpdateOne(ctx,
+			bson.M{"_id": key},
+			bson.M{"$set": bson.M{"next_attempt_at": now.Add(wait)}},
+		)
+	}
+	return err
+}
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     string
+)
+
+// checkDummyPassword 用户名不存在时同样执行一次 bcrypt 比较，使响应耗时与密码错误一致，避免据此判断用户名是否存在
+func checkDummyPassword(password string) {
+	dummyHashOnce.Do(func() {
+		hash, err := utils.HashPassword("dummy-password")
+		if err != nil {
+			log.Printf("生成占位密码哈希失败: %v", err)
+			return
+		}
+		dummyHash = hash
+	})
+	utils.CheckPasswordHash(password, dummyHash)
+}
+
+// recordLoginAttempt 写入登录尝试记录，失败只记录日志，不影响登录结果
+func recordLoginAttempt(ctx context.Context, attempt *models.LoginAttempt) {
+	attempt.ID = primitive.NewObjectID()
+	attempt.CreatedAt = time.Now()
+	if _, err := database.GetCollection("login_attempts").InsertOne(ctx, attempt); err != nil {
+		log.Printf("记录登录尝试失败: %v", err)
+	}
+}
+
+// knownLoginIP 判断该IP是否曾成功登录过此账号
+func knownLoginIP(ctx context.Context, userID primitive.ObjectID, ip string) (bool, error) {
+	err := database.GetCollection("login_attempts").FindOne(ctx,
+		bson.M{"user_id": userID, "result": models.LoginSucceeded, "ip": ip}).Err()
+	if err == mongo.ErrNoDocuments {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// suspiciousReasons 判断成功的登录是否可疑：来自该用户从未成功登录过的IP，或之前有多次密码错误
+func suspiciousReasons(ctx context.Context, userID primitive.ObjectID, knownIP bool, account *models.LoginLock) []string {
+	var reasons []string
+
+	// 首次登录没有可比较的历史
+	if !knownIP {
+		err := database.GetCollection("login_attempts").FindOne(ctx,
+			bson.M{"user_id": userID, "result": models.LoginSucceeded}).Err()
+		if err == nil {
+			reasons = append(reasons, "新的登录IP")
+		}
+	}
+
+	if account != nil && (account.Failures >= suspiciousFailures || account.Lockouts > 0) {
+		reasons = append(reasons, "登录前多次密码错误")
+	}
+	return reasons
+}
+
+// notifySuspiciousLogin 通过站内通知提醒用户可疑登录
+func notifySuspiciousLogin(ctx context.Context, attempt *models.LoginAttempt) {
+	notification := &models.Notification{
+		ID:        primitive.NewObjectID(),
+		UserID:    *attempt.UserID,
+		Type:      models.NotificationLoginAlert,
+		Title:     "检测到异常登录",
+		Body:      fmt.Sprintf("IP %s（%s），如非本人操作请尽快修改密码", attempt.IP, strings.Join(attempt.SuspiciousReasons, "，")),
+		CreatedAt: attempt.CreatedAt,
+	}
+	if _, err := sendNotification(ctx, notification); err != nil {
+		log.Printf("发送异常登录通知失败: %v", err)
+	}
+}
+
+// ListLoginAttempts 获取当前用户最近的登录记录
+func (s *UserService) ListLoginAttempts(userID primitive.ObjectID, limit int) ([]models.LoginAttempt, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if limit < 1 || limit > 100 {
+		limit = 50
+	}
+	cursor, err := database.GetCollection("login_attempts").Find(ctx,
+		bson.M{"user_id": userID},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(int64(limit)))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	attempts := []models.LoginAttempt{}
+	if err := cursor.All(ctx, &attempts); err != nil {
+		return nil, err
+	}
+	return attempts, nil
+}
+
+// ListLoginLocks 获取当前被锁定的账号和IP，供管理员查看
+func (s *UserService) ListLoginLocks() ([]models.LoginLock, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cursor, err := database.GetCollection("login_failures").Find(ctx,
+		bson.M{"locked_until": bson.M{"$gt": time.Now()}},
+		options.Find().SetSort(bson.D{{Key: "locked_until", Value: -1}}).SetLimit(200))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	locks := []models.LoginLock{}
+	if err := cursor.All(ctx, &locks); err != nil {
+		return nil, err
+	}
+	return locks, nil
+}
+
+// UnlockLogin 管理员解除账号或IP的登录锁定，同时清除失败计数，返回解除的条数
+func (s *UserService) UnlockLogin(req *models.UnlockLoginRequest) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var keys bson.A
+	if username := strings.TrimSpace(req.Username); username != "" {
+		keys = append(keys, accountLockKey(username))
+	}
+	if ip := strings.TrimSpace(req.IP); ip != "" {
+		keys = append(keys, ipLockKey(ip))
+	}
+	if len(keys) == 0 {
+		return 0, errors.New("请指定要解锁的用户名或IP")
+	}
+
+	result, err := database.GetCollection("login_failures").DeleteMany(ctx, bson.M{"_id": bson.M{"$in": keys}})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
@@ -0,0 +1,338 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"mjbackend/database"
+	"mjbackend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// 角色权限等级。备忘录创建者视为 owner，已接受邀请的 owner 协作者与创建者权限相同：
+// viewer 可查看备忘录和附件；editor 还可修改内容、清单、提醒和附件；
+// owner 还可管理协作者和分享链接、设置置顶归档收藏、删除和彻底删除。
+// 附件、分享链接等备忘录下的资源都记在创建者名下，附件占用创建者的容量
+var roleRank = map[string]int{
+	models.RoleViewer: 1,
+	models.RoleEditor: 2,
+	models.RoleOwner:  3,
+}
+
+type MemberService struct{}
+
+func NewMemberService() *MemberService {
+	return &MemberService{}
+}
+
+// authorizeMemo 校验用户对未删除的备忘录至少拥有 minRole 权限，返回备忘录并填充用户角色
+// 无任何权限时返回备忘录不存在，避免泄露他人备忘录是否存在
+func authorizeMemo(ctx context.Context, userID, memoID primitive.ObjectID, minRole string) (*models.Memo, error) {
+	return authorizeMemoFilter(ctx, userID, bson.M{"_id": memoID, "deleted_at": nil}, minRole)
+}
+
+// authorizeMemoFilter 与 authorizeMemo 相同，按指定条件查找备忘录，用于操作已删除的备忘录
+func authorizeMemoFilter(ctx context.Context, userID primitive.ObjectID, filter bson.M, minRole string) (*models.Memo, error) {
+	var memo models.Memo
+	err := database.GetCollection("memos").FindOne(ctx, filter).Decode(&memo)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("备忘录不存在")
+		}
+		return nil, err
+	}
+
+	role := models.RoleOwner
+	if memo.UserID != userID {
+		role, err = memberRole(ctx, memo.ID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if role == "" {
+			return nil, errors.New("备忘录不存在")
+		}
+	}
+
+	if roleRank[role] < roleRank[minRole] {
+		return nil, errors.New("没有操作权限")
+	}
+	memo.Role = role
+	return &memo, nil
+}
+
+// 以 memoVersionFilter 写入时遇到并发修改的最大重试次数
+const memoWriteRetries = 3
+
+// memoVersionFilter 返回以授权时读到的同步版本号为条件的写入筛选，
+// 授权后备忘录被修改、删除或恢复时写入不会生效，调用方重新授权后重试
+func memoVersionFilter(memo *models.Memo) bson.M {
+	filter := bson.M{"_id": memo.ID, "sync_seq": memo.SyncSeq}
+	// 早于增量同步上线的备忘录没有版本号，视为版本0
+	if memo.SyncSeq == 0 {
+		filter["sync_seq"] = bson.M{"$in": bson.A{int64(0), nil}}
+	}
+	return filter
+}
+
+// bumpMemoVersion 为备忘录分配新的同步版本号。协作者角色变化或被移除后调用，
+// 使按旧权限授权、尚未写入的请求在版本条件上失败，重新授权时按新角色判断
+func bumpMemoVersion(ctx context.Context, memoID primitive.ObjectID) error {
+	seq, err := database.NextSequence(ctx, memoSyncCounter, 1)
+	if err != nil {
+		return err
+	}
+	_, err = database.GetCollection("memos").UpdateOne(ctx,
+		bson.M{"_id": memoID},
+		bson.M{"$set": bson.M{"sync_seq": seq}},
+	)
+	return err
+}
+
+// memberRole 返回用户在备忘录中已接受的协作角色，不是协作者时返回空字符串
+func memberRole(ctx context.Context, memoID, userID primitive.ObjectID) (string, error) {
+	var member models.MemoMember
+	err := database.GetCollection("memo_members").FindOne(ctx, bson.M{
+		"memo_id": memoID,
+		"user_id": userID,
+		"status":  models.MemberAccepted,
+	}).Decode(&member)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return "", nil
+		}
+		return "", err
+	}
+	return member.Role, nil
+}
+
+// memoAudience 返回应收到备忘录事件的用户：创建者和已接受邀请的协作者
+func memoAudience(ctx context.Context, ownerID, memoID primitive.ObjectID) []primitive.ObjectID {
+	audience := []primitive.ObjectID{ownerID}
+
+	cursor, err := database.GetCollection("memo_members").Find(ctx, bson.M{
+		"memo_id": memoID,
+		"status":  models.MemberAccepted,
+	}, options.Find().SetProjection(bson.M{"user_id": 1}))
+	if err != nil {
+		return audience
+	}
+	defer cursor.Close(ctx)
+
+	var members []models.MemoMember
+	if err := cursor.All(ctx, &members); err != nil {
+		return audience
+	}
+	for _, member := range members {
+		audience = append(audience, member.UserID)
+	}
+	return audience
+}
+
+// 邀请用户协作编辑备忘录
+func (s *MemberService) InviteMember(userID primitive.ObjectID, username string, memoID primitive.ObjectID, req *models.InviteMemberRequest) (*models.MemoMember, error) {
+	collection := database.GetCollection("memo_members")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	memo, err := authorizeMemo(ctx, userID, memoID, models.RoleOwner)
+	if err != nil {
+		return nil, err
+	}
+
+	var invitee models.User
+	err = database.GetCollection("users").FindOne(ctx, bson.M{"username": req.Username}).Decode(&invitee)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("用户不存在")
+		}
+		return nil, err
+	}
+	if invitee.ID == memo.UserID {
+		return nil, errors.New("不能邀请备忘录创建者")
+	}
+
+	member := &models.MemoMember{
+		ID:        primitive.NewObjectID(),
+		MemoID:    memoID,
+		OwnerID:   memo.UserID,
+		UserID:    invitee.ID,
+		Username:  invitee.Username,
+		Role:      req.Role,
+		Status:    models.MemberPending,
+		InvitedBy: username,
+		CreatedAt: time.Now(),
+	}
+
+	if _, err = collection.InsertOne(ctx, member); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, errors.New("该用户已是协作者或已被邀请")
+		}
+		return nil, err
+	}
+	return member, nil
+}
+
+// 获取备忘录的协作者列表
+func (s *MemberService) ListMembers(userID, memoID primitive.ObjectID) ([]models.MemoMember, error) {
+	collection := database.GetCollection("memo_members")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := authorizeMemo(ctx, userID, memoID, models.RoleViewer); err != nil {
+		return nil, err
+	}
+
+	cursor, err := collection.Find(ctx, bson.M{"memo_id": memoID},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	members := []models.MemoMember{}
+	if err = cursor.All(ctx, &members); err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// 修改协作者角色
+func (s *MemberService) UpdateMemberRole(userID, memoID, memberID primitive.ObjectID, role string) (*models.MemoMember, error) {
+	collection := database.GetCollection("memo_members")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := authorizeMemo(ctx, userID, memoID, models.RoleOwner); err != nil {
+		return nil, err
+	}
+
+	var member models.MemoMember
+	err := collection.FindOneAndUpdate(ctx,
+		bson.M{"_id": memberID, "memo_id": memoID},
+		bson.M{"$set": bson.M{"role": role}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&member)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("协作者不存在")
+		}
+		return nil, err
+	}
+	if err := bumpMemoVersion(ctx, memoID); err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+// 移除协作者，协作者也可以移除自己以退出协作
+func (s *MemberService) RemoveMember(userID, memoID, memberID primitive.ObjectID) error {
+	collection := database.GetCollection("memo_members")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var member models.MemoMember
+	err := collection.FindOne(ctx, bson.M{"_id": memberID, "memo_id": memoID}).Decode(&member)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return errors.New("协作者不存在")
+		}
+		return err
+	}
+
+	if member.UserID != userID {
+		if _, err := authorizeMemo(ctx, userID, memoID, models.RoleOwner); err != nil {
+			return err
+		}
+	}
+
+	if _, err = collection.DeleteOne(ctx, bson.M{"_id": memberID}); err != nil {
+		return err
+	}
+	// 尚未接受的邀请没有任何权限，不需要让进行中的写入失效
+	if member.Status != models.MemberAccepted {
+		return nil
+	}
+	return bumpMemoVersion(ctx, memoID)
+}
+
+// 获取当前用户待处理的协作邀请
+func (s *MemberService) ListInvitations(userID primitive.ObjectID) ([]models.MemoInvitation, error) {
+	collection := database.GetCollection("memo_members")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cursor, err := collection.Find(ctx, bson.M{"user_id": userID, "status": models.MemberPending},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var members []models.MemoMember
+	if err = cursor.All(ctx, &members); err != nil {
+		return nil, err
+	}
+
+	invitations := []models.MemoInvitation{}
+	for _, member := range members {
+		var memo models.Memo
+		err := database.GetCollection("memos").FindOne(ctx, bson.M{"_id": member.MemoID, "deleted_at": nil},
+			options.FindOne().SetProjection(bson.M{"title": 1, "encrypted.v": 1})).Decode(&memo)
+		if err != nil {
+			// 备忘录已被删除的邀请不再展示
+			continue
+		}
+		invitations = append(invitations, models.MemoInvitation{
+			ID:        member.ID,
+			MemoID:    member.MemoID,
+			MemoTitle: memoDisplayTitle(&memo),
+			Role:      member.Role,
+			InvitedBy: member.InvitedBy,
+			CreatedAt: member.CreatedAt,
+		})
+	}
+	return invitations, nil
+}
+
+// 接受协作邀请
+func (s *MemberService) AcceptInvitation(userID, invitationID primitive.ObjectID) (*models.MemoMember, error) {
+	collection := database.GetCollection("memo_members")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	var member models.MemoMember
+	err := collection.FindOneAndUpdate(ctx,
+		bson.M{"_id": invitationID, "user_id": userID, "status": models.MemberPending},
+		bson.M{"$set": bson.M{"status": models.MemberAccepted, "accepted_at": now}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&member)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("邀请不存在")
+		}
+		return nil, err
+	}
+	return &member, nil
+}
+
+// 拒绝协作邀请
+func (s *MemberService) DeclineInvitation(userID, invitationID primitive.ObjectID) error {
+	collection := database.GetCollection("memo_members")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := collection.DeleteOne(ctx, bson.M{"_id": invitationID, "user_id": userID, "status": models.MemberPending})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return errors.New("邀请不存在")
+	}
+	return nil
+}
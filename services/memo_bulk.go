@@ -0,0 +1,246 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"mjbackend/database"
+	"mjbackend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// 单次批量操作最多处理的备忘录数
+const maxBulkMemos = 1000
+
+// 批量操作备忘录，只处理当前用户创建的备忘录，逐条返回处理结果
+func (s *MemoService) BulkMemos(userID primitive.ObjectID, req *models.BulkMemoRequest) (*models.BulkMemoResponse, error) {
+	collection := database.GetCollection("memos")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	tags := normalizeTags(req.Tags)
+	if (req.Action == "tag" || req.Action == "untag") && len(tags) == 0 {
+		return nil, errors.New("请指定标签")
+	}
+
+	// 恢复操作针对已删除但未彻底删除的备忘录，其余操作针对未删除的备忘录
+	stateFilter := bson.M{"deleted_at": nil}
+	if req.Action == "restore" {
+		stateFilter = bson.M{"deleted_at": bson.M{"$ne": nil}, "purged_at": nil}
+	}
+
+	requested, filter, err := bulkTargetFilter(userID, req, stateFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := collection.Find(ctx, filter, options.Find().
+		SetProjection(bson.M{"_id": 1}).
+		SetSort(bson.D{{Key: "_id", Value: 1}}).
+		SetLimit(maxBulkMemos+1))
+	if err != nil {
+		return nil, err
+	}
+	var found []models.Memo
+	if err = cursor.All(ctx, &found); err != nil {
+		return nil, err
+	}
+	if len(found) > maxBulkMemos {
+		return nil, errors.New("匹配的备忘录过多，请缩小筛选范围")
+	}
+
+	// 按ID批量操作时保持请求顺序，不存在或无权操作的ID标记为 not_found
+	if requested == nil {
+		for _, memo := range found {
+			requested = append(requested, memo.ID)
+		}
+	}
+	owned := make(map[primitive.ObjectID]bool, len(found))
+	for _, memo := range found {
+		owned[memo.ID] = true
+	}
+
+	resp := &models.BulkMemoResponse{
+		Action:  req.Action,
+		Matched: len(found),
+		Results: make([]models.BulkMemoResult, 0, len(requested)),
+	}
+	if len(found) == 0 {
+		for _, id := range requested {
+			resp.Results = append(resp.Results, models.BulkMemoResult{ID: id.Hex(), Status: "not_found"})
+		}
+		return resp, nil
+	}
+
+	lastSeq, err := database.NextSequence(ctx, memoSyncCounter, int64(len(found)))
+	if err != nil {
+		return nil, err
+	}
+	seq := lastSeq - int64(len(found))
+
+	now := time.Now()
+	folder := strings.Trim(strings.TrimSpace(req.Folder), "/")
+	failed := make(map[primitive.ObjectID]string)
+	missing := make(map[primitive.ObjectID]bool)
+	var applied []primitive.ObjectID
+	for _, id := range requested {
+		if !owned[id] {
+			continue
+		}
+		seq++
+		filter := bson.M{"_id": id, "user_id": userID}
+		for key, value := range stateFilter {
+			filter[key] = value
+		}
+		result, err := collection.UpdateOne(ctx, filter, bulkUpdate(req.Action, tags, folder, now, seq))
+		if err != nil {
+			failed[id] = err.Error()
+			continue
+		}
+		// 查询之后被并发删除、恢复或彻底删除的备忘录不再匹配
+		if result.MatchedCount == 0 {
+			missing[id] = true
+			continue
+		}
+		resp.Modified += result.ModifiedCount
+		applied = append(applied, id)
+	}
+
+	for _, id := range requested {
+		switch {
+		case !owned[id] || missing[id]:
+			resp.Results = append(resp.Results, models.BulkMemoResult{ID: id.Hex(), Status: "not_found"})
+		case failed[id] != "":
+			resp.Results = append(resp.Results, models.BulkMemoResult{ID: id.Hex(), Status: "error", Message: failed[id]})
+		default:
+			resp.Results = append(resp.Results, models.BulkMemoResult{ID: id.Hex(), Status: "applied"})
+		}
+	}
+
+	if req.Action == "delete" || req.Action == "restore" {
+		s.refreshBulkLinks(ctx, applied)
+	}
+	s.publishBulkChanges(ctx, userID, applied)
+	return resp, nil
+}
+
+// bulkTargetFilter 返回按ID操作时请求的ID列表和查询条件，按筛选条件操作时ID列表为 nil
+func bulkTargetFilter(userID primitive.ObjectID, req *models.BulkMemoRequest, stateFilter bson.M) ([]primitive.ObjectID, bson.M, error) {
+	if len(req.IDs) > 0 && req.Filter != nil {
+		return nil, nil, errors.New("ids 和 filter 只能指定一个")
+	}
+
+	if req.Filter != nil {
+		f := req.Filter
+		if !f.All && f.Keyword == "" && f.Tag == "" && f.Folder == nil && f.Pinned == nil && f.Archived == nil && f.Favorite == nil {
+			return nil, nil, errors.New("筛选条件为空，操作全部备忘录需指定 all 为 true")
+		}
+		filter := memoListFilter(userID, &models.MemoListQuery{
+			Keyword:  req.Filter.Keyword,
+			Tag:      req.Filter.Tag,
+			Folder:   req.Filter.Folder,
+			Pinned:   req.Filter.Pinned,
+			Archived: req.Filter.Archived,
+			Favorite: req.Filter.Favorite,
+		})
+		delete(filter, "deleted_at")
+		for key, value := range stateFilter {
+			filter[key] = value
+		}
+		return nil, filter, nil
+	}
+
+	if len(req.IDs) == 0 {
+		return nil, nil, errors.New("请指定备忘录ID或筛选条件")
+	}
+	ids := make([]primitive.ObjectID, 0, len(req.IDs))
+	seen := make(map[primitive.ObjectID]bool, len(req.IDs))
+	for _, hex := range req.IDs {
+		id, err := primitive.ObjectIDFromHex(hex)
+		if err != nil {
+			return nil, nil, errors.New("无效的备忘录ID")
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	filter := bson.M{"_id": bson.M{"$in": ids}, "user_id": userID}
+	for key, value := range stateFilter {
+		filter[key] = value
+	}
+	return ids, filter, nil
+}
+
+// bulkUpdate 生成单个备忘录的更新语句
+func bulkUpdate(action string, tags []string, folder string, now time.Time, seq int64) bson.M {
+	set := bson.M{"sync_seq": seq}
+	update := bson.M{"$set": set}
+	switch action {
+	case "delete":
+		set["deleted_at"] = now
+		set["updated_at"] = now
+	case "restore":
+		set["updated_at"] = now
+		update["$unset"] = bson.M{"deleted_at": ""}
+	case "archive":
+		set["archived"] = true
+	case "unarchive":
+		update["$unset"] = bson.M{"archived": ""}
+	case "tag":
+		update["$addToSet"] = bson.M{"tags": bson.M{"$each": tags}}
+	case "untag":
+		update["$pull"] = bson.M{"tags": bson.M{"$in": tags}}
+	case "move":
+		if folder == "" {
+			update["$unset"] = bson.M{"folder": ""}
+		} else {
+			set["folder"] = folder
+		}
+	}
+	return update
+}
+
+// publishBulkChanges 为批量操作成功的备忘录发布事件，变更流可用时跳过
+func (s *MemoService) publishBulkChanges(ctx context.Context, userID primitive.ObjectID, ids []primitive.ObjectID) {
+	if Events.UsingChangeStream() || len(ids) == 0 {
+		return
+	}
+
+	cursor, err := database.GetCollection("memos").Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return
+	}
+	var memos []models.Memo
+	if err := cursor.All(ctx, &memos); err != nil {
+		return
+	}
+	for i := range memos {
+		if memos[i].DeletedAt != nil {
+			Events.publishMemoLocal(userID, memos[i].ID, models.EventMemoDeleted, memoTombstone(&memos[i]))
+		} else {
+			Events.publishMemoLocal(userID, memos[i].ID, models.EventMemoUpdated, memos[i])
+		}
+	}
+}
+
+// normalizeTags 去除标签首尾空白并去重，保持原有顺序
+func normalizeTags(tags []string) []string {
+	result := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		result = append(result, tag)
+	}
+	return result
+}
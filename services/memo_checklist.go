@@ -0,0 +1,227 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"mjbackend/database"
+	"mjbackend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// 单个清单的最大条目数
+const maxChecklistItems = 500
+
+// 新建清单条目，按给定顺序编号
+func newChecklistItems(texts []string) []models.ChecklistItem {
+	items := make([]models.ChecklistItem, 0, len(texts))
+	for i, text := range texts {
+		items = append(items, models.ChecklistItem{
+			ID:       primitive.NewObjectID(),
+			Text:     text,
+			Position: i,
+		})
+	}
+	return items
+}
+
+// 统计清单完成情况
+func checklistProgress(items []models.ChecklistItem) *models.ChecklistProgress {
+	progress := &models.ChecklistProgress{Total: len(items)}
+	for _, item := range items {
+		if item.Checked {
+			progress.Checked++
+		}
+	}
+	return progress
+}
+
+// 清单条目文本，用于全文索引和搜索片段
+func checklistText(items []models.ChecklistItem) string {
+	texts := make([]string, 0, len(items))
+	for _, item := range items {
+		texts = append(texts, item.Text)
+	}
+	return strings.Join(texts, "\n")
+}
+
+// 搜索结果片段使用的文本：正文之后追加清单条目
+func searchableText(memo *models.Memo) string {
+	if len(memo.Items) == 0 {
+		return memo.Content
+	}
+	if memo.Content == "" {
+		return checklistText(memo.Items)
+	}
+	return memo.Content + "\n" + checklistText(memo.Items)
+}
+
+// 生成写入清单所需的字段：条目、完成计数和条目索引
+func (s *MemoService) checklistFields(items []models.ChecklistItem) bson.M {
+	for i := range items {
+		items[i].Position = i
+	}
+	return bson.M{
+		"items":        items,
+		"progress":     checklistProgress(items),
+		"search_items": strings.Join(s.analyzer.IndexTokens(checklistText(items)), " "),
+	}
+}
+
+func findChecklistItem(items []models.ChecklistItem, itemID primitive.ObjectID) int {
+	for i, item := range items {
+		if item.ID == itemID {
+			return i
+		}
+	}
+	return -1
+}
+
+// 将条目移动到指定位置，超出范围时移动到末尾
+func moveChecklistItem(items []models.ChecklistItem, from, to int) []models.ChecklistItem {
+	item := items[from]
+	items = append(items[:from], items[from+1:]...)
+	if to > len(items) {
+		to = len(items)
+	}
+	items = append(items[:to], append([]models.ChecklistItem{item}, items[to:]...)...)
+	return items
+}
+
+// updateChecklist 读取清单、修改条目后写回，以同步版本号作为乐观锁，
+// 并发修改冲突时重新读取后重试，保证单个条目操作不会覆盖其他人的修改
+func (s *MemoService) updateChecklist(userID, memoID primitive.ObjectID, mutate func(items []models.ChecklistItem) ([]models.ChecklistItem, error)) (*models.Memo, error) {
+	collection := database.GetCollection("memos")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for attempt := 0; attempt < memoWriteRetries; attempt++ {
+		current, err := authorizeMemo(ctx, userID, memoID, models.RoleEditor)
+		if err != nil {
+			return nil, err
+		}
+		if current.Type != models.MemoTypeChecklist {
+			return nil, errors.New("该备忘录不是清单")
+		}
+
+		items, err := mutate(append([]models.ChecklistItem{}, current.Items...))
+		if err != nil {
+			return nil, err
+		}
+
+		seq, err := database.NextSequence(ctx, memoSyncCounter, 1)
+		if err != nil {
+			return nil, err
+		}
+
+		set := s.checklistFields(items)
+		set["updated_at"] = time.Now()
+		set["sync_seq"] = seq
+
+		filter := memoVersionFilter(current)
+		filter["deleted_at"] = nil
+
+		var memo models.Memo
+		err = collection.FindOneAndUpdate(ctx, filter, bson.M{"$set": set},
+			options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&memo)
+		if err == mongo.ErrNoDocuments {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		memo.Role = current.Role
+		queueEmbedding(memo.ID)
+
+		Events.publishMemoLocal(memo.UserID, memo.ID, models.EventMemoUpdated, memo)
+		return &memo, nil
+	}
+	return nil, errors.New("清单正在被其他人修改，请重试")
+}
+
+// 添加清单条目
+func (s *MemoService) AddChecklistItem(userID, memoID primitive.ObjectID, req *models.AddChecklistItemRequest) (*models.Memo, error) {
+	return s.updateChecklist(userID, memoID, func(items []models.ChecklistItem) ([]models.ChecklistItem, error) {
+		if len(items) >= maxChecklistItems {
+			return nil, errors.New("清单条目数量超过上限")
+		}
+		items = append(items, models.ChecklistItem{
+			ID:      primitive.NewObjectID(),
+			Text:    req.Text,
+			Checked: req.Checked,
+		})
+		if req.Position != nil {
+			items = moveChecklistItem(items, len(items)-1, *req.Position)
+		}
+		return items, nil
+	})
+}
+
+// 修改清单条目的文本、勾选状态或位置
+func (s *MemoService) UpdateChecklistItem(userID, memoID, itemID primitive.ObjectID, req *models.UpdateChecklistItemRequest) (*models.Memo, error) {
+	return s.updateChecklist(userID, memoID, func(items []models.ChecklistItem) ([]models.ChecklistItem, error) {
+		index := findChecklistItem(items, itemID)
+		if index < 0 {
+			return nil, errors.New("清单条目不存在")
+		}
+		if req.Text != nil {
+			items[index].Text = *req.Text
+		}
+		if req.Checked != nil {
+			items[index].Checked = *req.Checked
+		}
+		if req.Position != nil {
+			items = moveChecklistItem(items, index, *req.Position)
+		}
+		return items, nil
+	})
+}
+
+// 切换清单条目的勾选状态
+func (s *MemoService) ToggleChecklistItem(userID, memoID, itemID primitive.ObjectID) (*models.Memo, error) {
+	return s.updateChecklist(userID, memoID, func(items []models.ChecklistItem) ([]models.ChecklistItem, error) {
+		index := findChecklistItem(items, itemID)
+		if index < 0 {
+			return nil, errors.New("清单条目不存在")
+		}
+		items[index].Checked = !items[index].Checked
+		return items, nil
+	})
+}
+
+// 删除清单条目
+func (s *MemoService) DeleteChecklistItem(userID, memoID, itemID primitive.ObjectID) (*models.Memo, error) {
+	return s.updateChecklist(userID, memoID, func(items []models.ChecklistItem) ([]models.ChecklistItem, error) {
+		index := findChecklistItem(items, itemID)
+		if index < 0 {
+			return nil, errors.New("清单条目不存在")
+		}
+		return append(items[:index], items[index+1:]...), nil
+	})
+}
+
+// 按给定的条目ID顺序重新排列清单，ID列表必须与当前条目完全一致
+func (s *MemoService) ReorderChecklist(userID, memoID primitive.ObjectID, itemIDs []primitive.ObjectID) (*models.Memo, error) {
+	return s.updateChecklist(userID, memoID, func(items []models.ChecklistItem) ([]models.ChecklistItem, error) {
+		if len(itemIDs) != len(items) {
+			return nil, errors.New("条目顺序与当前清单不一致")
+		}
+		reordered := make([]models.ChecklistItem, 0, len(items))
+		seen := make(map[primitive.ObjectID]bool, len(itemIDs))
+		for _, id := range itemIDs {
+			index := findChecklistItem(items, id)
+			if index < 0 || seen[id] {
+				return nil, errors.New("条目顺序与当前清单不一致")
+			}
+			seen[id] = true
+			reordered = append(reordered, items[index])
+		}
+		return reordered, nil
+	})
+}
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"mjbackend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// memoSortKey 列表排序字段
+type memoSortKey struct {
+	Field string
+	Desc  bool
+	// 布尔字段只存储 true，false 以字段缺失表示，比较时需特殊处理
+	Bool  bool
+	value func(m *models.Memo) interface{}
+}
+
+// memoListCursor 游标分页的位置信息，编码后作为不透明字符串返回给客户端
+type memoListCursor struct {
+	Sort   string             `bson:"s"`
+	Order  string             `bson:"o"`
+	Values bson.A             `bson:"v"`
+	ID     primitive.ObjectID `bson:"i"`
+}
+
+// 解析排序方式，返回排序字段（不含 _id）和实际生效的排序方向
+func memoSortKeys(sort, order string) ([]memoSortKey, string, error) {
+	if order != "" && order != "asc" && order != "desc" {
+		return nil, "", errors.New("无效的排序方向")
+	}
+
+	createdAt := func(m *models.Memo) interface{} { return m.CreatedAt }
+	switch sort {
+	case "", "created":
+		if order == "" {
+			order = "desc"
+		}
+		return []memoSortKey{{Field: "created_at", Desc: order == "desc", value: createdAt}}, order, nil
+	case "updated":
+		if order == "" {
+			order = "desc"
+		}
+		return []memoSortKey{{Field: "updated_at", Desc: order == "desc", value: func(m *models.Memo) interface{} { return m.UpdatedAt }}}, order, nil
+	case "title":
+		if order == "" {
+			order = "asc"
+		}
+		return []memoSortKey{{Field: "title", Desc: order == "desc", value: func(m *models.Memo) interface{} { return m.Title }}}, order, nil
+	case "pinned":
+		// 置顶优先，同组内按创建时间排序
+		if order == "" {
+			order = "desc"
+		}
+		return []memoSortKey{
+			{Field: "pinned", Desc: true, Bool: true, value: func(m *models.Memo) interface{} { return m.Pinned }},
+			{Field: "created_at", Desc: order == "desc", value: createdAt},
+		}, order, nil
+	default:
+		return nil, "", errors.New("无效的排序方式")
+	}
+}
+
+// 生成排序条件，以 _id 作为最终排序键保证顺序稳定
+func memoSortSpec(keys []memoSortKey) bson.D {
+	spec := bson.D{}
+	for _, key := range keys {
+		spec = append(spec, bson.E{Key: key.Field, Value: direction(key.Desc)})
+	}
+	return append(spec, bson.E{Key: "_id", Value: direction(keys[len(keys)-1].Desc)})
+}
+
+func direction(desc bool) int {
+	if desc {
+		return -1
+	}
+	return 1
+}
+
+// 生成游标之后的查询条件：(k1, k2, ..., _id) 按字典序位于游标之后
+func memoCursorFilter(keys []memoSortKey, cursor *memoListCursor) (bson.M, error) {
+	if len(cursor.Values) != len(keys) {
+		return nil, errors.New("无效的分页游标")
+	}
+	for i, key := range keys {
+		if !validCursorValue(key, cursor.Values[i]) {
+			return nil, errors.New("无效的分页游标")
+		}
+	}
+
+	idKey := memoSortKey{Field: "_id", Desc: keys[len(keys)-1].Desc}
+	allKeys := append(append([]memoSortKey{}, keys...), idKey)
+	values := append(append(bson.A{}, cursor.Values...), cursor.ID)
+
+	var clauses []bson.M
+	for i := range allKeys {
+		clause := bson.M{}
+		for j := 0; j < i; j++ {
+			clause[allKeys[j].Field] = equalCondition(allKeys[j], values[j])
+		}
+		after, ok := afterCondition(allKeys[i], values[i])
+		if !ok {
+			continue
+		}
+		clause[allKeys[i].Field] = after
+		clauses = append(clauses, clause)
+	}
+
+	if len(clauses) == 0 {
+		// 游标已位于最后一组，不会再有数据
+		return bson.M{"_id": bson.M{"$exists": false}}, nil
+	}
+	return bson.M{"$or": clauses}, nil
+}
+
+// validCursorValue 检查游标中的值与排序字段的类型一致。游标由客户端传回，
+// 值会直接写入查询条件，不能是文档、数组等可以携带查询操作符的类型
+func validCursorValue(key memoSortKey, value interface{}) bool {
+	var ok bool
+	switch key.value(&models.Memo{}).(type) {
+	case time.Time:
+		_, ok = value.(primitive.DateTime)
+	case string:
+		_, ok = value.(string)
+	case bool:
+		_, ok = value.(bool)
+	case primitive.ObjectID:
+		_, ok = value.(primitive.ObjectID)
+	}
+	return ok
+}
+
+func equalCondition(key memoSortKey, value interface{}) interface{} {
+	if key.Bool && value != true {
+		return bson.M{"$ne": true}
+	}
+	return value
+}
+
+// 返回严格位于 value 之后的条件，第二个返回值为 false 表示不存在这样的值
+func afterCondition(key memoSortKey, value interface{}) (interface{}, bool) {
+	if key.Bool {
+		isTrue := value == true
+		switch {
+		case key.Desc && isTrue:
+			return bson.M{"$ne": true}, true
+		case !key.Desc && !isTrue:
+			return true, true
+		default:
+			return nil, false
+		}
+	}
+	if key.Desc {
+		return bson.M{"$lt": value}, true
+	}
+	return bson.M{"$gt": value}, true
+}
+
+func encodeMemoCursor(sort, order string, keys []memoSortKey, memo *models.Memo) (string, error) {
+	values := bson.A{}
+	for _, key := range keys {
+		values = append(values, key.value(memo))
+	}
+	data, err := bson.Marshal(memoListCursor{Sort: sort, Order: order, Values: values, ID: memo.ID})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeMemoCursor(encoded, sort, order string) (*memoListCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errors.New("无效的分页游标")
+	}
+	var cursor memoListCursor
+	if err := bson.Unmarshal(data, &cursor); err != nil {
+		return nil, errors.New("无效的分页游标")
+	}
+	if cursor.Sort != sort || cursor.Order != order {
+		return nil, errors.New("分页游标与排序方式不匹配")
+	}
+	return &cursor, nil
+}
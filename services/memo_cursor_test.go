@@ -0,0 +1,156 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"mjbackend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestMemoCursorRoundTrip(t *testing.T) {
+	created := time.Date(2024, 3, 1, 10, 30, 0, 0, time.UTC)
+	memo := &models.Memo{
+		ID:        primitive.NewObjectID(),
+		Title:     "购物清单",
+		Pinned:    true,
+		CreatedAt: created,
+		UpdatedAt: created.Add(time.Hour),
+	}
+
+	tests := []struct {
+		sort  string
+		order string
+		want  bson.A
+	}{
+		{sort: "created", order: "desc", want: bson.A{primitive.NewDateTimeFromTime(created)}},
+		{sort: "updated", order: "asc", want: bson.A{primitive.NewDateTimeFromTime(created.Add(time.Hour))}},
+		{sort: "title", order: "asc", want: bson.A{"购物清单"}},
+		{sort: "pinned", order: "desc", want: bson.A{true, primitive.NewDateTimeFromTime(created)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sort+"_"+tt.order, func(t *testing.T) {
+			keys, order, err := memoSortKeys(tt.sort, tt.order)
+			if err != nil {
+				t.Fatalf("memoSortKeys() error = %v", err)
+			}
+			encoded, err := encodeMemoCursor(tt.sort, order, keys, memo)
+			if err != nil {
+				t.Fatalf("encodeMemoCursor() error = %v", err)
+			}
+
+			cursor, err := decodeMemoCursor(encoded, tt.sort, order)
+			if err != nil {
+				t.Fatalf("decodeMemoCursor() error = %v", err)
+			}
+			if cursor.ID != memo.ID {
+				t.Errorf("ID = %v, want %v", cursor.ID, memo.ID)
+			}
+			if len(cursor.Values) != len(tt.want) {
+				t.Fatalf("Values = %v, want %v", cursor.Values, tt.want)
+			}
+			for i := range tt.want {
+				if cursor.Values[i] != tt.want[i] {
+					t.Errorf("Values[%d] = %#v, want %#v", i, cursor.Values[i], tt.want[i])
+				}
+			}
+			if _, err := memoCursorFilter(keys, cursor); err != nil {
+				t.Errorf("memoCursorFilter() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestDecodeMemoCursorErrors(t *testing.T) {
+	keys, _, _ := memoSortKeys("created", "desc")
+	encoded, err := encodeMemoCursor("created", "desc", keys, &models.Memo{ID: primitive.NewObjectID()})
+	if err != nil {
+		t.Fatalf("encodeMemoCursor() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		cursor  string
+		sort    string
+		order   string
+		wantErr string
+	}{
+		{name: "不是Base64", cursor: "!!!", sort: "created", order: "desc", wantErr: "无效的分页游标"},
+		{name: "不是BSON", cursor: "YWJj", sort: "created", order: "desc", wantErr: "无效的分页游标"},
+		{name: "排序方式不匹配", cursor: encoded, sort: "title", order: "desc", wantErr: "分页游标与排序方式不匹配"},
+		{name: "排序方向不匹配", cursor: encoded, sort: "created", order: "asc", wantErr: "分页游标与排序方式不匹配"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decodeMemoCursor(tt.cursor, tt.sort, tt.order)
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("decodeMemoCursor() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMemoCursorFilter(t *testing.T) {
+	keys, _, _ := memoSortKeys("pinned", "desc")
+	created := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		pinned bool
+		// 游标之后的条件分支数：置顶组内还可以翻到未置顶组，未置顶组只能在组内继续
+		want int
+	}{
+		{name: "置顶组", pinned: true, want: 3},
+		{name: "未置顶组", pinned: false, want: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cursor := &memoListCursor{Values: bson.A{tt.pinned, primitive.NewDateTimeFromTime(created)}, ID: primitive.NewObjectID()}
+			filter, err := memoCursorFilter(keys, cursor)
+			if err != nil {
+				t.Fatalf("memoCursorFilter() error = %v", err)
+			}
+			clauses, ok := filter["$or"].([]bson.M)
+			if !ok || len(clauses) != tt.want {
+				t.Errorf("filter = %v, want %d 个条件分支", filter, tt.want)
+			}
+		})
+	}
+
+	if _, err := memoCursorFilter(keys, &memoListCursor{Values: bson.A{true}}); err == nil {
+		t.Error("游标值数量与排序字段不一致时应返回错误")
+	}
+}
+
+func TestMemoCursorFilterRejectsInvalidValues(t *testing.T) {
+	created := primitive.NewDateTimeFromTime(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))
+	tests := []struct {
+		name   string
+		sort   string
+		values bson.A
+	}{
+		{name: "时间字段为查询操作符", sort: "created", values: bson.A{bson.D{{Key: "$ne", Value: nil}}}},
+		{name: "时间字段为字符串", sort: "updated", values: bson.A{"2024-03-01"}},
+		{name: "标题字段为正则", sort: "title", values: bson.A{primitive.Regex{Pattern: ".*"}}},
+		{name: "标题字段为数组", sort: "title", values: bson.A{bson.A{"a"}}},
+		{name: "置顶字段为文档", sort: "pinned", values: bson.A{bson.D{{Key: "$exists", Value: true}}, created}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, _, err := memoSortKeys(tt.sort, "")
+			if err != nil {
+				t.Fatalf("memoSortKeys() error = %v", err)
+			}
+			_, err = memoCursorFilter(keys, &memoListCursor{Values: tt.values, ID: primitive.NewObjectID()})
+			if err == nil || err.Error() != "无效的分页游标" {
+				t.Errorf("memoCursorFilter() error = %v, want 无效的分页游标", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package services
+
+import (
+	"mjbackend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// 通知等服务端生成的文本中代替加密备忘录的标题
+const encryptedMemoTitle = "加密备忘录"
+
+// encryptedMemoFields 以密文替换备忘录内容的更新字段：清空明文标题、正文、摘要和全文索引，
+// 删除清单条目和AI摘要，使加密备忘录不会出现在搜索结果中
+func (s *MemoService) encryptedMemoFields(envelope *models.MemoEnvelope) (bson.M, bson.M) {
+	set := s.searchFields("", "")
+	set["title"] = ""
+	set["content"] = ""
+	set["encrypted"] = envelope
+	unset := bson.M{
+		"type":         "",
+		"items":        "",
+		"progress":     "",
+		"search_items": "",
+		"summary":      "",
+	}
+	return set, unset
+}
+
+// memoDisplayTitle 返回用于通知的标题，加密备忘录的标题服务端不可见
+func memoDisplayTitle(memo *models.Memo) string {
+	if memo.Encrypted != nil {
+		return encryptedMemoTitle
+	}
+	return memo.Title
+}
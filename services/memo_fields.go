@@ -0,0 +1,107 @@
+package services
+
+import (
+	"errors"
+
+	"mjbackend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// memoField 列表可选择返回的字段，名称与响应中的 JSON 字段一致
+type memoField struct {
+	Field string
+	value func(m *models.Memo) interface{}
+}
+
+var memoFields = map[string]memoField{
+	"id":         {Field: "_id", value: func(m *models.Memo) interface{} { return m.ID }},
+	"title":      {Field: "title", value: func(m *models.Memo) interface{} { return m.Title }},
+	"content":    {Field: "content", value: func(m *models.Memo) interface{} { return m.Content }},
+	"excerpt":    {Field: "excerpt", value: func(m *models.Memo) interface{} { return m.Excerpt }},
+	"createTime": {Field: "created_at", value: func(m *models.Memo) interface{} { return m.CreatedAt }},
+	"updateTime": {Field: "updated_at", value: func(m *models.Memo) interface{} { return m.UpdatedAt }},
+	"version":    {Field: "sync_seq", value: func(m *models.Memo) interface{} { return m.SyncSeq }},
+	"pinned":     {Field: "pinned", value: func(m *models.Memo) interface{} { return m.Pinned }},
+	"archived":   {Field: "archived", value: func(m *models.Memo) interface{} { return m.Archived }},
+	"favorite":   {Field: "favorite", value: func(m *models.Memo) interface{} { return m.Favorite }},
+	"type":       {Field: "type", value: func(m *models.Memo) interface{} { return m.Type }},
+	"items":      {Field: "items", value: func(m *models.Memo) interface{} { return m.Items }},
+	"progress":   {Field: "progress", value: func(m *models.Memo) interface{} { return m.Progress }},
+	"tags":       {Field: "tags", value: func(m *models.Memo) interface{} { return m.Tags }},
+	"folder":     {Field: "folder", value: func(m *models.Memo) interface{} { return m.Folder }},
+	"dueAt":      {Field: "due_at", value: func(m *models.Memo) interface{} { return m.DueAt }},
+	"reminder":   {Field: "reminder", value: func(m *models.Memo) interface{} { return m.Reminder }},
+	"encrypted":  {Field: "encrypted", value: func(m *models.Memo) interface{} { return m.Encrypted }},
+}
+
+// 摘要字段集：列表页展示所需的标题、摘要、时间和状态标记，不含正文和清单条目，未指定返回字段时默认使用
+const memoSummaryFields = "summary"
+
+// 返回完整备忘录，包含正文和清单条目
+const memoAllFields = "all"
+
+var memoSummaryFieldNames = []string{
+	"id", "title", "excerpt", "createTime", "updateTime", "version",
+	"pinned", "archived", "favorite", "type", "progress", "tags", "folder", "dueAt",
+	// 加密备忘录的标题在密文中，客户端需要密文才能展示
+	"encrypted",
+}
+
+// memoFieldSelection 解析要返回的字段，未指定时为摘要字段集，summary 表示摘要字段集；id 总是返回。
+// 返回 nil 表示返回完整备忘录，只能通过 all 显式指定，避免列表默认返回大段正文
+func memoFieldSelection(names []string) ([]string, error) {
+	if len(names) == 0 {
+		names = []string{memoSummaryFields}
+	}
+	if len(names) == 1 && names[0] == memoAllFields {
+		return nil, nil
+	}
+
+	selected := []string{"id"}
+	seen := map[string]bool{"id": true}
+	for _, name := range names {
+		if name == memoSummaryFields {
+			for _, summary := range memoSummaryFieldNames {
+				if !seen[summary] {
+					seen[summary] = true
+					selected = append(selected, summary)
+				}
+			}
+			continue
+		}
+		if _, ok := memoFields[name]; !ok {
+			return nil, errors.New("无效的返回字段: " + name)
+		}
+		if !seen[name] {
+			seen[name] = true
+			selected = append(selected, name)
+		}
+	}
+	return selected, nil
+}
+
+// memoFieldProjection 生成查询投影，额外包含排序字段以便生成分页游标
+func memoFieldProjection(fields []string, sortKeys []memoSortKey) bson.M {
+	projection := bson.M{}
+	for _, name := range fields {
+		projection[memoFields[name].Field] = 1
+	}
+	for _, key := range sortKeys {
+		projection[key.Field] = 1
+	}
+	return projection
+}
+
+// selectMemoFields 只输出选中的字段，未设置的字段也会以零值或 null 返回
+func selectMemoFields(memos []models.Memo, fields []string) []map[string]interface{} {
+	list := make([]map[string]interface{}, 0, len(memos))
+	for i := range memos {
+		item := make(map[string]interface{}, len(fields))
+		for _, name := range fields {
+			item[name] = memoFields[name].value(&memos[i])
+		}
+		list = append(list, item)
+	}
+	return list
+}
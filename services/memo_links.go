@@ -0,0 +1,457 @@
+package services
+
+import (
+	"context"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"mjbackend/database"
+	"mjbackend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// 单条备忘录最多索引的链接数
+const maxMemoLinks = 200
+
+// 链接语法：[[目标]] 或 [[目标|显示文本]]，目标为备忘录标题或备忘录ID
+var wikiLinkPattern = regexp.MustCompile(`\[\[([^\[\]|\n]+)(\|[^\[\]\n]*)?\]\]`)
+
+// wikiRef 正文中解析出的一个链接目标
+type wikiRef struct {
+	kind string
+	ref  string
+	text string
+}
+
+// linkKey 标题的比较键：忽略大小写，合并连续空白
+func linkKey(title string) string {
+	return strings.ToLower(strings.Join(strings.Fields(title), " "))
+}
+
+// parseWikiLinks 解析正文中的链接，同一目标只保留一次
+func parseWikiLinks(content string) []wikiRef {
+	var refs []wikiRef
+	seen := make(map[string]bool)
+	for _, match := range wikiLinkPattern.FindAllStringSubmatch(content, -1) {
+		text := strings.TrimSpace(match[1])
+		ref := wikiRef{kind: models.LinkByTitle, ref: linkKey(text), text: text}
+		if id, err := primitive.ObjectIDFromHex(text); err == nil {
+			ref = wikiRef{kind: models.LinkByID, ref: id.Hex(), text: text}
+		}
+		if ref.ref == "" || seen[ref.kind+":"+ref.ref] {
+			continue
+		}
+		seen[ref.kind+":"+ref.ref] = true
+		refs = append(refs, ref)
+		if len(refs) >= maxMemoLinks {
+			break
+		}
+	}
+	return refs
+}
+
+// refreshMemoLinks 在备忘录创建、修改或删除后维护链接索引。链接索引由正文派生，
+// 失败时只记录日志，不影响备忘录本身的写入
+func (s *MemoService) refreshMemoLinks(ctx context.Context, memo *models.Memo) {
+	// 加密备忘录的标题和正文不可见，与已删除的备忘录一样不参与链接
+	if memo.DeletedAt != nil || memo.Encrypted != nil {
+		unlinkMemo(ctx, memo.ID)
+		return
+	}
+	if err := indexOutgoingLinks(ctx, memo); err != nil {
+		log.Printf("更新备忘录 %s 的链接索引失败: %v", memo.ID.Hex(), err)
+	}
+	if err := s.renameIncomingLinks(ctx, memo); err != nil {
+		log.Printf("更新指向备忘录 %s 的链接失败: %v", memo.ID.Hex(), err)
+	}
+	if err := resolveIncomingLinks(ctx, memo); err != nil {
+		log.Printf("解析指向备忘录 %s 的链接失败: %v", memo.ID.Hex(), err)
+	}
+}
+
+// refreshBulkLinks 批量删除或恢复备忘录后维护链接索引
+func (s *MemoService) refreshBulkLinks(ctx context.Context, ids []primitive.ObjectID) {
+	if len(ids) == 0 {
+		return
+	}
+	cursor, err := database.GetCollection("memos").Find(ctx, bson.M{"_id": bson.M{"$in": ids}},
+		options.Find().SetProjection(bson.M{"_id": 1, "user_id": 1, "title": 1, "content": 1, "deleted_at": 1, "encrypted.v": 1}))
+	if err != nil {
+		log.Printf("查询批量操作的备忘录失败: %v", err)
+		return
+	}
+	var memos []models.Memo
+	if err := cursor.All(ctx, &memos); err != nil {
+		log.Printf("查询批量操作的备忘录失败: %v", err)
+		return
+	}
+	for i := range memos {
+		s.refreshMemoLinks(ctx, &memos[i])
+		queueEmbedding(memos[i].ID)
+	}
+}
+
+// indexOutgoingLinks 重新解析备忘录正文中的链接并替换其全部出链
+func indexOutgoingLinks(ctx context.Context, memo *models.Memo) error {
+	collection := database.GetCollection("memo_links")
+	refs := parseWikiLinks(memo.Content)
+
+	targets, err := resolveLinkTargets(ctx, memo.UserID, refs)
+	if err != nil {
+		return err
+	}
+
+	if _, err := collection.DeleteMany(ctx, bson.M{"source_id": memo.ID}); err != nil {
+		return err
+	}
+	if len(refs) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	docs := make([]interface{}, 0, len(refs))
+	for _, ref := range refs {
+		link := models.MemoLink{
+			UserID:    memo.UserID,
+			SourceID:  memo.ID,
+			Kind:      ref.kind,
+			Ref:       ref.ref,
+			Text:      ref.text,
+			CreatedAt: now,
+		}
+		// 指向自身的链接不计入反向链接
+		if target, ok := targets[ref.kind+":"+ref.ref]; ok && target != memo.ID {
+			link.TargetID = &target
+		}
+		docs = append(docs, link)
+	}
+	_, err = collection.InsertMany(ctx, docs)
+	return err
+}
+
+// resolveLinkTargets 在同一用户未删除的备忘录中查找链接目标，标题重复时取最早创建的备忘录
+func resolveLinkTargets(ctx context.Context, userID primitive.ObjectID, refs []wikiRef) (map[string]primitive.ObjectID, error) {
+	targets := make(map[string]primitive.ObjectID)
+	var ids []primitive.ObjectID
+	var titles []string
+	for _, ref := range refs {
+		if ref.kind == models.LinkByID {
+			id, _ := primitive.ObjectIDFromHex(ref.ref)
+			ids = append(ids, id)
+		} else {
+			titles = append(titles, ref.ref)
+		}
+	}
+
+	var or []bson.M
+	if len(ids) > 0 {
+		or = append(or, bson.M{"_id": bson.M{"$in": ids}})
+	}
+	if len(titles) > 0 {
+		// 链接引用已按 linkKey 归一化，与 title_lower 精确匹配可以使用索引
+		or = append(or, bson.M{"title_lower": bson.M{"$in": titles}})
+	}
+	if len(or) == 0 {
+		return targets, nil
+	}
+
+	cursor, err := database.GetCollection("memos").Find(ctx,
+		bson.M{"user_id": userID, "deleted_at": nil, "$or": or},
+		options.Find().
+			SetProjection(bson.M{"_id": 1, "title": 1}).
+			SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	var memos []models.Memo
+	if err = cursor.All(ctx, &memos); err != nil {
+		return nil, err
+	}
+	// 按创建时间倒序遍历，同名时较早创建的备忘录最后写入
+	for _, memo := range memos {
+		targets[models.LinkByID+":"+memo.ID.Hex()] = memo.ID
+		targets[models.LinkByTitle+":"+linkKey(memo.Title)] = memo.ID
+	}
+	return targets, nil
+}
+
+// resolveIncomingLinks 将尚未解析、指向该备忘录标题或ID的链接关联到该备忘录
+func resolveIncomingLinks(ctx context.Context, memo *models.Memo) error {
+	_, err := database.GetCollection("memo_links").UpdateMany(ctx, bson.M{
+		"user_id":   memo.UserID,
+		"target_id": nil,
+		"source_id": bson.M{"$ne": memo.ID},
+		"$or": []bson.M{
+			{"kind": models.LinkByTitle, "ref": linkKey(memo.Title)},
+			{"kind": models.LinkByID, "ref": memo.ID.Hex()},
+		},
+	}, bson.M{"$set": bson.M{"target_id": memo.ID}})
+	return err
+}
+
+// unlinkMemo 删除备忘录的出链，并将指向它的链接标记为未解析，恢复后会重新关联
+func unlinkMemo(ctx context.Context, memoID primitive.ObjectID) {
+	collection := database.GetCollection("memo_links")
+	if _, err := collection.DeleteMany(ctx, bson.M{"source_id": memoID}); err != nil {
+		log.Printf("删除备忘录 %s 的链接索引失败: %v", memoID.Hex(), err)
+	}
+	if _, err := collection.UpdateMany(ctx, bson.M{"target_id": memoID},
+		bson.M{"$set": bson.M{"target_id": nil}}); err != nil {
+		log.Printf("解除指向备忘录 %s 的链接失败: %v", memoID.Hex(), err)
+	}
+}
+
+// renameIncomingLinks 备忘录改名后，将其他备忘录正文中按旧标题指向它的链接改写为新标题。
+// 新标题包含链接语法字符时改写为按ID链接
+func (s *MemoService) renameIncomingLinks(ctx context.Context, memo *models.Memo) error {
+	newKey := linkKey(memo.Title)
+	cursor, err := database.GetCollection("memo_links").Find(ctx, bson.M{
+		"target_id": memo.ID,
+		"kind":      models.LinkByTitle,
+		"ref":       bson.M{"$ne": newKey},
+	})
+	if err != nil {
+		return err
+	}
+	var links []models.MemoLink
+	if err = cursor.All(ctx, &links); err != nil {
+		return err
+	}
+
+	target := memo.Title
+	if strings.ContainsAny(target, "[]|\n") {
+		target = memo.ID.Hex()
+	}
+
+	oldKeys := make(map[primitive.ObjectID]map[string]bool)
+	for _, link := range links {
+		if oldKeys[link.SourceID] == nil {
+			oldKeys[link.SourceID] = make(map[string]bool)
+		}
+		oldKeys[link.SourceID][link.Ref] = true
+	}
+
+	for sourceID, keys := range oldKeys {
+		if err := s.rewriteLinks(ctx, sourceID, keys, target); err != nil {
+			log.Printf("改写备忘录 %s 中的链接失败: %v", sourceID.Hex(), err)
+		}
+	}
+	return nil
+}
+
+// rewriteLinks 将备忘录正文中指向 oldKeys 的标题链接替换为 target，保留显示文本。
+// 只在正文未被并发修改时写入，不修改更新时间
+func (s *MemoService) rewriteLinks(ctx context.Context, memoID primitive.ObjectID, oldKeys map[string]bool, target string) error {
+	collection := database.GetCollection("memos")
+
+	var source models.Memo
+	if err := collection.FindOne(ctx, bson.M{"_id": memoID, "deleted_at": nil}).Decode(&source); err != nil {
+		return err
+	}
+	content := wikiLinkPattern.ReplaceAllStringFunc(source.Content, func(match string) string {
+		sub := wikiLinkPattern.FindStringSubmatch(match)
+		if !oldKeys[linkKey(sub[1])] {
+			return match
+		}
+		return "[[" + target + sub[2] + "]]"
+	})
+	if content == source.Content {
+		return nil
+	}
+
+	seq, err := database.NextSequence(ctx, memoSyncCounter, 1)
+	if err != nil {
+		return err
+	}
+	set := s.searchFields(source.Title, content)
+	set["content"] = content
+	set["sync_seq"] = seq
+
+	var memo models.Memo
+	err = collection.FindOneAndUpdate(ctx,
+		bson.M{"_id": memoID, "deleted_at": nil, "content": source.Content},
+		bson.M{"$set": set},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&memo)
+	if err != nil {
+		return err
+	}
+	// 正文变化后语义搜索向量也需要更新
+	queueEmbedding(memo.ID)
+
+	Events.publishMemoLocal(memo.UserID, memo.ID, models.EventMemoUpdated, memo)
+	return indexOutgoingLinks(ctx, &memo)
+}
+
+// 获取链接到该备忘录的其他备忘录，协作者只能看到自己有权访问的备忘录
+func (s *MemoService) GetBacklinks(userID, memoID primitive.ObjectID) ([]models.LinkedMemo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	memo, err := authorizeMemo(ctx, userID, memoID, models.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := database.GetCollection("memo_links").Find(ctx, bson.M{"target_id": memoID})
+	if err != nil {
+		return nil, err
+	}
+	var links []models.MemoLink
+	if err = cursor.All(ctx, &links); err != nil {
+		return nil, err
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(links))
+	for _, link := range links {
+		ids = append(ids, link.SourceID)
+	}
+	sources, err := visibleLinkedMemos(ctx, userID, memo.UserID, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []models.LinkedMemo{}
+	for _, id := range ids {
+		if source, ok := sources[id]; ok {
+			result = append(result, source)
+		}
+	}
+	return result, nil
+}
+
+// 获取备忘录正文中的链接及其目标，目标不存在或无权访问时不返回目标信息
+func (s *MemoService) GetOutgoingLinks(userID, memoID primitive.ObjectID) ([]models.OutgoingLink, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	memo, err := authorizeMemo(ctx, userID, memoID, models.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := database.GetCollection("memo_links").Find(ctx, bson.M{"source_id": memoID},
+		options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	var links []models.MemoLink
+	if err = cursor.All(ctx, &links); err != nil {
+		return nil, err
+	}
+
+	var ids []primitive.ObjectID
+	for _, link := range links {
+		if link.TargetID != nil {
+			ids = append(ids, *link.TargetID)
+		}
+	}
+	targets, err := visibleLinkedMemos(ctx, userID, memo.UserID, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]models.OutgoingLink, 0, len(links))
+	for _, link := range links {
+		outgoing := models.OutgoingLink{Text: link.Text, Kind: link.Kind}
+		if link.TargetID != nil {
+			if target, ok := targets[*link.TargetID]; ok {
+				outgoing.Memo = &target
+			}
+		}
+		result = append(result, outgoing)
+	}
+	return result, nil
+}
+
+// visibleLinkedMemos 查询链接另一端的备忘录摘要。链接只存在于同一用户的备忘录之间，
+// 所有者可以看到全部，协作者只能看到同样共享给自己的备忘录
+func visibleLinkedMemos(ctx context.Context, userID, ownerID primitive.ObjectID, ids []primitive.ObjectID) (map[primitive.ObjectID]models.LinkedMemo, error) {
+	result := make(map[primitive.ObjectID]models.LinkedMemo)
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	if userID != ownerID {
+		cursor, err := database.GetCollection("memo_members").Find(ctx, bson.M{
+			"memo_id": bson.M{"$in": ids},
+			"user_id": userID,
+			"status":  models.MemberAccepted,
+		})
+		if err != nil {
+			return nil, err
+		}
+		var members []models.MemoMember
+		if err = cursor.All(ctx, &members); err != nil {
+			return nil, err
+		}
+		ids = make([]primitive.ObjectID, 0, len(members))
+		for _, member := range members {
+			ids = append(ids, member.MemoID)
+		}
+		if len(ids) == 0 {
+			return result, nil
+		}
+	}
+
+	cursor, err := database.GetCollection("memos").Find(ctx,
+		bson.M{"_id": bson.M{"$in": ids}, "user_id": ownerID, "deleted_at": nil},
+		options.Find().SetProjection(bson.M{"_id": 1, "title": 1, "updated_at": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var memos []models.Memo
+	if err = cursor.All(ctx, &memos); err != nil {
+		return nil, err
+	}
+	for _, memo := range memos {
+		result[memo.ID] = models.LinkedMemo{ID: memo.ID, Title: memo.Title, UpdatedAt: memo.UpdatedAt}
+	}
+	return result, nil
+}
+
+// BackfillMemoLinks 链接索引为空时为已有备忘录建立索引，用于链接功能上线后的首次启动
+func (s *MemoService) BackfillMemoLinks() {
+	ctx := context.Background()
+
+	count, err := database.GetCollection("memo_links").CountDocuments(ctx, bson.M{})
+	if err != nil {
+		log.Printf("查询链接索引失败: %v", err)
+		return
+	}
+	if count > 0 {
+		return
+	}
+
+	cursor, err := database.GetCollection("memos").Find(ctx, bson.M{
+		"deleted_at": nil,
+		"content":    primitive.Regex{Pattern: `\[\[`},
+	}, options.Find().SetProjection(bson.M{"_id": 1, "user_id": 1, "content": 1}))
+	if err != nil {
+		log.Printf("查询待索引备忘录失败: %v", err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	indexed := 0
+	for cursor.Next(ctx) {
+		var memo models.Memo
+		if err := cursor.Decode(&memo); err != nil {
+			log.Printf("解析备忘录失败: %v", err)
+			continue
+		}
+		if err := indexOutgoingLinks(ctx, &memo); err != nil {
+			log.Printf("建立备忘录 %s 的链接索引失败: %v", memo.ID.Hex(), err)
+			continue
+		}
+		indexed++
+	}
+	if indexed > 0 {
+		log.Printf("已为 %d 条备忘录建立链接索引", indexed)
+	}
+}
@@ -0,0 +1,466 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"mjbackend/database"
+	"mjbackend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// 合并补丁中只读、可以出现但不会被修改的字段
+var memoPatchReadOnly = map[string]bool{
+	"id": true, "createTime": true, "updateTime": true, "deletedAt": true, "role": true, "progress": true,
+}
+
+// MemoPatch 由 JSON Merge Patch（RFC 7396）解析出的备忘录修改，未出现的字段保持不变
+type MemoPatch struct {
+	title    *string
+	content  *string
+	memoType *string
+
+	items    []models.ChecklistItem
+	itemsSet bool
+
+	pinned   *bool
+	archived *bool
+	favorite *bool
+
+	tags    []string
+	tagsSet bool
+	folder  *string
+
+	dueAt    *time.Time
+	dueAtSet bool
+
+	// reminder 为 nil 且 reminderSet 为 true 表示清除提醒，否则按字段合并到当前提醒
+	reminder    map[string]json.RawMessage
+	reminderSet bool
+
+	// encrypted 为 nil 且 encryptedSet 为 true 表示解除加密，此时需同时提供明文标题
+	encrypted    *models.MemoEnvelope
+	encryptedSet bool
+
+	// version 不为空时要求备忘录当前版本与之相同
+	version *int64
+}
+
+// 合并补丁中的清单条目，id 为空表示新条目
+type checklistItemPatch struct {
+	ID      string `json:"id"`
+	Text    string `json:"text"`
+	Checked bool   `json:"checked"`
+}
+
+// 是否包含需要所有者权限的字段（状态标记、标签和文件夹只对所有者生效）
+func (p *MemoPatch) ownerOnly() bool {
+	return p.pinned != nil || p.archived != nil || p.favorite != nil || p.tagsSet || p.folder != nil
+}
+
+// 是否修改明文内容
+func (p *MemoPatch) hasPlaintext() bool {
+	return p.title != nil || p.content != nil || p.memoType != nil || p.itemsSet
+}
+
+// ParseMemoPatch 解析并校验合并补丁，字段值为 null 表示删除该字段（恢复默认值）
+func ParseMemoPatch(data []byte) (*MemoPatch, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil || fields == nil {
+		return nil, errors.New("请求体必须是JSON对象")
+	}
+
+	patch := &MemoPatch{}
+	for key, raw := range fields {
+		isNull := bytes.Equal(bytes.TrimSpace(raw), []byte("null"))
+		var err error
+		switch key {
+		case "title":
+			var title string
+			if isNull || json.Unmarshal(raw, &title) != nil || strings.TrimSpace(title) == "" {
+				return nil, errors.New("title 必须是非空字符串")
+			}
+			patch.title = &title
+		case "content":
+			var content string
+			if !isNull {
+				if json.Unmarshal(raw, &content) != nil {
+					return nil, errors.New("content 必须是字符串")
+				}
+			}
+			patch.content = &content
+		case "type":
+			var memoType string
+			if !isNull {
+				if json.Unmarshal(raw, &memoType) != nil || (memoType != models.MemoTypeNote && memoType != models.MemoTypeChecklist) {
+					return nil, errors.New("type 必须是 note 或 checklist")
+				}
+			}
+			patch.memoType = &memoType
+		case "items":
+			patch.itemsSet = true
+			if !isNull {
+				patch.items, err = parseChecklistItemsPatch(raw)
+			}
+		case "pinned":
+			patch.pinned, err = parseFlagPatch(key, raw, isNull)
+		case "archived":
+			patch.archived, err = parseFlagPatch(key, raw, isNull)
+		case "favorite":
+			patch.favorite, err = parseFlagPatch(key, raw, isNull)
+		case "tags":
+			patch.tagsSet = true
+			if !isNull {
+				patch.tags, err = parseTagsPatch(raw)
+			}
+		case "folder":
+			var folder string
+			if !isNull {
+				if json.Unmarshal(raw, &folder) != nil || utf8.RuneCountInString(folder) > 200 {
+					return nil, errors.New("folder 必须是不超过200个字符的字符串")
+				}
+			}
+			folder = strings.Trim(strings.TrimSpace(folder), "/")
+			patch.folder = &folder
+		case "dueAt":
+			patch.dueAtSet = true
+			if !isNull {
+				var dueAt time.Time
+				if json.Unmarshal(raw, &dueAt) != nil {
+					return nil, errors.New("dueAt 必须是RFC 3339格式的时间")
+				}
+				dueAt = dueAt.UTC()
+				patch.dueAt = &dueAt
+			}
+		case "reminder":
+			patch.reminderSet = true
+			if !isNull {
+				if json.Unmarshal(raw, &patch.reminder) != nil || patch.reminder == nil {
+					return nil, errors.New("reminder 必须是对象或 null")
+				}
+			}
+		case "encrypted":
+			patch.encryptedSet = true
+			if !isNull {
+				if json.Unmarshal(raw, &patch.encrypted) != nil || patch.encrypted == nil {
+					return nil, errors.New("encrypted 必须是密文信封对象或 null")
+				}
+				err = validateEnvelope(patch.encrypted)
+			}
+		case "version":
+			var version int64
+			if json.Unmarshal(raw, &version) != nil {
+				return nil, errors.New("version 必须是整数")
+			}
+			patch.version = &version
+		default:
+			if !memoPatchReadOnly[key] {
+				return nil, errors.New("不支持的字段: " + key)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return patch, nil
+}
+
+func parseFlagPatch(key string, raw json.RawMessage, isNull bool) (*bool, error) {
+	var value bool
+	if !isNull && json.Unmarshal(raw, &value) != nil {
+		return nil, errors.New(key + " 必须是布尔值")
+	}
+	return &value, nil
+}
+
+// 标签与批量打标签的限制一致：最多50个，每个不超过50个字符
+func parseTagsPatch(raw json.RawMessage) ([]string, error) {
+	var tags []string
+	if json.Unmarshal(raw, &tags) != nil {
+		return nil, errors.New("tags 必须是字符串数组")
+	}
+	tags = normalizeTags(tags)
+	if len(tags) > 50 {
+		return nil, errors.New("标签不能超过50个")
+	}
+	for _, tag := range tags {
+		if utf8.RuneCountInString(tag) > 50 {
+			return nil, errors.New("标签不能超过50个字符")
+		}
+	}
+	return tags, nil
+}
+
+// 清单条目整体替换，保留已有条目的ID，新条目生成新ID
+func parseChecklistItemsPatch(raw json.RawMessage) ([]models.ChecklistItem, error) {
+	var patches []checklistItemPatch
+	if json.Unmarshal(raw, &patches) != nil {
+		return nil, errors.New("items 必须是清单条目数组")
+	}
+	if len(patches) > maxChecklistItems {
+		return nil, errors.New("清单条目数量超过上限")
+	}
+
+	items := make([]models.ChecklistItem, 0, len(patches))
+	seen := make(map[primitive.ObjectID]bool, len(patches))
+	for i, patch := range patches {
+		if patch.Text == "" || utf8.RuneCountInString(patch.Text) > 1000 {
+			return nil, errors.New("清单条目文本不能为空且不能超过1000个字符")
+		}
+		id := primitive.NewObjectID()
+		if patch.ID != "" {
+			var err error
+			id, err = primitive.ObjectIDFromHex(patch.ID)
+			if err != nil || seen[id] {
+				return nil, errors.New("无效的清单条目ID")
+			}
+		}
+		seen[id] = true
+		items = append(items, models.ChecklistItem{ID: id, Text: patch.Text, Checked: patch.Checked, Position: i})
+	}
+	return items, nil
+}
+
+// PatchMemo 按合并补丁部分更新备忘录，只写入补丁中出现的字段。
+// 以同步版本号作为乐观锁，并发修改冲突时重新读取后重试
+func (s *MemoService) PatchMemo(userID, memoID primitive.ObjectID, patch *MemoPatch) (*models.Memo, error) {
+	collection := database.GetCollection("memos")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	minRole := models.RoleEditor
+	if patch.ownerOnly() {
+		minRole = models.RoleOwner
+	}
+
+	for attempt := 0; attempt < memoWriteRetries; attempt++ {
+		current, err := authorizeMemo(ctx, userID, memoID, minRole)
+		if err != nil {
+			return nil, err
+		}
+		if patch.version != nil && *patch.version != current.SyncSeq {
+			return nil, errors.New("备忘录已被修改")
+		}
+		if patch.encrypted != nil {
+			if err := checkMemoEnvelope(ctx, current.UserID, patch.encrypted); err != nil {
+				return nil, err
+			}
+		}
+
+		set, unset, err := s.patchUpdate(current, patch)
+		if err != nil {
+			return nil, err
+		}
+		// 空补丁不产生新版本
+		if len(set) == 0 && len(unset) == 0 {
+			return current, nil
+		}
+
+		seq, err := database.NextSequence(ctx, memoSyncCounter, 1)
+		if err != nil {
+			return nil, err
+		}
+		set["sync_seq"] = seq
+		update := bson.M{"$set": set}
+		if len(unset) > 0 {
+			update["$unset"] = unset
+		}
+
+		filter := memoVersionFilter(current)
+		filter["deleted_at"] = nil
+
+		var memo models.Memo
+		err = collection.FindOneAndUpdate(ctx, filter, update,
+			options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&memo)
+		if err == mongo.ErrNoDocuments {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		memo.Role = current.Role
+		if patch.title != nil || patch.content != nil || patch.encryptedSet {
+			s.refreshMemoLinks(ctx, &memo)
+			queueEmbedding(memo.ID)
+		}
+
+		Events.publishMemoLocal(memo.UserID, memo.ID, models.EventMemoUpdated, memo)
+		return &memo, nil
+	}
+	return nil, errors.New("备忘录正在被其他人修改，请重试")
+}
+
+// patchUpdate 根据当前备忘录和补丁生成更新语句。正文相关字段变化时更新修改时间和全文索引，
+// 状态标记、标签、文件夹和提醒与各自的接口一致，不修改更新时间
+func (s *MemoService) patchUpdate(current *models.Memo, patch *MemoPatch) (bson.M, bson.M, error) {
+	set := bson.M{}
+	unset := bson.M{}
+
+	switch {
+	case patch.encrypted != nil:
+		if patch.hasPlaintext() {
+			return nil, nil, errors.New("加密备忘录不能包含明文内容")
+		}
+		set, unset = s.encryptedMemoFields(patch.encrypted)
+	case current.Encrypted != nil && !patch.encryptedSet && patch.hasPlaintext():
+		return nil, nil, errors.New("加密备忘录需要提交密文")
+	case current.Encrypted != nil && patch.encryptedSet && patch.title == nil:
+		return nil, nil, errors.New("解除加密时需要提供标题")
+	default:
+		if current.Encrypted != nil && patch.encryptedSet {
+			unset["encrypted"] = ""
+		}
+		title, content := current.Title, current.Content
+		if patch.title != nil {
+			title = *patch.title
+			set["title"] = title
+		}
+		if patch.content != nil {
+			content = *patch.content
+			set["content"] = content
+		}
+		if patch.title != nil || patch.content != nil {
+			for key, value := range s.searchFields(title, content) {
+				set[key] = value
+			}
+		}
+
+		memoType := current.Type
+		if patch.memoType != nil {
+			memoType = *patch.memoType
+		}
+		switch {
+		case memoType == models.MemoTypeChecklist:
+			items := current.Items
+			if patch.itemsSet {
+				items = patch.items
+			}
+			if patch.memoType != nil || patch.itemsSet {
+				if items == nil {
+					items = []models.ChecklistItem{}
+				}
+				set["type"] = models.MemoTypeChecklist
+				for key, value := range s.checklistFields(items) {
+					set[key] = value
+				}
+			}
+		case patch.itemsSet && len(patch.items) > 0:
+			return nil, nil, errors.New("该备忘录不是清单")
+		case current.Type == models.MemoTypeChecklist:
+			// 清单转为普通备忘录时删除条目
+			unset["type"] = ""
+			unset["items"] = ""
+			unset["progress"] = ""
+			unset["search_items"] = ""
+		}
+	}
+	// 此时只包含正文相关字段的修改
+	if len(set) > 0 || len(unset) > 0 {
+		set["updated_at"] = time.Now()
+	}
+
+	for flag, value := range map[string]*bool{"pinned": patch.pinned, "archived": patch.archived, "favorite": patch.favorite} {
+		switch {
+		case value == nil:
+		case *value:
+			set[flag] = true
+		default:
+			unset[flag] = ""
+		}
+	}
+	if patch.tagsSet {
+		if len(patch.tags) > 0 {
+			set["tags"] = patch.tags
+		} else {
+			unset["tags"] = ""
+		}
+	}
+	if patch.folder != nil {
+		if *patch.folder != "" {
+			set["folder"] = *patch.folder
+		} else {
+			unset["folder"] = ""
+		}
+	}
+
+	if patch.dueAtSet {
+		if patch.dueAt != nil {
+			set["due_at"] = *patch.dueAt
+		} else {
+			unset["due_at"] = ""
+		}
+	}
+	if patch.reminderSet {
+		reminder, err := mergeReminderPatch(current.Reminder, patch.reminder)
+		if err != nil {
+			return nil, nil, errors.New("提醒设置无效：" + err.Error())
+		}
+		if reminder != nil {
+			set["reminder"] = reminder
+		} else {
+			unset["reminder"] = ""
+		}
+	}
+	return set, unset, nil
+}
+
+// mergeReminderPatch 将补丁中的 at、repeat、timezone 合并到当前提醒，并重新计算下一次提醒时间
+func mergeReminderPatch(current *models.MemoReminder, fields map[string]json.RawMessage) (*models.MemoReminder, error) {
+	if fields == nil {
+		return nil, nil
+	}
+
+	reminder := &models.MemoReminder{}
+	if current != nil {
+		reminder.At = current.At
+		reminder.Repeat = current.Repeat
+		reminder.Timezone = current.Timezone
+	}
+	for key, raw := range fields {
+		isNull := bytes.Equal(bytes.TrimSpace(raw), []byte("null"))
+		switch key {
+		case "at":
+			reminder.At = time.Time{}
+			if !isNull && json.Unmarshal(raw, &reminder.At) != nil {
+				return nil, errors.New("at 必须是RFC 3339格式的时间")
+			}
+		case "repeat":
+			reminder.Repeat = ""
+			if !isNull && (json.Unmarshal(raw, &reminder.Repeat) != nil || len(reminder.Repeat) > 200) {
+				return nil, errors.New("repeat 必须是不超过200个字符的字符串")
+			}
+		case "timezone":
+			reminder.Timezone = ""
+			if !isNull && (json.Unmarshal(raw, &reminder.Timezone) != nil || len(reminder.Timezone) > 64) {
+				return nil, errors.New("timezone 必须是不超过64个字符的字符串")
+			}
+		case "nextAt", "lastFiredAt":
+			// 由调度器维护，忽略
+		default:
+			return nil, errors.New("不支持的字段: " + key)
+		}
+	}
+	if reminder.At.IsZero() {
+		return nil, errors.New("缺少提醒时间")
+	}
+	reminder.At = reminder.At.UTC()
+
+	next, err := reminderNextAt(reminder, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if next == nil {
+		return nil, errors.New("提醒时间不能早于当前时间")
+	}
+	reminder.NextAt = next
+	return reminder, nil
+}
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseMemoPatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantErr string
+		check   func(t *testing.T, p *MemoPatch)
+	}{
+		{name: "不是对象", body: `[1]`, wantErr: "请求体必须是JSON对象"},
+		{name: "null", body: `null`, wantErr: "请求体必须是JSON对象"},
+		{name: "空补丁", body: `{}`, check: func(t *testing.T, p *MemoPatch) {
+			if p.hasPlaintext() || p.ownerOnly() {
+				t.Error("空补丁不应包含任何修改")
+			}
+		}},
+		{name: "修改标题", body: `{"title":"新标题"}`, check: func(t *testing.T, p *MemoPatch) {
+			if p.title == nil || *p.title != "新标题" {
+				t.Errorf("title = %v", p.title)
+			}
+			if !p.hasPlaintext() || p.ownerOnly() {
+				t.Error("修改标题只需要编辑权限")
+			}
+		}},
+		{name: "标题不能删除", body: `{"title":null}`, wantErr: "title 必须是非空字符串"},
+		{name: "标题不能为空白", body: `{"title":"  "}`, wantErr: "title 必须是非空字符串"},
+		{name: "删除正文", body: `{"content":null}`, check: func(t *testing.T, p *MemoPatch) {
+			if p.content == nil || *p.content != "" {
+				t.Errorf("content = %v, want 空字符串", p.content)
+			}
+		}},
+		{name: "无效类型", body: `{"type":"todo"}`, wantErr: "type 必须是 note 或 checklist"},
+		{name: "状态标记需要所有者", body: `{"pinned":true,"archived":null}`, check: func(t *testing.T, p *MemoPatch) {
+			if p.pinned == nil || !*p.pinned || p.archived == nil || *p.archived {
+				t.Errorf("pinned = %v, archived = %v", p.pinned, p.archived)
+			}
+			if !p.ownerOnly() {
+				t.Error("状态标记需要所有者权限")
+			}
+		}},
+		{name: "标记必须是布尔值", body: `{"favorite":"yes"}`, wantErr: "favorite 必须是布尔值"},
+		{name: "标签去重并去除空白", body: `{"tags":[" a ","a","","b"]}`, check: func(t *testing.T, p *MemoPatch) {
+			if !p.tagsSet || strings.Join(p.tags, ",") != "a,b" {
+				t.Errorf("tags = %v", p.tags)
+			}
+		}},
+		{name: "标签过长", body: `{"tags":["` + strings.Repeat("长", 51) + `"]}`, wantErr: "标签不能超过50个字符"},
+		{name: "文件夹去除首尾斜杠", body: `{"folder":"/工作/项目/"}`, check: func(t *testing.T, p *MemoPatch) {
+			if p.folder == nil || *p.folder != "工作/项目" {
+				t.Errorf("folder = %v", p.folder)
+			}
+		}},
+		{name: "截止时间转换为UTC", body: `{"dueAt":"2024-05-01T08:00:00+08:00"}`, check: func(t *testing.T, p *MemoPatch) {
+			if !p.dueAtSet || p.dueAt == nil || p.dueAt.Format("2006-01-02T15:04:05Z07:00") != "2024-05-01T00:00:00Z" {
+				t.Errorf("dueAt = %v", p.dueAt)
+			}
+		}},
+		{name: "清除截止时间", body: `{"dueAt":null}`, check: func(t *testing.T, p *MemoPatch) {
+			if !p.dueAtSet || p.dueAt != nil {
+				t.Errorf("dueAtSet = %v, dueAt = %v", p.dueAtSet, p.dueAt)
+			}
+		}},
+		{name: "无效截止时间", body: `{"dueAt":"明天"}`, wantErr: "dueAt 必须是RFC 3339格式的时间"},
+		{name: "清除提醒", body: `{"reminder":null}`, check: func(t *testing.T, p *MemoPatch) {
+			if !p.reminderSet || p.reminder != nil {
+				t.Errorf("reminderSet = %v, reminder = %v", p.reminderSet, p.reminder)
+			}
+		}},
+		{name: "提醒必须是对象", body: `{"reminder":"daily"}`, wantErr: "reminder 必须是对象或 null"},
+		{name: "清单条目保留ID", body: `{"items":[{"id":"64b000000000000000000001","text":"一","checked":true},{"text":"二"}]}`, check: func(t *testing.T, p *MemoPatch) {
+			if len(p.items) != 2 || p.items[0].ID.Hex() != "64b000000000000000000001" || !p.items[0].Checked || p.items[1].Position != 1 {
+				t.Errorf("items = %+v", p.items)
+			}
+		}},
+		{name: "清单条目ID重复", body: `{"items":[{"id":"64b000000000000000000001","text":"一"},{"id":"64b000000000000000000001","text":"二"}]}`, wantErr: "无效的清单条目ID"},
+		{name: "清单条目为空", body: `{"items":[{"text":""}]}`, wantErr: "清单条目文本不能为空且不能超过1000个字符"},
+		{name: "解除加密", body: `{"encrypted":null,"title":"明文"}`, check: func(t *testing.T, p *MemoPatch) {
+			if !p.encryptedSet || p.encrypted != nil {
+				t.Errorf("encryptedSet = %v, encrypted = %v", p.encryptedSet, p.encrypted)
+			}
+		}},
+		{name: "版本号", body: `{"version":42}`, check: func(t *testing.T, p *MemoPatch) {
+			if p.version == nil || *p.version != 42 {
+				t.Errorf("version = %v", p.version)
+			}
+		}},
+		{name: "版本号必须是整数", body: `{"version":"42"}`, wantErr: "version 必须是整数"},
+		{name: "只读字段忽略", body: `{"id":"x","createTime":"x","progress":1}`},
+		{name: "不支持的字段", body: `{"owner":"x"}`, wantErr: "不支持的字段: owner"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patch, err := ParseMemoPatch([]byte(tt.body))
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("ParseMemoPatch() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseMemoPatch() error = %v", err)
+			}
+			if tt.check != nil {
+				tt.check(t, patch)
+			}
+		})
+	}
+}
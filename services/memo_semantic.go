@@ -0,0 +1,382 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"mjbackend/config"
+	"mjbackend/database"
+	"mjbackend/models"
+	"mjbackend/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// 待更新向量的备忘录队列长度，队列满时丢弃，由下次启动时的补建任务处理
+const embeddingQueueSize = 1024
+
+// 补建向量时每次提交给模型的备忘录数
+const embeddingBatchSize = 32
+
+// 进程内比较时每个用户最多加载的向量数，按更新时间取最近的部分
+const bruteForceCandidateLimit = 5000
+
+// 向量索引查询失败后暂停使用的时长，期间直接在进程内比较
+const vectorIndexRetryInterval = 10 * time.Minute
+
+var embeddingQueue = make(chan primitive.ObjectID, embeddingQueueSize)
+
+// vectorIndexRetryAt 向量索引可再次尝试的时间（UnixNano）
+var vectorIndexRetryAt atomic.Int64
+
+// queueEmbedding 在备忘录写入后安排更新其向量，向量在后台生成，不阻塞写操作
+func queueEmbedding(memoID primitive.ObjectID) {
+	select {
+	case embeddingQueue <- memoID:
+	default:
+		log.Printf("向量更新队列已满，跳过备忘录 %s", memoID.Hex())
+	}
+}
+
+// StartEmbeddingIndexer 启动向量更新任务，并为缺少向量或向量已过期的备忘录补建
+func StartEmbeddingIndexer() {
+	service := NewMemoService()
+	go service.BackfillEmbeddings()
+	go func() {
+		for memoID := range embeddingQueue {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			if err := service.refreshEmbedding(ctx, memoID); err != nil {
+				log.Printf("更新备忘录 %s 的向量失败: %v", memoID.Hex(), err)
+			}
+			cancel()
+		}
+	}()
+}
+
+// embeddingText 返回用于生成向量的纯文本，加密备忘录的内容服务端不可见，返回空字符串
+func embeddingText(memo *models.Memo) string {
+	if memo.Encrypted != nil {
+		return ""
+	}
+	text, err := utils.MarkdownText(memoMarkdown(memo))
+	if err != nil {
+		text = memoMarkdown(memo)
+	}
+	text = strings.TrimSpace(memo.Title + "\n\n" + text)
+	return truncateRunes(text, maxEmbeddingInput)
+}
+
+// embeddingHash 计算向量所用模型和文本的哈希，任一变化时需要重新生成
+func embeddingHash(model, text string) string {
+	h := sha256.New()
+	h.Write([]byte(model))
+	h.Write([]byte{0})
+	h.Write([]byte(text))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// refreshEmbedding 按备忘录当前内容更新向量，内容未变化时跳过；
+// 备忘录已删除、已加密或内容为空时删除向量
+func (s *MemoService) refreshEmbedding(ctx context.Context, memoID primitive.ObjectID) error {
+	collection := database.GetCollection("memo_embeddings")
+
+	var memo models.Memo
+	err := database.GetCollection("memos").FindOne(ctx, bson.M{"_id": memoID}).Decode(&memo)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return err
+	}
+	text := ""
+	if err == nil && memo.DeletedAt == nil {
+		text = embeddingText(&memo)
+	}
+	if text == "" {
+		_, err := collection.DeleteOne(ctx, bson.M{"_id": memoID})
+		return err
+	}
+
+	hash := embeddingHash(s.embedder.Name(), text)
+	var existing models.MemoEmbedding
+	err = collection.FindOne(ctx, bson.M{"_id": memoID}, options.FindOne().SetProjection(bson.M{"content_hash": 1})).Decode(&existing)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return err
+	}
+	if existing.ContentHash == hash {
+		return nil
+	}
+
+	vectors, err := s.embedder.Embed(ctx, []string{text})
+	if err != nil {
+		return err
+	}
+	return saveEmbedding(ctx, &memo, s.embedder.Name(), vectors[0], hash)
+}
+
+func saveEmbedding(ctx context.Context, memo *models.Memo, model string, vector []float32, hash string) error {
+	_, err := database.GetCollection("memo_embeddings").ReplaceOne(ctx,
+		bson.M{"_id": memo.ID},
+		models.MemoEmbedding{
+			MemoID:      memo.ID,
+			UserID:      memo.UserID,
+			Model:       model,
+			Vector:      vector,
+			ContentHash: hash,
+			UpdatedAt:   time.Now(),
+		},
+		options.Replace().SetUpsert(true),
+	)
+	return err
+}
+
+// BackfillEmbeddings 为缺少向量、内容已变化或模型已变更的备忘录重建向量
+func (s *MemoService) BackfillEmbeddings() {
+	ctx := context.Background()
+
+	cursor, err := database.GetCollection("memos").Find(ctx,
+		bson.M{"deleted_at": nil, "encrypted": nil},
+		options.Find().SetProjection(bson.M{"_id": 1, "user_id": 1, "title": 1, "content": 1, "type": 1, "items": 1}))
+	if err != nil {
+		log.Printf("查询待生成向量的备忘录失败: %v", err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	updated := 0
+	batch := make([]models.Memo, 0, embeddingBatchSize)
+	flush := func() {
+		n, err := s.backfillEmbeddingBatch(ctx, batch)
+		if err != nil {
+			log.Printf("批量生成备忘录向量失败: %v", err)
+		}
+		updated += n
+		batch = batch[:0]
+	}
+	for cursor.Next(ctx) {
+		var memo models.Memo
+		if err := cursor.Decode(&memo); err != nil {
+			log.Printf("解析备忘录失败: %v", err)
+			continue
+		}
+		batch = append(batch, memo)
+		if len(batch) == embeddingBatchSize {
+			flush()
+		}
+	}
+	if len(batch) > 0 {
+		flush()
+	}
+	if updated > 0 {
+		log.Printf("已为 %d 条备忘录生成向量", updated)
+	}
+}
+
+func (s *MemoService) backfillEmbeddingBatch(ctx context.Context, memos []models.Memo) (int, error) {
+	ids := make([]primitive.ObjectID, len(memos))
+	for i := range memos {
+		ids[i] = memos[i].ID
+	}
+	cursor, err := database.GetCollection("memo_embeddings").Find(ctx, bson.M{"_id": bson.M{"$in": ids}},
+		options.Find().SetProjection(bson.M{"content_hash": 1}))
+	if err != nil {
+		return 0, err
+	}
+	var existing []models.MemoEmbedding
+	if err := cursor.All(ctx, &existing); err != nil {
+		return 0, err
+	}
+	hashes := make(map[primitive.ObjectID]string, len(existing))
+	for _, embedding := range existing {
+		hashes[embedding.MemoID] = embedding.ContentHash
+	}
+
+	model := s.embedder.Name()
+	var stale []*models.Memo
+	var texts, staleHashes []string
+	for i := range memos {
+		text := embeddingText(&memos[i])
+		if text == "" {
+			continue
+		}
+		hash := embeddingHash(model, text)
+		if hashes[memos[i].ID] == hash {
+			continue
+		}
+		stale = append(stale, &memos[i])
+		texts = append(texts, text)
+		staleHashes = append(staleHashes, hash)
+	}
+	if len(stale) == 0 {
+		return 0, nil
+	}
+
+	embedCtx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	vectors, err := s.embedder.Embed(embedCtx, texts)
+	if err != nil {
+		return 0, err
+	}
+	for i, memo := range stale {
+		if err := saveEmbedding(ctx, memo, model, vectors[i], staleHashes[i]); err != nil {
+			return i, err
+		}
+	}
+	return len(stale), nil
+}
+
+// semanticCandidate 按向量相似度找到的候选备忘录
+type semanticCandidate struct {
+	MemoID primitive.ObjectID `bson:"_id"`
+	Score  float64            `bson:"score"`
+}
+
+// SemanticSearch 按语义相似度搜索当前用户的备忘录。配置了向量索引时由数据库查找最近邻，
+// 索引不可用时在进程内与用户的全部向量逐条比较
+func (s *MemoService) SemanticSearch(userID primitive.ObjectID, q string, limit int) (*models.SemanticSearchResponse, error) {
+	q = strings.TrimSpace(q)
+	if q == "" {
+		return nil, errors.New("搜索关键词不能为空")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	vectors, err := s.embedder.Embed(ctx, []string{truncateRunes(q, maxEmbeddingInput)})
+	if err != nil {
+		log.Printf("生成查询向量失败: %v", err)
+		return nil, errors.New("生成查询向量失败")
+	}
+
+	// 向量可能尚未随删除或加密更新，多取一些候选再按备忘录状态过滤
+	candidates, engine, err := s.semanticCandidates(ctx, userID, vectors[0], limit*2)
+	if err != nil {
+		return nil, err
+	}
+
+	hits := []models.SemanticSearchHit{}
+	if len(candidates) > 0 {
+		ids := make([]primitive.ObjectID, len(candidates))
+		for i, candidate := range candidates {
+			ids[i] = candidate.MemoID
+		}
+		cursor, err := database.GetCollection("memos").Find(ctx, bson.M{
+			"_id":        bson.M{"$in": ids},
+			"user_id":    userID,
+			"deleted_at": nil,
+			"encrypted":  nil,
+		})
+		if err != nil {
+			return nil, err
+		}
+		var memos []models.Memo
+		if err := cursor.All(ctx, &memos); err != nil {
+			return nil, err
+		}
+		byID := make(map[primitive.ObjectID]models.Memo, len(memos))
+		for _, memo := range memos {
+			byID[memo.ID] = memo
+		}
+		for _, candidate := range candidates {
+			memo, ok := byID[candidate.MemoID]
+			if !ok || candidate.Score <= 0 {
+				continue
+			}
+			hits = append(hits, models.SemanticSearchHit{Memo: memo, Score: candidate.Score})
+			if len(hits) == limit {
+				break
+			}
+		}
+	}
+
+	return &models.SemanticSearchResponse{List: hits, Engine: engine}, nil
+}
+
+func (s *MemoService) semanticCandidates(ctx context.Context, userID primitive.ObjectID, query []float32, k int) ([]semanticCandidate, string, error) {
+	index := config.AppConfig.VectorSearchIndex
+	if index != "" && time.Now().UnixNano() >= vectorIndexRetryAt.Load() {
+		candidates, err := s.vectorIndexSearch(ctx, index, userID, query, k)
+		if err == nil {
+			return candidates, "vector_index", nil
+		}
+		// 非 Atlas 部署不支持 $vectorSearch，暂停一段时间后再尝试
+		log.Printf("向量索引查询失败，改为进程内比较: %v", err)
+		vectorIndexRetryAt.Store(time.Now().Add(vectorIndexRetryInterval).UnixNano())
+	}
+
+	candidates, err := s.bruteForceSearch(ctx, userID, query, k)
+	return candidates, "brute_force", err
+}
+
+// vectorIndexSearch 使用 Atlas 向量索引查找最近邻，索引需以 cosine 度量建立在 vector 字段上，
+// 并将 user_id 和 model 声明为过滤字段
+func (s *MemoService) vectorIndexSearch(ctx context.Context, index string, userID primitive.ObjectID, query []float32, k int) ([]semanticCandidate, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$vectorSearch", Value: bson.M{
+			"index":         index,
+			"path":          "vector",
+			"queryVector":   query,
+			"numCandidates": k * 10,
+			"limit":         k,
+			"filter":        bson.M{"user_id": userID, "model": s.embedder.Name()},
+		}}},
+		{{Key: "$project", Value: bson.M{"_id": 1, "score": bson.M{"$meta": "vectorSearchScore"}}}},
+	}
+	cursor, err := database.GetCollection("memo_embeddings").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	var candidates []semanticCandidate
+	if err := cursor.All(ctx, &candidates); err != nil {
+		return nil, err
+	}
+	// cosine 索引的得分为 (1 + cos) / 2，换算为与进程内比较一致的余弦相似度
+	for i := range candidates {
+		candidates[i].Score = candidates[i].Score*2 - 1
+	}
+	return candidates, nil
+}
+
+// bruteForceSearch 加载用户最近更新的向量并逐条计算相似度，返回得分最高的 k 条
+func (s *MemoService) bruteForceSearch(ctx context.Context, userID primitive.ObjectID, query []float32, k int) ([]semanticCandidate, error) {
+	cursor, err := database.GetCollection("memo_embeddings").Find(ctx,
+		bson.M{"user_id": userID, "model": s.embedder.Name()},
+		options.Find().
+			SetProjection(bson.M{"vector": 1}).
+			SetSort(bson.D{{Key: "updated_at", Value: -1}}).
+			SetLimit(bruteForceCandidateLimit))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	candidates := []semanticCandidate{}
+	for cursor.Next(ctx) {
+		var embedding models.MemoEmbedding
+		if err := cursor.Decode(&embedding); err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, semanticCandidate{
+			MemoID: embedding.MemoID,
+			Score:  dotProduct(query, embedding.Vector),
+		})
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].Score > candidates[j].Score
+	})
+	if len(candidates) > k {
+		candidates = candidates[:k]
+	}
+	return candidates, nil
+}
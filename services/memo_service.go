@@ -3,10 +3,16 @@ package services
 import (
 	"context"
 	"errors"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
+	"mjbackend/config"
 	"mjbackend/database"
 	"mjbackend/models"
+	"mjbackend/utils"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -14,10 +20,80 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-type MemoService struct{}
+// 备忘录同步序号计数器名称
+const memoSyncCounter = "memo_sync"
+
+// 增量同步单次返回的最大变更数
+const maxSyncChanges = 500
+
+// 搜索结果片段在首个命中位置前后截取的字符数
+const snippetRadius = 40
+
+// 彻底删除备忘录时清除的字段，仅保留墓碑所需的ID、用户、时间和版本信息
+var memoBodyFields = bson.M{
+	"title":           "",
+	"content":         "",
+	"encrypted":       "",
+	"summary":         "",
+	"client_id":       "",
+	"excerpt":         "",
+	"import_key":      "",
+	"search_title":    "",
+	"search_text":     "",
+	"search_items":    "",
+	"search_analyzer": "",
+	"title_lower":     "",
+	"pinned":          "",
+	"archived":        "",
+	"favorite":        "",
+	"due_at":          "",
+	"reminder":        "",
+	"type":            "",
+	"items":           "",
+	"progress":        "",
+	"tags":            "",
+	"folder":          "",
+}
+
+type MemoService struct {
+	analyzer          utils.Analyzer
+	attachmentService *AttachmentService
+	currencyService   *CurrencyService
+	llm               LLMProvider
+	embedder          EmbeddingProvider
+}
 
 func NewMemoService() *MemoService {
-	return &MemoService{}
+	return &MemoService{
+		analyzer:          utils.NewAnalyzer(config.AppConfig.SearchAnalyzer),
+		attachmentService: NewAttachmentService(),
+		currencyService:   NewCurrencyService(),
+		llm:               NewLLMProvider(),
+		embedder:          NewEmbeddingProvider(),
+	}
+}
+
+// 生成全文索引字段和正文摘要
+func (s *MemoService) searchFields(title, content string) bson.M {
+	return bson.M{
+		"search_title":    strings.Join(s.analyzer.IndexTokens(title), " "),
+		"search_text":     strings.Join(s.analyzer.IndexTokens(content), " "),
+		"search_analyzer": s.analyzer.Name(),
+		"title_lower":     linkKey(title),
+		"excerpt":         utils.Excerpt(content),
+	}
+}
+
+// 为备忘录填充全文索引字段和正文摘要
+func (s *MemoService) indexMemo(memo *models.Memo) {
+	memo.Excerpt = utils.Excerpt(memo.Content)
+	memo.SearchTitle = strings.Join(s.analyzer.IndexTokens(memo.Title), " ")
+	memo.SearchText = strings.Join(s.analyzer.IndexTokens(memo.Content), " ")
+	if len(memo.Items) > 0 {
+		memo.SearchItems = strings.Join(s.analyzer.IndexTokens(checklistText(memo.Items)), " ")
+	}
+	memo.SearchAnalyzer = s.analyzer.Name()
+	memo.TitleLower = linkKey(memo.Title)
 }
 
 // 创建备忘录
@@ -26,52 +102,119 @@ func (s *MemoService) CreateMemo(userID primitive.ObjectID, req *models.CreateMe
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	if req.TemplateID != "" {
+		if err := applyTemplate(ctx, userID, req); err != nil {
+			return nil, err
+		}
+		if req.Title == "" {
+			return nil, errors.New("标题不能为空")
+		}
+		if req.Type != models.MemoTypeChecklist && len(req.Items) > 0 {
+			return nil, errors.New("只有清单备忘录可以包含条目")
+		}
+	}
+	// 加密备忘录的内容全部在密文中
+	if req.Encrypted != nil {
+		if req.Title != "" || req.Content != "" || req.Type == models.MemoTypeChecklist || len(req.Items) > 0 {
+			return nil, errors.New("加密备忘录不能包含明文内容")
+		}
+		if err := checkMemoEnvelope(ctx, userID, req.Encrypted); err != nil {
+			return nil, err
+		}
+	}
+
+	seq, err := database.NextSequence(ctx, memoSyncCounter, 1)
+	if err != nil {
+		return nil, err
+	}
+
 	memo := &models.Memo{
-		ID:        primitive.NewObjectID(),
-		UserID:    userID,
-		Title:     req.Title,
-		Content:   req.Content,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+		ID:         primitive.NewObjectID(),
+		UserID:     userID,
+		Title:      req.Title,
+		Content:    req.Content,
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+		SyncSeq:    seq,
+		CreatedSeq: seq,
+		Encrypted:  req.Encrypted,
 	}
+	if req.Type == models.MemoTypeChecklist {
+		memo.Type = models.MemoTypeChecklist
+		memo.Items = newChecklistItems(req.Items)
+		memo.Progress = checklistProgress(memo.Items)
+	}
+	if tags := normalizeTags(req.Tags); len(tags) > 0 {
+		memo.Tags = tags
+	}
+	memo.Folder = strings.Trim(strings.TrimSpace(req.Folder), "/")
+	s.indexMemo(memo)
 
-	_, err := collection.InsertOne(ctx, memo)
+	_, err = collection.InsertOne(ctx, memo)
 	if err != nil {
 		return nil, err
 	}
+	s.refreshMemoLinks(ctx, memo)
+	queueEmbedding(memo.ID)
 
+	Events.publishMemoLocal(userID, memo.ID, models.EventMemoCreated, memo)
 	return memo, nil
 }
 
-// 获取备忘录列表
-func (s *MemoService) GetMemoList(userID primitive.ObjectID, page, limit int, keyword string) (*models.MemoListResponse, error) {
+// 获取备忘录列表，支持页码分页和游标分页
+func (s *MemoService) GetMemoList(userID primitive.ObjectID, query *models.MemoListQuery) (*models.MemoListResponse, error) {
 	collection := database.GetCollection("memos")
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	// 构建查询条件
-	filter := bson.M{"user_id": userID}
-	if keyword != "" {
-		filter["$or"] = []bson.M{
-			{"title": bson.M{"$regex": keyword, "$options": "i"}},
-			{"content": bson.M{"$regex": keyword, "$options": "i"}},
-		}
+	// 默认置顶优先
+	sortName := query.Sort
+	if sortName == "" {
+		sortName = "pinned"
+	}
+	sortKeys, order, err := memoSortKeys(sortName, query.Order)
+	if err != nil {
+		return nil, err
 	}
 
+	fields, err := memoFieldSelection(query.Fields)
+	if err != nil {
+		return nil, err
+	}
+
+	// 构建查询条件
+	filter := memoListFilter(userID, query)
+
 	// 计算总数
 	total, err := collection.CountDocuments(ctx, filter)
 	if err != nil {
 		return nil, err
 	}
 
-	// 计算跳过的文档数
-	skip := (page - 1) * limit
-
-	// 查询选项
+	// 查询选项，多取一条用于判断是否还有更多数据
 	findOptions := options.Find()
-	findOptions.SetSort(bson.D{{"created_at", -1}}) // 按创建时间倒序
-	findOptions.SetSkip(int64(skip))
-	findOptions.SetLimit(int64(limit))
+	findOptions.SetSort(memoSortSpec(sortKeys))
+	findOptions.SetLimit(int64(query.Limit + 1))
+	if fields != nil {
+		findOptions.SetProjection(memoFieldProjection(fields, sortKeys))
+	}
+
+	if query.UseCursor {
+		if query.Cursor != "" {
+			cursor, err := decodeMemoCursor(query.Cursor, sortName, order)
+			if err != nil {
+				return nil, err
+			}
+			after, err := memoCursorFilter(sortKeys, cursor)
+			if err != nil {
+				return nil, err
+			}
+			filter["$and"] = []bson.M{after}
+		}
+	} else {
+		// 计算跳过的文档数
+		findOptions.SetSkip(int64((query.Page - 1) * query.Limit))
+	}
 
 	// 查询数据
 	cursor, err := collection.Find(ctx, filter, findOptions)
@@ -80,97 +223,837 @@ func (s *MemoService) GetMemoList(userID primitive.ObjectID, page, limit int, ke
 	}
 	defer cursor.Close(ctx)
 
-	var memos []models.Memo
+	memos := []models.Memo{}
 	if err = cursor.All(ctx, &memos); err != nil {
 		return nil, err
 	}
 
-	return &models.MemoListResponse{
-		List:  memos,
+	resp := &models.MemoListResponse{
 		Total: total,
-		Page:  page,
-		Limit: limit,
-	}, nil
+		Limit: query.Limit,
+	}
+	if len(memos) > query.Limit {
+		memos = memos[:query.Limit]
+		resp.HasMore = true
+	}
+	if fields != nil {
+		resp.List = selectMemoFields(memos, fields)
+	} else {
+		resp.List = memos
+	}
+
+	if query.UseCursor {
+		if resp.HasMore {
+			resp.NextCursor, err = encodeMemoCursor(sortName, order, sortKeys, &memos[len(memos)-1])
+			if err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		resp.Page = query.Page
+	}
+
+	return resp, nil
 }
 
-// 获取备忘录详情
+// 构建列表查询条件，批量操作按筛选条件选择备忘录时复用
+func memoListFilter(userID primitive.ObjectID, query *models.MemoListQuery) bson.M {
+	filter := bson.M{"user_id": userID, "deleted_at": nil}
+	addFlagFilter(filter, "pinned", query.Pinned)
+	addFlagFilter(filter, "archived", query.Archived)
+	addFlagFilter(filter, "favorite", query.Favorite)
+	if query.Tag != "" {
+		filter["tags"] = query.Tag
+	}
+	if query.Folder != nil {
+		if *query.Folder == "" {
+			filter["folder"] = bson.M{"$exists": false}
+		} else {
+			filter["folder"] = *query.Folder
+		}
+	}
+	if query.Keyword != "" {
+		pattern := regexp.QuoteMeta(query.Keyword)
+		filter["$or"] = []bson.M{
+			{"title": bson.M{"$regex": pattern, "$options": "i"}},
+			{"content": bson.M{"$regex": pattern, "$options": "i"}},
+		}
+	}
+	return filter
+}
+
+// 添加状态标记筛选条件，未标记的文档不含该字段
+func addFlagFilter(filter bson.M, field string, value *bool) {
+	if value == nil {
+		return
+	}
+	if *value {
+		filter[field] = true
+	} else {
+		filter[field] = bson.M{"$ne": true}
+	}
+}
+
+// 设置或取消备忘录的置顶、归档、收藏标记，需要所有者权限
+func (s *MemoService) SetMemoFlag(userID, memoID primitive.ObjectID, flag string, value bool) (*models.Memo, error) {
+	switch flag {
+	case "pinned", "archived", "favorite":
+	default:
+		return nil, errors.New("无效的备忘录标记")
+	}
+
+	collection := database.GetCollection("memos")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for attempt := 0; attempt < memoWriteRetries; attempt++ {
+		current, err := authorizeMemo(ctx, userID, memoID, models.RoleOwner)
+		if err != nil {
+			return nil, err
+		}
+
+		seq, err := database.NextSequence(ctx, memoSyncCounter, 1)
+		if err != nil {
+			return nil, err
+		}
+
+		filter := memoVersionFilter(current)
+		filter["deleted_at"] = nil
+
+		// 标记变更需要同步到其他设备，但不修改更新时间以免影响按更新时间排序
+		set := bson.M{"sync_seq": seq}
+		update := bson.M{"$set": set}
+		if value {
+			set[flag] = true
+		} else {
+			update["$unset"] = bson.M{flag: ""}
+		}
+
+		var memo models.Memo
+		err = collection.FindOneAndUpdate(ctx, filter, update,
+			options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&memo)
+		if err == mongo.ErrNoDocuments {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		memo.Role = current.Role
+
+		Events.publishMemoLocal(memo.UserID, memo.ID, models.EventMemoUpdated, memo)
+		return &memo, nil
+	}
+	return nil, errors.New("备忘录正在被其他人修改，请重试")
+}
+
+// 获取备忘录详情，创建者和协作者均可查看
 func (s *MemoService) GetMemoByID(userID, memoID primitive.ObjectID) (*models.Memo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return authorizeMemo(ctx, userID, memoID, models.RoleViewer)
+}
+
+// 获取备忘录并将正文渲染为过滤后的HTML，清单条目渲染为任务列表
+func (s *MemoService) GetMemoHTML(userID, memoID primitive.ObjectID) (*models.MemoHTMLResponse, error) {
+	memo, err := s.GetMemoByID(userID, memoID)
+	if err != nil {
+		return nil, err
+	}
+	if memo.Encrypted != nil {
+		return nil, errors.New("加密备忘录不支持该操作")
+	}
+
+	html, err := utils.RenderMarkdown(memoMarkdown(memo))
+	if err != nil {
+		return nil, err
+	}
+	return &models.MemoHTMLResponse{Memo: *memo, HTML: html}, nil
+}
+
+// 更新备忘录，需要编辑及以上权限。以同步版本号作为乐观锁：授权后备忘录被修改，或协作者角色变化、被移除
+// （见 bumpMemoVersion）时版本号改变，写入不生效，重新授权后重试
+func (s *MemoService) UpdateMemo(userID, memoID primitive.ObjectID, req *models.UpdateMemoRequest) (*models.Memo, error) {
 	collection := database.GetCollection("memos")
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	var memo models.Memo
-	filter := bson.M{
-		"_id":     memoID,
+	for attempt := 0; attempt < memoWriteRetries; attempt++ {
+		current, err := authorizeMemo(ctx, userID, memoID, models.RoleEditor)
+		if err != nil {
+			return nil, err
+		}
+
+		var set, unset bson.M
+		if req.Encrypted != nil {
+			if req.Title != "" || req.Content != "" {
+				return nil, errors.New("加密备忘录不能包含明文内容")
+			}
+			if err := checkMemoEnvelope(ctx, current.UserID, req.Encrypted); err != nil {
+				return nil, err
+			}
+			set, unset = s.encryptedMemoFields(req.Encrypted)
+		} else {
+			// 明文更新不能覆盖密文，解除加密需通过 PATCH 显式将 encrypted 置为 null
+			if current.Encrypted != nil {
+				return nil, errors.New("加密备忘录需要提交密文")
+			}
+			set = s.searchFields(req.Title, req.Content)
+			set["title"] = req.Title
+			set["content"] = req.Content
+		}
+
+		seq, err := database.NextSequence(ctx, memoSyncCounter, 1)
+		if err != nil {
+			return nil, err
+		}
+		set["updated_at"] = time.Now()
+		set["sync_seq"] = seq
+		update := bson.M{"$set": set}
+		if len(unset) > 0 {
+			update["$unset"] = unset
+		}
+
+		filter := memoVersionFilter(current)
+		filter["deleted_at"] = nil
+
+		var memo models.Memo
+		err = collection.FindOneAndUpdate(ctx, filter, update,
+			options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&memo)
+		if err == mongo.ErrNoDocuments {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		memo.Role = current.Role
+		s.refreshMemoLinks(ctx, &memo)
+		queueEmbedding(memo.ID)
+
+		Events.publishMemoLocal(memo.UserID, memo.ID, models.EventMemoUpdated, memo)
+		return &memo, nil
+	}
+	return nil, errors.New("备忘录正在被其他人修改，请重试")
+}
+
+// 删除备忘录（保留墓碑记录以便客户端增量同步删除），需要所有者权限
+func (s *MemoService) DeleteMemo(userID, memoID primitive.ObjectID) error {
+	collection := database.GetCollection("memos")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for attempt := 0; attempt < memoWriteRetries; attempt++ {
+		current, err := authorizeMemo(ctx, userID, memoID, models.RoleOwner)
+		if err != nil {
+			return err
+		}
+
+		seq, err := database.NextSequence(ctx, memoSyncCounter, 1)
+		if err != nil {
+			return err
+		}
+
+		filter := memoVersionFilter(current)
+		filter["deleted_at"] = nil
+
+		now := time.Now()
+		update := bson.M{
+			"$set": bson.M{
+				"deleted_at": now,
+				"updated_at": now,
+				"sync_seq":   seq,
+			},
+		}
+
+		result, err := collection.UpdateOne(ctx, filter, update)
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			continue
+		}
+		unlinkMemo(ctx, memoID)
+		queueEmbedding(memoID)
+
+		Events.publishMemoLocal(current.UserID, memoID, models.EventMemoDeleted, models.MemoTombstone{
+			ID:        memoID,
+			DeletedAt: now,
+			Version:   seq,
+		})
+		return nil
+	}
+	return errors.New("备忘录正在被其他人修改，请重试")
+}
+
+// 获取其他用户共享给当前用户的备忘录
+func (s *MemoService) GetSharedWithMe(userID primitive.ObjectID, page, limit int) (*models.MemoListResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := database.GetCollection("memo_members").Find(ctx, bson.M{
 		"user_id": userID,
+		"status":  models.MemberAccepted,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var members []models.MemoMember
+	if err = cursor.All(ctx, &members); err != nil {
+		return nil, err
+	}
+
+	roles := make(map[primitive.ObjectID]string, len(members))
+	memoIDs := make([]primitive.ObjectID, 0, len(members))
+	for _, member := range members {
+		roles[member.MemoID] = member.Role
+		memoIDs = append(memoIDs, member.MemoID)
+	}
+
+	collection := database.GetCollection("memos")
+	filter := bson.M{"_id": bson.M{"$in": memoIDs}, "deleted_at": nil}
+
+	total, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "updated_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(int64((page - 1) * limit)).
+		SetLimit(int64(limit))
+	memoCursor, err := collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer memoCursor.Close(ctx)
+
+	memos := []models.Memo{}
+	if err = memoCursor.All(ctx, &memos); err != nil {
+		return nil, err
+	}
+	for i := range memos {
+		memos[i].Role = roles[memos[i].ID]
+	}
+
+	return &models.MemoListResponse{
+		List:    memos,
+		Total:   total,
+		Page:    page,
+		Limit:   limit,
+		HasMore: int64(page*limit) < total,
+	}, nil
+}
+
+// 彻底删除备忘录：清除内容和附件，仅保留墓碑记录供其他设备同步删除。
+// 需要所有者权限，已删除但未彻底删除的备忘录同样可以操作
+func (s *MemoService) PurgeMemo(userID, memoID primitive.ObjectID) error {
+	collection := database.GetCollection("memos")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for attempt := 0; attempt < memoWriteRetries; attempt++ {
+		current, err := authorizeMemoFilter(ctx, userID, bson.M{"_id": memoID, "purged_at": nil}, models.RoleOwner)
+		if err != nil {
+			return err
+		}
+
+		seq, err := database.NextSequence(ctx, memoSyncCounter, 1)
+		if err != nil {
+			return err
+		}
+
+		filter := memoVersionFilter(current)
+		filter["purged_at"] = nil
+
+		now := time.Now()
+		update := bson.M{
+			"$set": bson.M{
+				"deleted_at": now,
+				"purged_at":  now,
+				"updated_at": now,
+				"sync_seq":   seq,
+			},
+			"$unset": memoBodyFields,
+		}
+
+		result, err := collection.UpdateOne(ctx, filter, update)
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			continue
+		}
+
+		unlinkMemo(ctx, memoID)
+		queueEmbedding(memoID)
+		if err := s.attachmentService.DeleteMemoAttachments(memoID); err != nil {
+			log.Printf("删除备忘录 %s 的附件失败: %v", memoID.Hex(), err)
+		}
+
+		// 删除前仍在使用中时才需要通知其他设备
+		if current.DeletedAt == nil {
+			Events.publishMemoLocal(current.UserID, memoID, models.EventMemoDeleted, models.MemoTombstone{
+				ID:        memoID,
+				DeletedAt: now,
+				Version:   seq,
+			})
+		}
+		return nil
 	}
+	return errors.New("备忘录正在被其他人修改，请重试")
+}
 
-	err := collection.FindOne(ctx, filter).Decode(&memo)
+// 通过分享链接获取只读备忘录，撤销、过期的链接或已删除的备忘录均返回不存在
+func (s *MemoService) GetSharedMemo(token, password string) (*models.SharedMemoResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	share, err := findActiveShare(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	// 先校验访问密码，密码错误时不读取备忘录
+	if share.HasPassword {
+		if password == "" {
+			return nil, errors.New("需要访问密码")
+		}
+		if !utils.CheckPasswordHash(password, share.PasswordHash) {
+			return nil, errors.New("访问密码错误")
+		}
+	}
+
+	var memo models.Memo
+	err = database.GetCollection("memos").FindOne(ctx, bson.M{
+		"_id":        share.MemoID,
+		"user_id":    share.UserID,
+		"deleted_at": nil,
+	}).Decode(&memo)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			return nil, errors.New("备忘录不存在")
+			return nil, errors.New("分享链接不存在")
 		}
 		return nil, err
 	}
 
-	return &memo, nil
+	// 记录访问次数
+	_, err = database.GetCollection("memo_shares").UpdateOne(ctx, bson.M{"_id": share.ID}, bson.M{
+		"$inc": bson.M{"views": 1},
+		"$set": bson.M{"last_viewed_at": time.Now()},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.SharedMemoResponse{
+		Title:     memo.Title,
+		Content:   memo.Content,
+		Type:      memo.Type,
+		Items:     memo.Items,
+		Encrypted: memo.Encrypted,
+		CreatedAt: memo.CreatedAt,
+		UpdatedAt: memo.UpdatedAt,
+		Views:     share.Views + 1,
+		ExpiresAt: share.ExpiresAt,
+	}, nil
 }
 
-// 更新备忘录
-func (s *MemoService) UpdateMemo(userID, memoID primitive.ObjectID, req *models.UpdateMemoRequest) (*models.Memo, error) {
+// 获取指定游标之后的备忘录变更。
+// 游标只推进到已稳定的同步序号（见 memoSyncSettle），最近的变更可能在下次同步时重复下发，客户端按版本覆盖即可
+func (s *MemoService) GetMemoChanges(userID primitive.ObjectID, since string, limit int) (*models.MemoChangesResponse, error) {
 	collection := database.GetCollection("memos")
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	// 检查备忘录是否存在且属于当前用户
+	sc, err := parseSyncCursor(since)
+	if err != nil {
+		return nil, err
+	}
+	if limit < 1 || limit > maxSyncChanges {
+		limit = maxSyncChanges
+	}
+
+	resp := &models.MemoChangesResponse{
+		Created: []models.Memo{},
+		Updated: []models.Memo{},
+		Deleted: []models.MemoTombstone{},
+	}
+
+	// 首次同步：按ID分页返回全部未删除的备忘录，完成后的游标取开始同步时已稳定的序号，
+	// 之后的变更由增量同步补齐
+	if sc.initial {
+		if sc.afterID.IsZero() {
+			if sc.seq, err = database.SettledSequence(ctx, memoSyncCounter, memoSyncSettle); err != nil {
+				return nil, err
+			}
+		}
+
+		filter := bson.M{"user_id": userID, "deleted_at": nil}
+		if !sc.afterID.IsZero() {
+			filter["_id"] = bson.M{"$gt": sc.afterID}
+		}
+		cursor, err := collection.Find(ctx, filter,
+			options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(int64(limit+1)))
+		if err != nil {
+			return nil, err
+		}
+		defer cursor.Close(ctx)
+
+		if err = cursor.All(ctx, &resp.Created); err != nil {
+			return nil, err
+		}
+		resp.Cursor = strconv.FormatInt(sc.seq, 10)
+		if len(resp.Created) > limit {
+			resp.Created = resp.Created[:limit]
+			resp.HasMore = true
+			resp.Cursor = initialSyncCursor(sc.seq, resp.Created[limit-1].ID)
+		}
+		return resp, nil
+	}
+
+	settled, err := database.SettledSequence(ctx, memoSyncCounter, memoSyncSettle)
+	if err != nil {
+		return nil, err
+	}
+
 	filter := bson.M{
-		"_id":     memoID,
-		"user_id": userID,
+		"user_id":  userID,
+		"sync_seq": bson.M{"$gt": sc.seq},
 	}
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "sync_seq", Value: 1}}).
+		SetLimit(int64(limit + 1))
 
-	update := bson.M{
-		"$set": bson.M{
-			"title":      req.Title,
-			"content":    req.Content,
-			"updated_at": time.Now(),
-		},
+	cursor, err := collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, err
 	}
+	defer cursor.Close(ctx)
 
-	result := collection.FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After))
-	if result.Err() != nil {
-		if result.Err() == mongo.ErrNoDocuments {
-			return nil, errors.New("备忘录不存在")
+	var memos []models.Memo
+	if err = cursor.All(ctx, &memos); err != nil {
+		return nil, err
+	}
+
+	if len(memos) > limit {
+		memos = memos[:limit]
+		resp.HasMore = true
+	}
+
+	lastSeq := sc.seq
+	for _, memo := range memos {
+		lastSeq = memo.SyncSeq
+		switch {
+		case memo.DeletedAt != nil:
+			// 最近的变更会重复下发，客户端可能已收到过创建，因此总是下发墓碑，未见过的ID由客户端忽略
+			resp.Deleted = append(resp.Deleted, memoTombstone(&memo))
+		case memo.CreatedSeq > sc.seq:
+			resp.Created = append(resp.Created, memo)
+		default:
+			resp.Updated = append(resp.Updated, memo)
 		}
-		return nil, result.Err()
 	}
 
-	var memo models.Memo
-	if err := result.Decode(&memo); err != nil {
+	// 尚未稳定的序号之前可能还有写入未提交，游标停在已稳定的位置，本页剩余的变更下次重新下发。
+	// 此时不再提示继续拉取，避免客户端反复获取同一页
+	if lastSeq > settled {
+		lastSeq = max(settled, sc.seq)
+		resp.HasMore = false
+	}
+	resp.Cursor = strconv.FormatInt(lastSeq, 10)
+
+	return resp, nil
+}
+
+// 批量应用客户端离线变更，版本不一致的变更作为冲突返回服务端当前状态
+func (s *MemoService) ApplySyncChanges(userID primitive.ObjectID, changes []models.SyncChange) (*models.SyncPushResponse, error) {
+	results := make([]models.SyncChangeResult, 0, len(changes))
+	for _, change := range changes {
+		results = append(results, s.applySyncChange(userID, change))
+	}
+	return &models.SyncPushResponse{Results: results}, nil
+}
+
+func (s *MemoService) applySyncChange(userID primitive.ObjectID, change models.SyncChange) models.SyncChangeResult {
+	result := models.SyncChangeResult{ClientID: change.ClientID, ID: change.ID}
+
+	if change.Op == "create" {
+		if change.Title == "" && change.Encrypted == nil {
+			result.Status = "error"
+			result.Message = "标题不能为空"
+			return result
+		}
+		memo, err := s.createFromSync(userID, change)
+		if err != nil {
+			result.Status = "error"
+			result.Message = err.Error()
+			return result
+		}
+		result.Status = "applied"
+		result.ID = memo.ID.Hex()
+		result.Memo = memo
+		return result
+	}
+
+	memoID, err := primitive.ObjectIDFromHex(change.ID)
+	if err != nil {
+		result.Status = "error"
+		result.Message = "无效的备忘录ID"
+		return result
+	}
+	if change.Op == "update" && change.Title == "" && change.Encrypted == nil {
+		result.Status = "error"
+		result.Message = "标题不能为空"
+		return result
+	}
+
+	memo, conflict, err := s.updateFromSync(userID, memoID, change)
+	if err != nil {
+		result.Status = "error"
+		result.Message = err.Error()
+		return result
+	}
+	if conflict {
+		result.Status = "conflict"
+		result.Message = "备忘录已被其他设备修改"
+	} else {
+		result.Status = "applied"
+	}
+	result.Memo = memo
+	return result
+}
+
+// 同步创建备忘录，按客户端ID去重以保证重试幂等
+func (s *MemoService) createFromSync(userID primitive.ObjectID, change models.SyncChange) (*models.Memo, error) {
+	collection := database.GetCollection("memos")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if change.ClientID != "" {
+		var existing models.Memo
+		err := collection.FindOne(ctx, bson.M{"user_id": userID, "client_id": change.ClientID}).Decode(&existing)
+		if err == nil {
+			return &existing, nil
+		}
+		if err != mongo.ErrNoDocuments {
+			return nil, err
+		}
+	}
+
+	seq, err := database.NextSequence(ctx, memoSyncCounter, 1)
+	if err != nil {
 		return nil, err
 	}
 
-	return &memo, nil
+	memo := &models.Memo{
+		ID:         primitive.NewObjectID(),
+		UserID:     userID,
+		Title:      change.Title,
+		Content:    change.Content,
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+		SyncSeq:    seq,
+		CreatedSeq: seq,
+		ClientID:   change.ClientID,
+	}
+	if change.Encrypted != nil {
+		if err := checkMemoEnvelope(ctx, userID, change.Encrypted); err != nil {
+			return nil, err
+		}
+		memo.Title = ""
+		memo.Content = ""
+		memo.Encrypted = change.Encrypted
+	}
+	s.indexMemo(memo)
+
+	if _, err = collection.InsertOne(ctx, memo); err != nil {
+		return nil, err
+	}
+	s.refreshMemoLinks(ctx, memo)
+	queueEmbedding(memo.ID)
+	Events.publishMemoLocal(userID, memo.ID, models.EventMemoCreated, memo)
+	return memo, nil
 }
 
-// 删除备忘录
-func (s *MemoService) DeleteMemo(userID, memoID primitive.ObjectID) error {
+// 同步更新或删除备忘录，仅当服务端版本与客户端基线版本一致时写入
+func (s *MemoService) updateFromSync(userID, memoID primitive.ObjectID, change models.SyncChange) (*models.Memo, bool, error) {
 	collection := database.GetCollection("memos")
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	seq, err := database.NextSequence(ctx, memoSyncCounter, 1)
+	if err != nil {
+		return nil, false, err
+	}
+
 	filter := bson.M{
-		"_id":     memoID,
-		"user_id": userID,
+		"_id":        memoID,
+		"user_id":    userID,
+		"deleted_at": nil,
+		"sync_seq":   change.BaseVersion,
+	}
+	// 早于增量同步上线的备忘录没有版本号，视为版本0
+	if change.BaseVersion == 0 {
+		filter["sync_seq"] = bson.M{"$in": bson.A{int64(0), nil}}
 	}
 
-	result, err := collection.DeleteOne(ctx, filter)
+	now := time.Now()
+	set := bson.M{}
+	update := bson.M{"$set": set}
+	switch {
+	case change.Op == "delete":
+		set["deleted_at"] = now
+	case change.Encrypted != nil:
+		if err := checkMemoEnvelope(ctx, userID, change.Encrypted); err != nil {
+			return nil, false, err
+		}
+		var unset bson.M
+		set, unset = s.encryptedMemoFields(change.Encrypted)
+		update = bson.M{"$set": set, "$unset": unset}
+	default:
+		// 明文变更不能覆盖密文
+		filter["encrypted"] = nil
+		for key, value := range s.searchFields(change.Title, change.Content) {
+			set[key] = value
+		}
+		set["title"] = change.Title
+		set["content"] = change.Content
+	}
+	set["updated_at"] = now
+	set["sync_seq"] = seq
+
+	var memo models.Memo
+	err = collection.FindOneAndUpdate(ctx, filter, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&memo)
+	if err == nil {
+		s.refreshMemoLinks(ctx, &memo)
+		queueEmbedding(memo.ID)
+		if memo.DeletedAt != nil {
+			Events.publishMemoLocal(userID, memo.ID, models.EventMemoDeleted, memoTombstone(&memo))
+		} else {
+			Events.publishMemoLocal(userID, memo.ID, models.EventMemoUpdated, memo)
+		}
+		return &memo, false, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return nil, false, err
+	}
+
+	// 未命中：区分备忘录不存在与版本冲突
+	err = collection.FindOne(ctx, bson.M{"_id": memoID, "user_id": userID}).Decode(&memo)
 	if err != nil {
-		return err
+		if err == mongo.ErrNoDocuments {
+			return nil, false, errors.New("备忘录不存在")
+		}
+		return nil, false, err
 	}
+	if memo.DeletedAt == nil && memo.SyncSeq == change.BaseVersion && memo.Encrypted != nil {
+		return nil, false, errors.New("加密备忘录需要提交密文")
+	}
+	return &memo, true, nil
+}
 
-	if result.DeletedCount == 0 {
-		return errors.New("备忘录不存在")
+// 使用全文索引搜索备忘录，按相关度排序
+func (s *MemoService) SearchMemos(userID primitive.ObjectID, q string, page, limit int) (*models.MemoSearchResponse, error) {
+	collection := database.GetCollection("memos")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	query, err := utils.ParseSearchQuery(q, s.analyzer)
+	if err != nil {
+		return nil, err
+	}
+
+	// 加密备忘录没有索引内容，这里显式排除
+	filter := bson.M{
+		"user_id":    userID,
+		"deleted_at": nil,
+		"encrypted":  nil,
+		"$text":      bson.M{"$search": query.Text},
+	}
+
+	total, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	findOptions := options.Find().
+		SetProjection(bson.M{"score": bson.M{"$meta": "textScore"}}).
+		SetSort(bson.D{{Key: "score", Value: bson.M{"$meta": "textScore"}}, {Key: "_id", Value: -1}}).
+		SetSkip(int64((page - 1) * limit)).
+		SetLimit(int64(limit))
+
+	cursor, err := collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var docs []struct {
+		models.Memo `bson:",inline"`
+		Score       float64 `bson:"score"`
+	}
+	if err = cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	hits := make([]models.MemoSearchHit, 0, len(docs))
+	for _, doc := range docs {
+		snippet, highlights := utils.BuildSnippet(searchableText(&doc.Memo), query.Terms, snippetRadius)
+		hits = append(hits, models.MemoSearchHit{
+			Memo:            doc.Memo,
+			Score:           doc.Score,
+			Snippet:         snippet,
+			Highlights:      highlights,
+			TitleHighlights: utils.FindHighlights(doc.Title, query.Terms),
+		})
 	}
 
-	return nil
-}
\ No newline at end of file
+	return &models.MemoSearchResponse{
+		List:  hits,
+		Total: total,
+		Page:  page,
+		Limit: limit,
+	}, nil
+}
+
+// 为缺少全文索引字段、摘要或分析器已变更的备忘录重建索引
+func (s *MemoService) BackfillSearchIndex() {
+	collection := database.GetCollection("memos")
+	ctx := context.Background()
+
+	filter := bson.M{"$or": []bson.M{
+		{"search_analyzer": bson.M{"$ne": s.analyzer.Name()}},
+		{"excerpt": bson.M{"$exists": false}, "content": bson.M{"$nin": bson.A{"", nil}}},
+		{"title_lower": bson.M{"$exists": false}, "title": bson.M{"$nin": bson.A{"", nil}}},
+	}}
+	cursor, err := collection.Find(ctx, filter, options.Find().SetProjection(bson.M{"title": 1, "content": 1, "items": 1, "sync_seq": 1}))
+	if err != nil {
+		log.Printf("查询待索引备忘录失败: %v", err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	count := 0
+	for cursor.Next(ctx) {
+		var memo models.Memo
+		if err := cursor.Decode(&memo); err != nil {
+			log.Printf("解析备忘录失败: %v", err)
+			continue
+		}
+		set := s.searchFields(memo.Title, memo.Content)
+		if len(memo.Items) > 0 {
+			set["search_items"] = strings.Join(s.analyzer.IndexTokens(checklistText(memo.Items)), " ")
+		}
+		// 读取后备忘录被修改时跳过，修改时已按新内容写入索引字段，避免用旧内容覆盖
+		result, err := collection.UpdateOne(ctx, memoVersionFilter(&memo), bson.M{"$set": set})
+		if err != nil {
+			log.Printf("重建备忘录 %s 索引失败: %v", memo.ID.Hex(), err)
+			continue
+		}
+		count += int(result.ModifiedCount)
+	}
+	if count > 0 {
+		log.Printf("已为 %d 条备忘录重建全文索引", count)
+	}
+}
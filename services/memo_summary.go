@@ -0,0 +1,191 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"mjbackend/config"
+	"mjbackend/database"
+	"mjbackend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// 生成摘要的最长耗时，同一备忘录的生成标记超过该时长后视为已失效
+const summaryTimeout = 90 * time.Second
+
+// SummarizeMemo 为备忘录生成AI摘要并保存，按配置的价格从当前用户的算力中扣费。
+// 先预扣算力再调用模型，保存成功后确认扣费，任一步骤失败都退回预扣；
+// 正文未变化且已有摘要时直接返回，不重复扣费，force 为 true 时重新生成。
+// 同一备忘录同时只允许一个生成请求，避免并发请求重复扣费
+func (s *MemoService) SummarizeMemo(userID, memoID primitive.ObjectID, force bool) (*models.MemoSummaryResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), summaryTimeout)
+	defer cancel()
+
+	if _, err := authorizeMemo(ctx, userID, memoID, models.RoleEditor); err != nil {
+		return nil, err
+	}
+	memo, pending, err := claimSummary(ctx, memoID)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseSummary(memoID, pending)
+
+	source, err := summarySource(memo)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := summaryHash(memo.Title, source)
+	if !force && memo.Summary != nil && memo.Summary.ContentHash == hash {
+		return &models.MemoSummaryResponse{Summary: memo.Summary, Cached: true}, nil
+	}
+
+	cost := config.AppConfig.AISummaryCost
+	var hold *models.CurrencyHold
+	if cost > 0 {
+		hold, err = s.currencyService.HoldBalance(ctx, userID, cost, "AI摘要", &memoID, holdTimeout)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	summary, err := s.generateSummary(ctx, memo, source, hash)
+	if err != nil {
+		if hold != nil {
+			if releaseErr := s.currencyService.ReleaseHold(context.Background(), hold); releaseErr != nil {
+				log.Printf("退回预扣 %s 失败: %v", hold.ID.Hex(), releaseErr)
+			}
+		}
+		return nil, err
+	}
+
+	if hold != nil {
+		// 预扣已超时退回时摘要仍然有效，只是不再扣费
+		if err := s.currencyService.CaptureHold(ctx, hold); err != nil {
+			log.Printf("确认预扣 %s 失败: %v", hold.ID.Hex(), err)
+			cost = 0
+		}
+	}
+	return &models.MemoSummaryResponse{Summary: summary, Charged: cost}, nil
+}
+
+// claimSummary 以条件更新设置 summary_pending 标记，标记不存在或已过期时才能设置成功，
+// 返回设置后的备忘录，此前并发请求生成的摘要也包含在内，可以直接命中缓存
+func claimSummary(ctx context.Context, memoID primitive.ObjectID) (*models.Memo, time.Time, error) {
+	now := time.Now()
+	// 数据库时间精度为毫秒，截断后才能在清除时按值匹配
+	pending := now.Add(summaryTimeout).Truncate(time.Millisecond)
+
+	var memo models.Memo
+	err := database.GetCollection("memos").FindOneAndUpdate(ctx,
+		bson.M{
+			"_id":        memoID,
+			"deleted_at": nil,
+			"$or": bson.A{
+				bson.M{"summary_pending": nil},
+				bson.M{"summary_pending": bson.M{"$lte": now}},
+			},
+		},
+		bson.M{"$set": bson.M{"summary_pending": pending}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&memo)
+	if err == mongo.ErrNoDocuments {
+		count, countErr := database.GetCollection("memos").CountDocuments(ctx, bson.M{"_id": memoID, "deleted_at": nil})
+		if countErr != nil {
+			return nil, time.Time{}, countErr
+		}
+		if count == 0 {
+			return nil, time.Time{}, errors.New("备忘录不存在")
+		}
+		return nil, time.Time{}, errors.New("摘要正在生成中，请稍后再试")
+	}
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return &memo, pending, nil
+}
+
+// releaseSummary 清除本次请求设置的 summary_pending 标记，标记已过期被其他请求覆盖时不清除
+func releaseSummary(memoID primitive.ObjectID, pending time.Time) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := database.GetCollection("memos").UpdateOne(ctx,
+		bson.M{"_id": memoID, "summary_pending": pending},
+		bson.M{"$unset": bson.M{"summary_pending": ""}},
+	)
+	if err != nil {
+		log.Printf("清除备忘录 %s 的摘要生成标记失败: %v", memoID.Hex(), err)
+	}
+}
+
+// summarySource 返回用于生成摘要的正文，加密或空白的备忘录不能生成摘要
+func summarySource(memo *models.Memo) (string, error) {
+	if memo.Encrypted != nil {
+		return "", errors.New("加密备忘录不支持该操作")
+	}
+	source := memoMarkdown(memo)
+	if strings.TrimSpace(source) == "" {
+		return "", errors.New("备忘录内容为空")
+	}
+	return source, nil
+}
+
+// generateSummary 调用模型生成摘要并写入备忘录。摘要不修改更新时间，但会产生新的同步版本
+func (s *MemoService) generateSummary(ctx context.Context, memo *models.Memo, source, hash string) (*models.MemoSummary, error) {
+	text, err := s.llm.Summarize(ctx, memo.Title, source)
+	if err != nil {
+		log.Printf("备忘录 %s 生成摘要失败: %v", memo.ID.Hex(), err)
+		return nil, errors.New("生成摘要失败")
+	}
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, errors.New("生成摘要失败")
+	}
+
+	summary := &models.MemoSummary{
+		Text:        text,
+		Provider:    s.llm.Name(),
+		ContentHash: hash,
+		CreatedAt:   time.Now(),
+	}
+
+	seq, err := database.NextSequence(ctx, memoSyncCounter, 1)
+	if err != nil {
+		return nil, err
+	}
+	// 生成期间备忘录被删除或加密时不保存
+	var updated models.Memo
+	err = database.GetCollection("memos").FindOneAndUpdate(ctx,
+		bson.M{"_id": memo.ID, "deleted_at": nil, "encrypted": nil},
+		bson.M{"$set": bson.M{"summary": summary, "sync_seq": seq}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("备忘录不存在")
+		}
+		return nil, err
+	}
+
+	Events.publishMemoLocal(updated.UserID, updated.ID, models.EventMemoUpdated, updated)
+	return summary, nil
+}
+
+// summaryHash 计算生成摘要所用内容的哈希
+func summaryHash(title, source string) string {
+	h := sha256.New()
+	h.Write([]byte(title))
+	h.Write([]byte{0})
+	h.Write([]byte(source))
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"testing"
+
+	"mjbackend/models"
+)
+
+func TestSummarySource(t *testing.T) {
+	tests := []struct {
+		name    string
+		memo    *models.Memo
+		want    string
+		wantErr string
+	}{
+		{name: "普通正文", memo: &models.Memo{Content: "正文"}, want: "正文"},
+		{name: "清单条目计入正文", memo: &models.Memo{Items: []models.ChecklistItem{{Text: "牛奶"}}}, want: "- [ ] 牛奶\n"},
+		{name: "加密备忘录", memo: &models.Memo{Content: "正文", Encrypted: &models.MemoEnvelope{}}, wantErr: "加密备忘录不支持该操作"},
+		{name: "空白正文", memo: &models.Memo{Content: " \n\t"}, wantErr: "备忘录内容为空"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := summarySource(tt.memo)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("期望错误 %q，得到 %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("意外错误: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("得到 %q，期望 %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// 摘要缓存按哈希命中，命中时不扣费，所以标题或正文的任何变化都必须改变哈希
+func TestSummaryHash(t *testing.T) {
+	base := summaryHash("标题", "正文")
+	if summaryHash("标题", "正文") != base {
+		t.Fatal("相同内容的哈希应相同")
+	}
+	if summaryHash("标题2", "正文") == base {
+		t.Error("标题变化时哈希应变化")
+	}
+	if summaryHash("标题", "正文2") == base {
+		t.Error("正文变化时哈希应变化")
+	}
+	// 标题与正文之间有分隔，拼接结果相同的两组内容哈希不同
+	if summaryHash("ab", "c") == summaryHash("a", "bc") {
+		t.Error("标题与正文的边界变化时哈希应变化")
+	}
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"mjbackend/database"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// 同步序号在写入前分配，分配后超过该时长的写入都已提交或已超时放弃，
+	// 同步游标只推进到该时长之前已分配的序号，之后的变更在下次同步时重新下发
+	memoSyncSettle = 30 * time.Second
+	// 采样同步计数器的间隔
+	memoSyncSampleInterval = 5 * time.Second
+)
+
+// 首次同步分页时的游标前缀，格式为 init:<完成后的同步游标>:<最后一条备忘录ID>
+const initialSyncCursorPrefix = "init:"
+
+// StartSyncSampler 定期采样备忘录同步计数器，用于计算可以安全推进的同步游标
+func StartSyncSampler() {
+	go func() {
+		ticker := time.NewTicker(memoSyncSampleInterval)
+		defer ticker.Stop()
+		for {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			if err := database.SampleSequence(ctx, memoSyncCounter); err != nil {
+				log.Printf("采样同步序号失败: %v", err)
+			}
+			cancel()
+			<-ticker.C
+		}
+	}()
+}
+
+// syncCursor 解析后的同步游标。首次同步分页时 initial 为 true，seq 为完成后的游标，afterID 为已下发的最后一条备忘录
+type syncCursor struct {
+	seq     int64
+	initial bool
+	afterID primitive.ObjectID
+}
+
+func parseSyncCursor(since string) (*syncCursor, error) {
+	if since == "" || since == "0" {
+		return &syncCursor{initial: true}, nil
+	}
+
+	if rest, ok := strings.CutPrefix(since, initialSyncCursorPrefix); ok {
+		seqPart, idPart, found := strings.Cut(rest, ":")
+		seq, err := strconv.ParseInt(seqPart, 10, 64)
+		if !found || err != nil || seq < 0 {
+			return nil, errors.New("无效的同步游标")
+		}
+		afterID, err := primitive.ObjectIDFromHex(idPart)
+		if err != nil {
+			return nil, errors.New("无效的同步游标")
+		}
+		return &syncCursor{seq: seq, initial: true, afterID: afterID}, nil
+	}
+
+	seq, err := strconv.ParseInt(since, 10, 64)
+	if err != nil || seq < 0 {
+		return nil, errors.New("无效的同步游标")
+	}
+	return &syncCursor{seq: seq}, nil
+}
+
+func initialSyncCursor(seq int64, afterID primitive.ObjectID) string {
+	return initialSyncCursorPrefix + strconv.FormatInt(seq, 10) + ":" + afterID.Hex()
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"mjbackend/database"
+	"mjbackend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type NotificationService struct{}
+
+func NewNotificationService() *NotificationService {
+	return &NotificationService{}
+}
+
+// insertNotification 写入收件箱，去重键已存在时返回 false 且不视为错误
+func insertNotification(ctx context.Context, notification *models.Notification) (bool, error) {
+	_, err := database.GetCollection("notifications").InsertOne(ctx, notification)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// 获取通知列表，unreadOnly 为 true 时只返回未读通知
+func (s *NotificationService) ListNotifications(userID primitive.ObjectID, unreadOnly bool, page, limit int) (*models.NotificationListResponse, error) {
+	collection := database.GetCollection("notifications")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"user_id": userID}
+	if unreadOnly {
+		filter["read_at"] = nil
+	}
+
+	total, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	unread, err := collection.CountDocuments(ctx, bson.M{"user_id": userID, "read_at": nil})
+	if err != nil {
+		return nil, err
+	}
+
+	skip := (page - 1) * limit
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(int64(skip)).
+		SetLimit(int64(limit))
+
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	notifications := []models.Notification{}
+	if err = cursor.All(ctx, &notifications); err != nil {
+		return nil, err
+	}
+
+	return &models.NotificationListResponse{
+		List:   notifications,
+		Total:  total,
+		Unread: unread,
+		Page:   page,
+		Limit:  limit,
+	}, nil
+}
+
+// 标记通知为已读
+func (s *NotificationService) MarkRead(userID, notificationID primitive.ObjectID) error {
+	collection := database.GetCollection("notifications")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := collection.UpdateOne(ctx,
+		bson.M{"_id": notificationID, "user_id": userID, "read_at": nil},
+		bson.M{"$set": bson.M{"read_at": time.Now()}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		// 已读通知重复标记不视为错误
+		count, err := collection.CountDocuments(ctx, bson.M{"_id": notificationID, "user_id": userID})
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return errors.New("通知不存在")
+		}
+	}
+	return nil
+}
+
+// 将全部通知标记为已读，返回标记的数量
+func (s *NotificationService) MarkAllRead(userID primitive.ObjectID) (int64, error) {
+	collection := database.GetCollection("notifications")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := collection.UpdateMany(ctx,
+		bson.M{"user_id": userID, "read_at": nil},
+		bson.M{"$set": bson.M{"read_at": time.Now()}},
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+// 删除通知
+func (s *NotificationService) DeleteNotification(userID, notificationID primitive.ObjectID) error {
+	collection := database.GetCollection("notifications")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := collection.DeleteOne(ctx, bson.M{"_id": notificationID, "user_id": userID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return errors.New("通知不存在")
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"mjbackend/config"
+	"mjbackend/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Notifier 通知投递渠道，通知写入收件箱后依次调用各渠道
+type Notifier interface {
+	Name() string
+	Notify(ctx context.Context, notification *models.Notification) error
+}
+
+var (
+	notifiersOnce sync.Once
+	notifiers     []Notifier
+)
+
+// sendNotification 将通知写入收件箱后通过配置的渠道投递，去重键已存在时返回 false 且不重复投递，
+// 投递失败只记录日志，通知仍保留在收件箱中
+func sendNotification(ctx context.Context, notification *models.Notification) (bool, error) {
+	created, err := insertNotification(ctx, notification)
+	if err != nil || !created {
+		return created, err
+	}
+
+	notifiersOnce.Do(func() { notifiers = NewNotifiers() })
+	for _, notifier := range notifiers {
+		if err := notifier.Notify(ctx, notification); err != nil {
+			log.Printf("通过 %s 投递通知 %s 失败: %v", notifier.Name(), notification.ID.Hex(), err)
+		}
+	}
+	return true, nil
+}
+
+// NewNotifiers 根据配置创建通知投递渠道，未知渠道记录日志后忽略
+func NewNotifiers() []Notifier {
+	var notifiers []Notifier
+	for _, name := range strings.Split(config.AppConfig.Notifiers, ",") {
+		switch strings.TrimSpace(name) {
+		case "":
+		case "event":
+			notifiers = append(notifiers, &EventNotifier{})
+		case "webhook":
+			if config.AppConfig.NotifyWebhookURL == "" {
+				log.Println("未配置 NOTIFY_WEBHOOK_URL，忽略 webhook 通知渠道")
+				continue
+			}
+			notifiers = append(notifiers, &WebhookNotifier{
+				url:    config.AppConfig.NotifyWebhookURL,
+				client: &http.Client{Timeout: 10 * time.Second},
+			})
+		case "log":
+			notifiers = append(notifiers, &LogNotifier{})
+		default:
+			log.Printf("未知的通知渠道 %s，已忽略", name)
+		}
+	}
+	return notifiers
+}
+
+// EventNotifier 通过实时事件推送通知，变更流可用时由变更流统一推送
+type EventNotifier struct{}
+
+func (n *EventNotifier) Name() string { return "event" }
+
+func (n *EventNotifier) Notify(ctx context.Context, notification *models.Notification) error {
+	Events.publishLocal(notification.UserID, models.EventNotificationCreated, notification)
+	return nil
+}
+
+// WebhookNotifier 以JSON POST 方式将通知推送到外部地址
+type WebhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+// webhookPayload 推送给外部地址的通知内容
+type webhookPayload struct {
+	UserID       primitive.ObjectID   `json:"userId"`
+	Notification *models.Notification `json:"notification"`
+}
+
+func (n *WebhookNotifier) Name() string { return "webhook" }
+
+func (n *WebhookNotifier) Notify(ctx context.Context, notification *models.Notification) error {
+	body, err := json.Marshal(webhookPayload{UserID: notification.UserID, Notification: notification})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook 返回状态码 %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// LogNotifier 将通知写入日志，便于开发调试
+type LogNotifier struct{}
+
+func (n *LogNotifier) Name() string { return "log" }
+
+func (n *LogNotifier) Notify(ctx context.Context, notification *models.Notification) error {
+	log.Printf("通知用户 %s: [%s] %s", notification.UserID.Hex(), notification.Type, notification.Title)
+	return nil
+}
@@ -0,0 +1,191 @@
+package services
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"mjbackend/config"
+	"mjbackend/database"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RateLimiter 令牌桶限流器，key 相同的请求共享一个桶。
+// 请求被拒绝时返回需要等待的时长
+type RateLimiter interface {
+	Allow(ctx context.Context, key string, policy config.RateLimitPolicy) (bool, time.Duration, error)
+	// Blocked 检查桶中是否已没有令牌，不消耗令牌。只在失败时调用 Allow 计数的场景使用
+	Blocked(ctx context.Context, key string, policy config.RateLimitPolicy) (bool, time.Duration, error)
+}
+
+// NewRateLimiter 根据配置创建限流器：memory（进程内，单实例部署）或 mongo（多实例共享计数）
+func NewRateLimiter() RateLimiter {
+	switch config.AppConfig.RateLimitStore {
+	case "mongo":
+		return &MongoRateLimiter{}
+	default:
+		return NewMemoryRateLimiter()
+	}
+}
+
+// retryAfter 返回桶中令牌从 tokens 补充到 1 个所需的时长
+func retryAfter(tokens float64, policy config.RateLimitPolicy) time.Duration {
+	return time.Duration(math.Ceil((1 - tokens) / float64(policy.PerMinute) * float64(time.Minute)))
+}
+
+// refillDuration 返回空桶补满所需的时长，超过该时长未访问的桶与新桶等价
+func refillDuration(policy config.RateLimitPolicy) time.Duration {
+	return time.Duration(float64(policy.Burst) / float64(policy.PerMinute) * float64(time.Minute))
+}
+
+type tokenBucket struct {
+	tokens    float64
+	updatedAt time.Time
+	// 桶补满的时间，之后可以删除
+	fullAt time.Time
+}
+
+// MemoryRateLimiter 进程内的令牌桶，多实例部署时每个实例分别计数
+type MemoryRateLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+func NewMemoryRateLimiter() *MemoryRateLimiter {
+	return &MemoryRateLimiter{
+		buckets:   make(map[string]*tokenBucket),
+		lastSweep: time.Now(),
+	}
+}
+
+func (l *MemoryRateLimiter) Allow(ctx context.Context, key string, policy config.RateLimitPolicy) (bool, time.Duration, error) {
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(policy.Burst), updatedAt: now}
+		l.buckets[key] = bucket
+	}
+	elapsed := now.Sub(bucket.updatedAt).Minutes()
+	bucket.tokens = math.Min(float64(policy.Burst), bucket.tokens+elapsed*float64(policy.PerMinute))
+	bucket.updatedAt = now
+
+	if bucket.tokens < 1 {
+		return false, retryAfter(bucket.tokens, policy), nil
+	}
+	bucket.tokens--
+	bucket.fullAt = now.Add(time.Duration((float64(policy.Burst) - bucket.tokens) / float64(policy.PerMinute) * float64(time.Minute)))
+	return true, 0, nil
+}
+
+func (l *MemoryRateLimiter) Blocked(ctx context.Context, key string, policy config.RateLimitPolicy) (bool, time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	bucket, ok := l.buckets[key]
+	if !ok {
+		return false, 0, nil
+	}
+	elapsed := time.Since(bucket.updatedAt).Minutes()
+	tokens := math.Min(float64(policy.Burst), bucket.tokens+elapsed*float64(policy.PerMinute))
+	if tokens < 1 {
+		return true, retryAfter(tokens, policy), nil
+	}
+	return false, 0, nil
+}
+
+// sweep 每分钟删除一次已补满的桶，避免大量不同IP的请求使内存持续增长
+func (l *MemoryRateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+	for key, bucket := range l.buckets {
+		if now.After(bucket.fullAt) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// MongoRateLimiter 将令牌桶保存在 rate_limits 集合中，多实例共享计数。
+// 每次请求以一条流水线更新原子地补充和扣减令牌，时间统一使用数据库服务器时间
+type MongoRateLimiter struct{}
+
+// refilledTokens 返回按数据库服务器时间补充后的令牌数表达式，桶不存在时为满桶
+func refilledTokens(policy config.RateLimitPolicy) bson.M {
+	burst := float64(policy.Burst)
+	return bson.M{"$min": bson.A{
+		burst,
+		bson.M{"$add": bson.A{
+			bson.M{"$ifNull": bson.A{"$tokens", burst}},
+			bson.M{"$multiply": bson.A{
+				bson.M{"$divide": bson.A{
+					bson.M{"$subtract": bson.A{"$$NOW", bson.M{"$ifNull": bson.A{"$updated_at", "$$NOW"}}}},
+					float64(time.Minute / time.Millisecond),
+				}},
+				float64(policy.PerMinute),
+			}},
+		}},
+	}}
+}
+
+func (l *MongoRateLimiter) Allow(ctx context.Context, key string, policy config.RateLimitPolicy) (bool, time.Duration, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"tokens": refilledTokens(policy), "updated_at": "$$NOW"}}},
+		{{Key: "$set", Value: bson.M{"allowed": bson.M{"$gte": bson.A{"$tokens", 1}}}}},
+		{{Key: "$set", Value: bson.M{
+			"tokens": bson.M{"$cond": bson.A{"$allowed", bson.M{"$subtract": bson.A{"$tokens", 1}}, "$tokens"}},
+			// 补满后的桶由 TTL 索引删除
+			"expires_at": bson.M{"$add": bson.A{"$$NOW", refillDuration(policy).Milliseconds()}},
+		}}},
+	}
+
+	var bucket struct {
+		Tokens  float64 `bson:"tokens"`
+		Allowed bool    `bson:"allowed"`
+	}
+	collection := database.GetCollection("rate_limits")
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	err := collection.FindOneAndUpdate(ctx, bson.M{"_id": key}, pipeline, opts).Decode(&bucket)
+	// 并发创建同一个桶时只有一个 upsert 成功，重试一次即可更新已存在的桶
+	if mongo.IsDuplicateKeyError(err) {
+		err = collection.FindOneAndUpdate(ctx, bson.M{"_id": key}, pipeline, opts).Decode(&bucket)
+	}
+	if err != nil {
+		return false, 0, err
+	}
+	if !bucket.Allowed {
+		return false, retryAfter(bucket.Tokens, policy), nil
+	}
+	return true, 0, nil
+}
+
+func (l *MongoRateLimiter) Blocked(ctx context.Context, key string, policy config.RateLimitPolicy) (bool, time.Duration, error) {
+	cursor, err := database.GetCollection("rate_limits").Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"_id": key}}},
+		{{Key: "$project", Value: bson.M{"tokens": refilledTokens(policy)}}},
+	})
+	if err != nil {
+		return false, 0, err
+	}
+	var buckets []struct {
+		Tokens float64 `bson:"tokens"`
+	}
+	if err := cursor.All(ctx, &buckets); err != nil {
+		return false, 0, err
+	}
+	if len(buckets) == 0 || buckets[0].Tokens >= 1 {
+		return false, 0, nil
+	}
+	return true, retryAfter(buckets[0].Tokens, policy), nil
+}
@@ -0,0 +1,304 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"mjbackend/config"
+	"mjbackend/database"
+	"mjbackend/models"
+	"mjbackend/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// 提醒调度器租约名称
+const reminderLeaseName = "reminder_scheduler"
+
+// 每次扫描处理的最大提醒数，剩余的在下一次扫描中处理
+const reminderBatchSize = 100
+
+// 提醒通知正文截取的内容长度（字符）
+const reminderBodyLength = 100
+
+type ReminderService struct{}
+
+func NewReminderService() *ReminderService {
+	return &ReminderService{}
+}
+
+// 设置备忘录的截止时间和提醒，需要编辑及以上权限
+func (s *ReminderService) SetReminder(userID, memoID primitive.ObjectID, req *models.SetReminderRequest) (*models.Memo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var reminder *models.MemoReminder
+	if req.RemindAt != nil {
+		reminder = &models.MemoReminder{
+			At:       req.RemindAt.UTC(),
+			Repeat:   req.Repeat,
+			Timezone: req.Timezone,
+		}
+		next, err := reminderNextAt(reminder, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		if next == nil {
+			return nil, errors.New("提醒时间不能早于当前时间")
+		}
+		reminder.NextAt = next
+	} else if req.Repeat != "" {
+		return nil, errors.New("设置重复规则时必须指定提醒时间")
+	}
+
+	set := bson.M{}
+	unset := bson.M{}
+	if req.DueAt != nil {
+		set["due_at"] = req.DueAt.UTC()
+	} else {
+		unset["due_at"] = ""
+	}
+	if reminder != nil {
+		set["reminder"] = reminder
+	} else {
+		unset["reminder"] = ""
+	}
+
+	return s.updateMemo(ctx, userID, memoID, set, unset)
+}
+
+// 清除备忘录的截止时间和提醒
+func (s *ReminderService) ClearReminder(userID, memoID primitive.ObjectID) (*models.Memo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return s.updateMemo(ctx, userID, memoID, bson.M{}, bson.M{"due_at": "", "reminder": ""})
+}
+
+// updateMemo 以授权时读到的同步版本号为条件写入提醒字段，授权后备忘录被修改或权限变化时重新授权后重试
+func (s *ReminderService) updateMemo(ctx context.Context, userID, memoID primitive.ObjectID, set, unset bson.M) (*models.Memo, error) {
+	collection := database.GetCollection("memos")
+
+	for attempt := 0; attempt < memoWriteRetries; attempt++ {
+		current, err := authorizeMemo(ctx, userID, memoID, models.RoleEditor)
+		if err != nil {
+			return nil, err
+		}
+
+		seq, err := database.NextSequence(ctx, memoSyncCounter, 1)
+		if err != nil {
+			return nil, err
+		}
+
+		filter := memoVersionFilter(current)
+		filter["deleted_at"] = nil
+
+		// 与状态标记一样需要同步到其他设备，但不修改更新时间
+		set["sync_seq"] = seq
+		update := bson.M{"$set": set}
+		if len(unset) > 0 {
+			update["$unset"] = unset
+		}
+
+		var memo models.Memo
+		err = collection.FindOneAndUpdate(ctx, filter, update,
+			options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&memo)
+		if err == mongo.ErrNoDocuments {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		memo.Role = current.Role
+
+		Events.publishMemoLocal(memo.UserID, memo.ID, models.EventMemoUpdated, memo)
+		return &memo, nil
+	}
+	return nil, errors.New("备忘录正在被其他人修改，请重试")
+}
+
+// reminderNextAt 返回严格晚于 after 的下一次提醒时间，没有后续提醒时返回 nil
+func reminderNextAt(reminder *models.MemoReminder, after time.Time) (*time.Time, error) {
+	recurrence, err := utils.ParseRecurrence(reminder.Repeat)
+	if err != nil {
+		return nil, err
+	}
+
+	loc := time.UTC
+	if reminder.Timezone != "" {
+		loc, err = time.LoadLocation(reminder.Timezone)
+		if err != nil {
+			return nil, errors.New("无效的时区")
+		}
+	}
+
+	start := reminder.At.In(loc)
+	if start.After(after) {
+		next := start.UTC()
+		return &next, nil
+	}
+	if recurrence == nil {
+		return nil, nil
+	}
+	next, ok := recurrence.Next(start, after)
+	if !ok {
+		return nil, nil
+	}
+	next = next.UTC()
+	return &next, nil
+}
+
+// ReminderScheduler 提醒调度器，多实例部署时通过租约保证同一时刻只有一个实例扫描，
+// 通知按去重键写入收件箱，提醒时间按比较交换推进，保证每次提醒只触发一次
+type ReminderScheduler struct {
+	interval time.Duration
+}
+
+// StartReminderScheduler 启动后台提醒调度
+func StartReminderScheduler() {
+	scheduler := &ReminderScheduler{
+		interval: time.Duration(config.AppConfig.ReminderScanIntervalSeconds) * time.Second,
+	}
+	go scheduler.run()
+}
+
+func (s *ReminderScheduler) run() {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		s.tick()
+		<-ticker.C
+	}
+}
+
+func (s *ReminderScheduler) tick() {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	// 租约有效期覆盖多个扫描周期，持有者宕机后由其他实例接管
+	held, err := acquireLease(ctx, reminderLeaseName, instanceID, 3*s.interval)
+	if err != nil {
+		log.Printf("获取提醒调度租约失败: %v", err)
+		return
+	}
+	if !held {
+		return
+	}
+
+	now := time.Now()
+	cursor, err := database.GetCollection("memos").Find(ctx, bson.M{
+		"reminder.next_at": bson.M{"$lte": now},
+		"deleted_at":       nil,
+	}, options.Find().
+		SetSort(bson.D{{Key: "reminder.next_at", Value: 1}}).
+		SetLimit(reminderBatchSize))
+	if err != nil {
+		log.Printf("查询到期提醒失败: %v", err)
+		return
+	}
+
+	var memos []models.Memo
+	if err := cursor.All(ctx, &memos); err != nil {
+		log.Printf("读取到期提醒失败: %v", err)
+		return
+	}
+
+	for i := range memos {
+		if err := s.fire(ctx, &memos[i], now); err != nil {
+			log.Printf("触发备忘录 %s 的提醒失败: %v", memos[i].ID.Hex(), err)
+		}
+	}
+}
+
+// fire 向备忘录的创建者和协作者发送提醒，并推进到下一次提醒时间
+func (s *ReminderScheduler) fire(ctx context.Context, memo *models.Memo, now time.Time) error {
+	occurrence := *memo.Reminder.NextAt
+	memoID := memo.ID
+
+	for _, userID := range memoAudience(ctx, memo.UserID, memo.ID) {
+		notification := &models.Notification{
+			ID:        primitive.NewObjectID(),
+			UserID:    userID,
+			Type:      models.NotificationMemoReminder,
+			Title:     memoDisplayTitle(memo),
+			Body:      reminderBody(memo),
+			MemoID:    &memoID,
+			FireAt:    &occurrence,
+			DedupeKey: fmt.Sprintf("reminder:%s:%s:%d", memo.ID.Hex(), userID.Hex(), occurrence.Unix()),
+			CreatedAt: now,
+		}
+		if _, err := sendNotification(ctx, notification); err != nil {
+			// 提醒时间未推进，下次扫描时重试，已写入的通知会被去重
+			return err
+		}
+	}
+
+	// 错过的重复提醒不再补发，直接跳到当前时间之后的下一次
+	after := occurrence
+	if now.After(after) {
+		after = now
+	}
+	next, err := reminderNextAt(memo.Reminder, after)
+	if err != nil {
+		return err
+	}
+
+	seq, err := database.NextSequence(ctx, memoSyncCounter, 1)
+	if err != nil {
+		return err
+	}
+	set := bson.M{"reminder.last_fired_at": occurrence, "sync_seq": seq}
+	update := bson.M{"$set": set}
+	if next != nil {
+		set["reminder.next_at"] = *next
+	} else {
+		update["$unset"] = bson.M{"reminder.next_at": ""}
+	}
+
+	// 仅当提醒时间未被其他实例或用户修改时推进
+	var updated models.Memo
+	err = database.GetCollection("memos").FindOneAndUpdate(ctx,
+		bson.M{"_id": memo.ID, "reminder.next_at": occurrence, "deleted_at": nil},
+		update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil
+		}
+		return err
+	}
+
+	Events.publishMemoLocal(updated.UserID, updated.ID, models.EventMemoUpdated, updated)
+	return nil
+}
+
+// reminderBody 生成提醒通知正文：截止时间和内容摘要
+func reminderBody(memo *models.Memo) string {
+	body := []rune(memo.Content)
+	if len(body) > reminderBodyLength {
+		body = append(body[:reminderBodyLength], '…')
+	}
+	if memo.DueAt == nil {
+		return string(body)
+	}
+
+	loc := time.UTC
+	if memo.Reminder.Timezone != "" {
+		if l, err := time.LoadLocation(memo.Reminder.Timezone); err == nil {
+			loc = l
+		}
+	}
+	due := "截止时间：" + memo.DueAt.In(loc).Format("2006-01-02 15:04")
+	if len(body) == 0 {
+		return due
+	}
+	return due + "\n" + string(body)
+}
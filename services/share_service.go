@@ -0,0 +1,144 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"mjbackend/database"
+	"mjbackend/models"
+	"mjbackend/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// 分享令牌随机字节数
+const shareTokenBytes = 32
+
+type ShareService struct{}
+
+func NewShareService() *ShareService {
+	return &ShareService{}
+}
+
+// 为备忘录创建分享链接，需要所有者权限，链接记在备忘录创建者名下
+func (s *ShareService) CreateShare(userID, memoID primitive.ObjectID, req *models.CreateShareRequest) (*models.MemoShare, error) {
+	collection := database.GetCollection("memo_shares")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	memo, err := authorizeMemo(ctx, userID, memoID, models.RoleOwner)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := generateShareToken()
+	if err != nil {
+		return nil, err
+	}
+
+	share := &models.MemoShare{
+		ID:        primitive.NewObjectID(),
+		UserID:    memo.UserID,
+		MemoID:    memoID,
+		Token:     token,
+		CreatedAt: time.Now(),
+	}
+	if req.ExpiresInHours > 0 {
+		expiresAt := time.Now().Add(time.Duration(req.ExpiresInHours) * time.Hour)
+		share.ExpiresAt = &expiresAt
+	}
+	if req.Password != "" {
+		share.PasswordHash, err = utils.HashPassword(req.Password)
+		if err != nil {
+			return nil, err
+		}
+		share.HasPassword = true
+	}
+
+	if _, err = collection.InsertOne(ctx, share); err != nil {
+		return nil, err
+	}
+	return share, nil
+}
+
+// 获取备忘录的分享链接列表（包含已撤销和已过期的链接），需要所有者权限
+func (s *ShareService) ListShares(userID, memoID primitive.ObjectID) ([]models.MemoShare, error) {
+	collection := database.GetCollection("memo_shares")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := authorizeMemo(ctx, userID, memoID, models.RoleOwner); err != nil {
+		return nil, err
+	}
+
+	cursor, err := collection.Find(ctx, bson.M{"memo_id": memoID},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	shares := []models.MemoShare{}
+	if err = cursor.All(ctx, &shares); err != nil {
+		return nil, err
+	}
+	return shares, nil
+}
+
+// 撤销分享链接，需要所有者权限
+func (s *ShareService) RevokeShare(userID, memoID, shareID primitive.ObjectID) error {
+	collection := database.GetCollection("memo_shares")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := authorizeMemo(ctx, userID, memoID, models.RoleOwner); err != nil {
+		return err
+	}
+
+	result, err := collection.UpdateOne(ctx, bson.M{
+		"_id":        shareID,
+		"memo_id":    memoID,
+		"revoked_at": nil,
+	}, bson.M{"$set": bson.M{"revoked_at": time.Now()}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errors.New("分享链接不存在")
+	}
+	return nil
+}
+
+// 查找有效的分享链接，已撤销或已过期的链接视为不存在
+func findActiveShare(ctx context.Context, token string) (*models.MemoShare, error) {
+	var share models.MemoShare
+	err := database.GetCollection("memo_shares").FindOne(ctx, bson.M{
+		"token":      token,
+		"revoked_at": nil,
+		"$or": []bson.M{
+			{"expires_at": nil},
+			{"expires_at": bson.M{"$gt": time.Now()}},
+		},
+	}).Decode(&share)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("分享链接不存在")
+		}
+		return nil, err
+	}
+	return &share, nil
+}
+
+func generateShareToken() (string, error) {
+	buf := make([]byte, shareTokenBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
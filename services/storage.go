@@ -0,0 +1,149 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"mjbackend/config"
+	"mjbackend/database"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// BlobStorage 二进制文件存储接口，key 由实现生成并由调用方保存
+type BlobStorage interface {
+	Save(ctx context.Context, filename string, r io.Reader) (key string, err error)
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// NewBlobStorage 根据配置创建文件存储，bucket 为 GridFS 桶名，localDir 为本地存储目录
+func NewBlobStorage(bucket, localDir string) BlobStorage {
+	switch config.AppConfig.AttachmentStorage {
+	case "local":
+		return &LocalStorage{dir: localDir}
+	default:
+		return &GridFSStorage{bucketName: bucket}
+	}
+}
+
+// GridFSStorage 使用MongoDB GridFS存储文件
+type GridFSStorage struct {
+	bucketName string
+}
+
+func (s *GridFSStorage) bucket() (*gridfs.Bucket, error) {
+	return gridfs.NewBucket(database.DB, options.GridFSBucket().SetName(s.bucketName))
+}
+
+func (s *GridFSStorage) Save(ctx context.Context, filename string, r io.Reader) (string, error) {
+	bucket, err := s.bucket()
+	if err != nil {
+		return "", err
+	}
+	id, err := bucket.UploadFromStream(filename, r)
+	if err != nil {
+		return "", err
+	}
+	return id.Hex(), nil
+}
+
+func (s *GridFSStorage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	id, err := primitive.ObjectIDFromHex(key)
+	if err != nil {
+		return nil, errors.New("无效的文件标识")
+	}
+	bucket, err := s.bucket()
+	if err != nil {
+		return nil, err
+	}
+	return bucket.OpenDownloadStream(id)
+}
+
+func (s *GridFSStorage) Delete(ctx context.Context, key string) error {
+	id, err := primitive.ObjectIDFromHex(key)
+	if err != nil {
+		return errors.New("无效的文件标识")
+	}
+	bucket, err := s.bucket()
+	if err != nil {
+		return err
+	}
+	err = bucket.DeleteContext(ctx, id)
+	if err == gridfs.ErrFileNotFound {
+		return nil
+	}
+	return err
+}
+
+// LocalStorage 使用本地文件系统存储文件
+type LocalStorage struct {
+	dir string
+}
+
+func (s *LocalStorage) path(key string) (string, error) {
+	// key 为十六进制随机串，拒绝其他形式以防路径穿越
+	if len(key) < 4 || strings.Trim(key, "0123456789abcdef") != "" {
+		return "", errors.New("无效的文件标识")
+	}
+	return filepath.Join(s.dir, key[:2], key), nil
+}
+
+func (s *LocalStorage) Save(ctx context.Context, filename string, r io.Reader) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	key := hex.EncodeToString(buf)
+
+	path, err := s.path(key)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		os.Remove(path)
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	return key, nil
+}
+
+func (s *LocalStorage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+func (s *LocalStorage) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,345 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"time"
+	"unicode/utf8"
+
+	"mjbackend/database"
+	"mjbackend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// 每个用户最多创建的模板数
+const maxUserTemplates = 100
+
+// 与 TemplateRequest 和 CreateMemoRequest 中的标题、清单条目长度限制一致
+const (
+	maxTemplateTitleLength = 200
+	maxChecklistItemLength = 1000
+)
+
+// 模板占位符：{{name}}，名称两侧允许空白
+var templateVarPattern = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+var weekdayNames = [...]string{"星期日", "星期一", "星期二", "星期三", "星期四", "星期五", "星期六"}
+
+// 内置模板使用固定ID，不会与数据库生成的ID冲突
+var builtinTemplates = []models.MemoTemplate{
+	{
+		ID:          builtinTemplateID("000000000000000000000001"),
+		Name:        "会议纪要",
+		Description: "记录会议主题、参会人、讨论内容和待办事项",
+		Title:       "{{date}} 会议纪要",
+		Content:     "## 会议信息\n\n- 时间：{{datetime}}\n- 记录人：{{username}}\n- 参会人：\n\n## 议题\n\n## 讨论内容\n\n## 结论\n\n## 待办事项\n\n- [ ] \n",
+		Tags:        []string{"会议"},
+	},
+	{
+		ID:          builtinTemplateID("000000000000000000000002"),
+		Name:        "每日日记",
+		Description: "按日期记录当天的事情和感想",
+		Title:       "{{date}} {{weekday}}",
+		Content:     "## 今天做了什么\n\n## 收获与感想\n\n## 明天的计划\n",
+		Tags:        []string{"日记"},
+	},
+	{
+		ID:          builtinTemplateID("000000000000000000000003"),
+		Name:        "待办清单",
+		Description: "当天的待办事项清单",
+		Title:       "{{date}} 待办",
+		Type:        models.MemoTypeChecklist,
+		Items:       []string{"查看邮件", "整理今日计划"},
+	},
+}
+
+func builtinTemplateID(hex string) primitive.ObjectID {
+	id, _ := primitive.ObjectIDFromHex(hex)
+	return id
+}
+
+func findBuiltinTemplate(id primitive.ObjectID) *models.MemoTemplate {
+	for i := range builtinTemplates {
+		if builtinTemplates[i].ID == id {
+			template := builtinTemplates[i]
+			template.Builtin = true
+			return &template
+		}
+	}
+	return nil
+}
+
+type TemplateService struct{}
+
+func NewTemplateService() *TemplateService {
+	return &TemplateService{}
+}
+
+// 获取模板列表，内置模板在前
+func (s *TemplateService) ListTemplates(userID primitive.ObjectID) ([]models.MemoTemplate, error) {
+	collection := database.GetCollection("memo_templates")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	templates := make([]models.MemoTemplate, 0, len(builtinTemplates))
+	for _, template := range builtinTemplates {
+		template.Builtin = true
+		templates = append(templates, template)
+	}
+
+	cursor, err := collection.Find(ctx, bson.M{"user_id": userID},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	var own []models.MemoTemplate
+	if err = cursor.All(ctx, &own); err != nil {
+		return nil, err
+	}
+	return append(templates, own...), nil
+}
+
+// 获取单个模板
+func (s *TemplateService) GetTemplate(userID, templateID primitive.ObjectID) (*models.MemoTemplate, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return getTemplate(ctx, userID, templateID)
+}
+
+func getTemplate(ctx context.Context, userID, templateID primitive.ObjectID) (*models.MemoTemplate, error) {
+	if template := findBuiltinTemplate(templateID); template != nil {
+		return template, nil
+	}
+
+	var template models.MemoTemplate
+	err := database.GetCollection("memo_templates").FindOne(ctx, bson.M{
+		"_id":     templateID,
+		"user_id": userID,
+	}).Decode(&template)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("模板不存在")
+		}
+		return nil, err
+	}
+	return &template, nil
+}
+
+// 创建模板
+func (s *TemplateService) CreateTemplate(userID primitive.ObjectID, req *models.TemplateRequest) (*models.MemoTemplate, error) {
+	collection := database.GetCollection("memo_templates")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := validateTemplate(req); err != nil {
+		return nil, err
+	}
+
+	count, err := collection.CountDocuments(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return nil, err
+	}
+	if count >= maxUserTemplates {
+		return nil, errors.New("模板数量超过上限")
+	}
+
+	now := time.Now()
+	template := &models.MemoTemplate{
+		ID:        primitive.NewObjectID(),
+		UserID:    userID,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	applyTemplateRequest(template, req)
+
+	if _, err := collection.InsertOne(ctx, template); err != nil {
+		return nil, err
+	}
+	return template, nil
+}
+
+// 更新模板，内置模板不能修改
+func (s *TemplateService) UpdateTemplate(userID, templateID primitive.ObjectID, req *models.TemplateRequest) (*models.MemoTemplate, error) {
+	collection := database.GetCollection("memo_templates")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if findBuiltinTemplate(templateID) != nil {
+		return nil, errors.New("内置模板不能修改")
+	}
+	if err := validateTemplate(req); err != nil {
+		return nil, err
+	}
+
+	template := &models.MemoTemplate{}
+	applyTemplateRequest(template, req)
+	update := bson.M{
+		"$set": bson.M{
+			"name":        template.Name,
+			"description": template.Description,
+			"title":       template.Title,
+			"content":     template.Content,
+			"type":        template.Type,
+			"items":       template.Items,
+			"tags":        template.Tags,
+			"folder":      template.Folder,
+			"updated_at":  time.Now(),
+		},
+	}
+
+	var updated models.MemoTemplate
+	err := collection.FindOneAndUpdate(ctx,
+		bson.M{"_id": templateID, "user_id": userID},
+		update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("模板不存在")
+		}
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// 删除模板，内置模板不能删除
+func (s *TemplateService) DeleteTemplate(userID, templateID primitive.ObjectID) error {
+	collection := database.GetCollection("memo_templates")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if findBuiltinTemplate(templateID) != nil {
+		return errors.New("内置模板不能修改")
+	}
+
+	result, err := collection.DeleteOne(ctx, bson.M{"_id": templateID, "user_id": userID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return errors.New("模板不存在")
+	}
+	return nil
+}
+
+func validateTemplate(req *models.TemplateRequest) error {
+	if req.Type != models.MemoTypeChecklist && len(req.Items) > 0 {
+		return errors.New("只有清单备忘录可以包含条目")
+	}
+	return nil
+}
+
+func applyTemplateRequest(template *models.MemoTemplate, req *models.TemplateRequest) {
+	template.Name = req.Name
+	template.Description = req.Description
+	template.Title = req.Title
+	template.Content = req.Content
+	template.Type = req.Type
+	if template.Type == models.MemoTypeNote {
+		template.Type = ""
+	}
+	template.Items = req.Items
+	template.Tags = normalizeTags(req.Tags)
+	if len(template.Tags) == 0 {
+		template.Tags = nil
+	}
+	template.Folder = req.Folder
+}
+
+// applyTemplate 将模板合并到创建备忘录请求中：请求中非空的字段优先，
+// 其余字段取自模板，并替换标题、内容和清单条目中的占位符
+func applyTemplate(ctx context.Context, userID primitive.ObjectID, req *models.CreateMemoRequest) error {
+	templateID, err := primitive.ObjectIDFromHex(req.TemplateID)
+	if err != nil {
+		return errors.New("模板不存在")
+	}
+	template, err := getTemplate(ctx, userID, templateID)
+	if err != nil {
+		return err
+	}
+
+	vars, err := templateVariables(ctx, userID, req.Timezone, req.Variables)
+	if err != nil {
+		return err
+	}
+
+	// 占位符替换后标题和清单条目可能变长，按模板和创建请求的长度限制重新校验
+	if req.Title == "" {
+		req.Title = renderTemplate(template.Title, vars)
+		if utf8.RuneCountInString(req.Title) > maxTemplateTitleLength {
+			return errors.New("模板生成的标题过长")
+		}
+	}
+	if req.Content == "" {
+		req.Content = renderTemplate(template.Content, vars)
+	}
+	if req.Type == "" {
+		req.Type = template.Type
+	}
+	if len(req.Items) == 0 && req.Type == template.Type {
+		for _, item := range template.Items {
+			item = renderTemplate(item, vars)
+			if utf8.RuneCountInString(item) > maxChecklistItemLength {
+				return errors.New("模板生成的清单条目过长")
+			}
+			req.Items = append(req.Items, item)
+		}
+	}
+	if len(req.Tags) == 0 {
+		req.Tags = template.Tags
+	}
+	if req.Folder == "" {
+		req.Folder = template.Folder
+	}
+	return nil
+}
+
+// templateVariables 生成内置变量 date、time、datetime、weekday、username，用户提供的同名变量优先
+func templateVariables(ctx context.Context, userID primitive.ObjectID, timezone string, custom map[string]string) (map[string]string, error) {
+	loc := time.UTC
+	if timezone != "" {
+		var err error
+		loc, err = time.LoadLocation(timezone)
+		if err != nil {
+			return nil, errors.New("无效的时区")
+		}
+	}
+	now := time.Now().In(loc)
+
+	vars := map[string]string{
+		"date":     now.Format("2006-01-02"),
+		"time":     now.Format("15:04"),
+		"datetime": now.Format("2006-01-02 15:04"),
+		"weekday":  weekdayNames[now.Weekday()],
+	}
+
+	var user models.User
+	err := database.GetCollection("users").FindOne(ctx, bson.M{"_id": userID},
+		options.FindOne().SetProjection(bson.M{"username": 1})).Decode(&user)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+	vars["username"] = user.Username
+
+	for name, value := range custom {
+		vars[name] = value
+	}
+	return vars, nil
+}
+
+// renderTemplate 替换占位符，没有取值的占位符原样保留
+func renderTemplate(text string, vars map[string]string) string {
+	return templateVarPattern.ReplaceAllStringFunc(text, func(match string) string {
+		name := templateVarPattern.FindStringSubmatch(match)[1]
+		if value, ok := vars[name]; ok {
+			return value
+		}
+		return match
+	})
+}
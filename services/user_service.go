@@ -3,8 +3,10 @@ package services
 import (
 	"context"
 	"errors"
+	"log"
 	"time"
 
+	"mjbackend/config"
 	"mjbackend/database"
 	"mjbackend/models"
 	"mjbackend/utils"
@@ -63,24 +65,62 @@ func (s *UserService) Register(req *models.RegisterRequest) (*models.User, error
 	return user, nil
 }
 
-// 用户登录
-func (s *UserService) Login(req *models.LoginRequest) (*models.LoginResponse, error) {
+// 用户登录，ip 和 userAgent 用于失败计数和登录记录。
+// 账号或IP连续失败过多时在校验密码前拒绝，返回 *LoginThrottledError
+func (s *UserService) Login(req *models.LoginRequest, ip, userAgent string) (*models.LoginResponse, error) {
 	collection := database.GetCollection("users")
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	attempt := &models.LoginAttempt{
+		Username:  req.Username,
+		IP:        ip,
+		UserAgent: userAgent,
+		Result:    models.LoginFailed,
+	}
+
 	// 查找用户（仅支持用户名登录）
 	var user models.User
 	err := collection.FindOne(ctx, bson.M{"username": req.Username}).Decode(&user)
+	if err == nil {
+		attempt.UserID = &user.ID
+	} else if err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+
+	knownIP := false
+	if attempt.UserID != nil {
+		if knownIP, err = knownLoginIP(ctx, user.ID, ip); err != nil {
+			return nil, err
+		}
+	}
+
+	// 用户名不存在时同样计数和锁定，避免通过锁定行为判断用户名是否存在
+	account, err := checkLoginAllowed(ctx, req.Username, ip, knownIP)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			return nil, errors.New("用户名或密码错误")
+		var throttled *LoginThrottledError
+		if errors.As(err, &throttled) {
+			attempt.Result = models.LoginBlocked
+			recordLoginAttempt(ctx, attempt)
 		}
 		return nil, err
 	}
 
-	// 验证密码
-	if !utils.CheckPasswordHash(req.Password, user.Password) {
+	// 验证密码，用户名不存在时也执行一次比较，使响应耗时一致
+	passwordOK := false
+	if attempt.UserID == nil {
+		checkDummyPassword(req.Password)
+	} else {
+		passwordOK = utils.CheckPasswordHash(req.Password, user.Password)
+	}
+	if !passwordOK {
+		recordLoginAttempt(ctx, attempt)
+		if err := recordLoginFailure(ctx, accountLockKey(req.Username), config.AppConfig.LoginMaxFailures, true); err != nil {
+			log.Printf("记录账号登录失败次数失败: %v", err)
+		}
+		if err := recordLoginFailure(ctx, ipLockKey(ip), config.AppConfig.LoginIPMaxFailures, false); err != nil {
+			log.Printf("记录IP登录失败次数失败: %v", err)
+		}
 		return nil, errors.New("用户名或密码错误")
 	}
 
@@ -90,6 +130,20 @@ func (s *UserService) Login(req *models.LoginRequest) (*models.LoginResponse, er
 		return nil, err
 	}
 
+	// 登录成功后清除账号的失败计数，IP计数保留到过期，避免攻击者用自己的账号重置
+	attempt.Result = models.LoginSucceeded
+	attempt.SuspiciousReasons = suspiciousReasons(ctx, user.ID, knownIP, account)
+	attempt.Suspicious = len(attempt.SuspiciousReasons) > 0
+	recordLoginAttempt(ctx, attempt)
+	if attempt.Suspicious {
+		notifySuspiciousLogin(ctx, attempt)
+	}
+	if account != nil {
+		if _, err := database.GetCollection("login_failures").DeleteOne(ctx, bson.M{"_id": account.Key}); err != nil {
+			log.Printf("清除账号登录失败次数失败: %v", err)
+		}
+	}
+
 	return &models.LoginResponse{
 		Token: token,
 		User: models.UserResponse{
@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Analyzer 将文本切分为全文索引词元
+type Analyzer interface {
+	// Name 分析器名称，用于判断已索引的文档是否需要重建
+	Name() string
+	// Tokenize 返回按原文顺序排列的词元
+	Tokenize(text string) []string
+	// IndexTokens 返回写入索引的全部词元，可包含 Tokenize 之外的补充词元
+	IndexTokens(text string) []string
+}
+
+// NewAnalyzer 根据名称创建分析器，未知名称使用CJK分析器
+func NewAnalyzer(name string) Analyzer {
+	switch name {
+	case "simple":
+		return SimpleAnalyzer{}
+	default:
+		return CJKAnalyzer{}
+	}
+}
+
+// SimpleAnalyzer 按非字母数字字符切分并转为小写，适用于以空格分词的语言
+type SimpleAnalyzer struct{}
+
+func (SimpleAnalyzer) Name() string {
+	return "simple"
+}
+
+func (SimpleAnalyzer) Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func (a SimpleAnalyzer) IndexTokens(text string) []string {
+	return a.Tokenize(text)
+}
+
+// CJKAnalyzer 中日韩文字按二元组切分，其余文字按单词切分
+type CJKAnalyzer struct{}
+
+func (CJKAnalyzer) Name() string {
+	return "cjk"
+}
+
+func (CJKAnalyzer) Tokenize(text string) []string {
+	var tokens []string
+	var word []rune
+	var cjk []rune
+
+	flushWord := func() {
+		if len(word) > 0 {
+			tokens = append(tokens, string(word))
+			word = word[:0]
+		}
+	}
+	flushCJK := func() {
+		switch {
+		case len(cjk) == 1:
+			tokens = append(tokens, string(cjk))
+		case len(cjk) > 1:
+			for i := 0; i+1 < len(cjk); i++ {
+				tokens = append(tokens, string(cjk[i:i+2]))
+			}
+		}
+		cjk = cjk[:0]
+	}
+
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case isCJK(r):
+			flushWord()
+			cjk = append(cjk, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushCJK()
+			word = append(word, r)
+		default:
+			flushWord()
+			flushCJK()
+		}
+	}
+	flushWord()
+	flushCJK()
+
+	return tokens
+}
+
+// IndexTokens 在二元组之后追加单字词元，使单字查询也能命中
+func (a CJKAnalyzer) IndexTokens(text string) []string {
+	tokens := a.Tokenize(text)
+	seen := make(map[rune]bool)
+	for _, r := range strings.ToLower(text) {
+		if isCJK(r) && !seen[r] {
+			seen[r] = true
+			tokens = append(tokens, string(r))
+		}
+	}
+	return tokens
+}
+
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+// EncodePNG 将图片编码为 PNG
+func EncodePNG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ScaleUp 按整数倍放大图片，每个像素复制为 scale×scale 的色块
+func ScaleUp(src image.Image, scale int) image.Image {
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	dst := image.NewNRGBA(image.Rect(0, 0, w*scale, h*scale))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.NRGBAModel.Convert(src.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.NRGBA)
+			for dy := 0; dy < scale; dy++ {
+				for dx := 0; dx < scale; dx++ {
+					dst.SetNRGBA(x*scale+dx, y*scale+dy, c)
+				}
+			}
+		}
+	}
+	return dst
+}
+
+// Tint 将图片与指定颜色按 weight（0-255）混合，保留透明通道
+func Tint(src image.Image, tint color.NRGBA, weight uint8) image.Image {
+	bounds := src.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	mix := func(a, b uint8) uint8 {
+		return uint8((int(a)*(255-int(weight)) + int(b)*int(weight)) / 255)
+	}
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			c := color.NRGBAModel.Convert(src.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.NRGBA)
+			dst.SetNRGBA(x, y, color.NRGBA{R: mix(c.R, tint.R), G: mix(c.G, tint.G), B: mix(c.B, tint.B), A: c.A})
+		}
+	}
+	return dst
+}
+
+// Gradient 生成从 from 到 to 的对角渐变图片，用作占位图
+func Gradient(width, height int, from, to color.NRGBA) image.Image {
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	span := width + height - 2
+	if span < 1 {
+		span = 1
+	}
+	lerp := func(a, b uint8, t int) uint8 {
+		return uint8(int(a) + (int(b)-int(a))*t/span)
+	}
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			t := x + y
+			dst.SetNRGBA(x, y, color.NRGBA{R: lerp(from.R, to.R, t), G: lerp(from.G, to.G, t), B: lerp(from.B, to.B, t), A: 255})
+		}
+	}
+	return dst
+}
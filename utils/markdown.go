@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"bytes"
+	"html"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	goldmarkhtml "github.com/yuin/goldmark/renderer/html"
+)
+
+// 摘要的最大字符数
+const ExcerptLength = 200
+
+// 备忘录正文按 CommonMark 解析，并启用 GFM 的表格、删除线、任务列表和自动链接。
+// 允许输出原始 HTML，再统一由白名单策略过滤
+var markdown = goldmark.New(
+	goldmark.WithExtensions(extension.GFM),
+	goldmark.WithRendererOptions(goldmarkhtml.WithUnsafe()),
+)
+
+// htmlPolicy 用户内容的HTML白名单：去除脚本、事件属性和危险链接，
+// 额外允许任务列表的复选框和代码块的语言类名
+var htmlPolicy = newHTMLPolicy()
+
+// textPolicy 去除全部标签，仅保留文本
+var textPolicy = bluemonday.StrictPolicy()
+
+var whitespacePattern = regexp.MustCompile(`\s+`)
+
+func newHTMLPolicy() *bluemonday.Policy {
+	policy := bluemonday.UGCPolicy()
+	policy.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	policy.AllowAttrs("checked", "disabled").OnElements("input")
+	policy.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+-]+$`)).OnElements("code")
+	policy.RequireNoFollowOnLinks(true)
+	policy.AddTargetBlankToFullyQualifiedLinks(true)
+	return policy
+}
+
+// RenderMarkdown 将 Markdown 渲染为经过过滤、可直接嵌入页面的HTML
+func RenderMarkdown(source string) (string, error) {
+	var buf bytes.Buffer
+	if err := markdown.Convert([]byte(source), &buf); err != nil {
+		return "", err
+	}
+	return htmlPolicy.Sanitize(buf.String()), nil
+}
+
+// MarkdownText 提取 Markdown 的纯文本，去除格式标记并合并空白
+func MarkdownText(source string) (string, error) {
+	var buf bytes.Buffer
+	if err := markdown.Convert([]byte(source), &buf); err != nil {
+		return "", err
+	}
+	text := html.UnescapeString(textPolicy.Sanitize(buf.String()))
+	return strings.TrimSpace(whitespacePattern.ReplaceAllString(text, " ")), nil
+}
+
+// Excerpt 生成 Markdown 的纯文本摘要，超过 ExcerptLength 个字符时截断并追加省略号
+func Excerpt(source string) string {
+	text, err := MarkdownText(source)
+	if err != nil {
+		text = strings.TrimSpace(whitespacePattern.ReplaceAllString(source, " "))
+	}
+	if utf8.RuneCountInString(text) <= ExcerptLength {
+		return text
+	}
+	runes := []rune(text)
+	return strings.TrimSpace(string(runes[:ExcerptLength])) + "…"
+}
@@ -0,0 +1,199 @@
+package utils
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	// 内置时区数据，运行镜像中没有 zoneinfo 时也能解析时区
+	_ "time/tzdata"
+)
+
+// 计算下一次重复时间时的最大迭代次数，防止异常规则导致死循环
+const maxRecurrenceSteps = 100000
+
+// Recurrence 重复规则，支持 daily、weekly、monthly、yearly 简写和 RRULE 子集
+// （FREQ、INTERVAL、BYDAY、UNTIL）
+type Recurrence struct {
+	Freq     string
+	Interval int
+	// ByDay 仅用于每周重复，取值为 time.Weekday
+	ByDay []time.Weekday
+	Until *time.Time
+}
+
+var weekdayCodes = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// ParseRecurrence 解析重复规则，空字符串表示不重复并返回 nil
+func ParseRecurrence(rule string) (*Recurrence, error) {
+	rule = strings.TrimSpace(rule)
+	if rule == "" {
+		return nil, nil
+	}
+
+	switch strings.ToLower(rule) {
+	case "daily", "weekly", "monthly", "yearly":
+		return &Recurrence{Freq: strings.ToUpper(rule), Interval: 1}, nil
+	}
+
+	rule = strings.TrimPrefix(rule, "RRULE:")
+	r := &Recurrence{Interval: 1}
+	for _, part := range strings.Split(rule, ";") {
+		if part == "" {
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return nil, errors.New("无效的重复规则")
+		}
+		key, value := strings.ToUpper(kv[0]), strings.ToUpper(kv[1])
+		switch key {
+		case "FREQ":
+			switch value {
+			case "DAILY", "WEEKLY", "MONTHLY", "YEARLY":
+				r.Freq = value
+			default:
+				return nil, errors.New("不支持的重复频率")
+			}
+		case "INTERVAL":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 || n > 1000 {
+				return nil, errors.New("无效的重复间隔")
+			}
+			r.Interval = n
+		case "BYDAY":
+			for _, code := range strings.Split(value, ",") {
+				day, ok := weekdayCodes[code]
+				if !ok {
+					return nil, errors.New("无效的重复星期")
+				}
+				r.ByDay = append(r.ByDay, day)
+			}
+		case "UNTIL":
+			until, err := parseUntil(value)
+			if err != nil {
+				return nil, errors.New("无效的重复截止时间")
+			}
+			r.Until = &until
+		default:
+			return nil, errors.New("不支持的重复规则: " + key)
+		}
+	}
+
+	if r.Freq == "" {
+		return nil, errors.New("重复规则缺少FREQ")
+	}
+	if len(r.ByDay) > 0 && r.Freq != "WEEKLY" {
+		return nil, errors.New("BYDAY仅支持每周重复")
+	}
+	sort.Slice(r.ByDay, func(i, j int) bool { return mondayIndex(r.ByDay[i]) < mondayIndex(r.ByDay[j]) })
+	return r, nil
+}
+
+func parseUntil(value string) (time.Time, error) {
+	for _, layout := range []string{"20060102T150405Z", "20060102"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			if layout == "20060102" {
+				// 仅有日期时包含当天全天
+				t = t.Add(24*time.Hour - time.Second)
+			}
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.New("invalid until")
+}
+
+// Next 返回以 start 为首次发生时间、严格晚于 after 的下一次发生时间，
+// 在 start 所在时区按墙上时间计算，没有后续发生时间时返回 false
+func (r *Recurrence) Next(start, after time.Time) (time.Time, bool) {
+	var next time.Time
+	switch r.Freq {
+	case "DAILY":
+		next = r.nextByStep(start, after, func(k int) time.Time { return start.AddDate(0, 0, k*r.Interval) }, 25*time.Hour*time.Duration(r.Interval))
+	case "WEEKLY":
+		if len(r.ByDay) == 0 {
+			next = r.nextByStep(start, after, func(k int) time.Time { return start.AddDate(0, 0, 7*k*r.Interval) }, (7*24+1)*time.Hour*time.Duration(r.Interval))
+		} else {
+			next = r.nextWeekly(start, after)
+		}
+	case "MONTHLY":
+		next = r.nextByStep(start, after, func(k int) time.Time { return addMonthsStrict(start, k*r.Interval) }, 31*25*time.Hour*time.Duration(r.Interval))
+	case "YEARLY":
+		next = r.nextByStep(start, after, func(k int) time.Time { return addMonthsStrict(start, 12*k*r.Interval) }, 366*25*time.Hour*time.Duration(r.Interval))
+	}
+
+	if next.IsZero() || (r.Until != nil && next.After(*r.Until)) {
+		return time.Time{}, false
+	}
+	return next, true
+}
+
+// nextByStep 按固定步长递推，maxStep 为一个步长的最长时长（含夏令时偏差），用于跳过 after 之前的大段区间
+func (r *Recurrence) nextByStep(start, after time.Time, nth func(k int) time.Time, maxStep time.Duration) time.Time {
+	k := 0
+	if after.After(start) {
+		// 先按最长步长估算跳过的次数，估算值不会越过 after 之后的第一次发生时间
+		k = int(after.Sub(start)/maxStep) - 1
+		if k < 0 {
+			k = 0
+		}
+	}
+	for steps := 0; steps < maxRecurrenceSteps; steps, k = steps+1, k+1 {
+		t := nth(k)
+		if t.IsZero() {
+			continue
+		}
+		if t.After(after) {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// nextWeekly 计算带 BYDAY 的每周重复，周从星期一开始
+func (r *Recurrence) nextWeekly(start, after time.Time) time.Time {
+	weekStart := start.AddDate(0, 0, -mondayIndex(start.Weekday()))
+	k := 0
+	if after.After(start) {
+		k = int(after.Sub(weekStart)/((7*24+1)*time.Hour))/r.Interval - 1
+		if k < 0 {
+			k = 0
+		}
+	}
+	for steps := 0; steps < maxRecurrenceSteps; steps, k = steps+1, k+1 {
+		week := weekStart.AddDate(0, 0, 7*k*r.Interval)
+		for _, day := range r.ByDay {
+			t := week.AddDate(0, 0, mondayIndex(day))
+			if t.Before(start) {
+				continue
+			}
+			if t.After(after) {
+				return t
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// addMonthsStrict 增加月份，目标月份没有对应日期（如2月30日）时返回零值表示跳过
+func addMonthsStrict(t time.Time, months int) time.Time {
+	result := t.AddDate(0, months, 0)
+	if result.Day() != t.Day() {
+		return time.Time{}
+	}
+	return result
+}
+
+func mondayIndex(day time.Weekday) int {
+	return (int(day) + 6) % 7
+}
@@ -0,0 +1,117 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRecurrence(t *testing.T) {
+	until := time.Date(2024, 6, 30, 23, 59, 59, 0, time.UTC)
+	tests := []struct {
+		rule    string
+		want    *Recurrence
+		wantErr string
+	}{
+		{rule: "", want: nil},
+		{rule: "daily", want: &Recurrence{Freq: "DAILY", Interval: 1}},
+		{rule: "Weekly", want: &Recurrence{Freq: "WEEKLY", Interval: 1}},
+		{rule: "RRULE:FREQ=MONTHLY;INTERVAL=3", want: &Recurrence{Freq: "MONTHLY", Interval: 3}},
+		{rule: "freq=weekly;byday=fr,mo", want: &Recurrence{Freq: "WEEKLY", Interval: 1, ByDay: []time.Weekday{time.Monday, time.Friday}}},
+		{rule: "FREQ=DAILY;UNTIL=20240630", want: &Recurrence{Freq: "DAILY", Interval: 1, Until: &until}},
+		{rule: "FREQ=DAILY;UNTIL=20240630T235959Z", want: &Recurrence{Freq: "DAILY", Interval: 1, Until: &until}},
+		{rule: "FREQ=HOURLY", wantErr: "不支持的重复频率"},
+		{rule: "FREQ=DAILY;INTERVAL=0", wantErr: "无效的重复间隔"},
+		{rule: "FREQ=DAILY;INTERVAL=1001", wantErr: "无效的重复间隔"},
+		{rule: "FREQ=WEEKLY;BYDAY=XX", wantErr: "无效的重复星期"},
+		{rule: "FREQ=DAILY;UNTIL=明天", wantErr: "无效的重复截止时间"},
+		{rule: "FREQ=DAILY;COUNT=3", wantErr: "不支持的重复规则: COUNT"},
+		{rule: "INTERVAL=2", wantErr: "重复规则缺少FREQ"},
+		{rule: "FREQ=DAILY;BYDAY=MO", wantErr: "BYDAY仅支持每周重复"},
+		{rule: "FREQ", wantErr: "无效的重复规则"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			got, err := ParseRecurrence(tt.rule)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("ParseRecurrence(%q) error = %v, want %q", tt.rule, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseRecurrence(%q) error = %v", tt.rule, err)
+			}
+			if !equalRecurrence(got, tt.want) {
+				t.Errorf("ParseRecurrence(%q) = %+v, want %+v", tt.rule, got, tt.want)
+			}
+		})
+	}
+}
+
+func equalRecurrence(a, b *Recurrence) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if a.Freq != b.Freq || a.Interval != b.Interval || len(a.ByDay) != len(b.ByDay) {
+		return false
+	}
+	for i := range a.ByDay {
+		if a.ByDay[i] != b.ByDay[i] {
+			return false
+		}
+	}
+	if a.Until == nil || b.Until == nil {
+		return a.Until == b.Until
+	}
+	return a.Until.Equal(*b.Until)
+}
+
+func TestRecurrenceNext(t *testing.T) {
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(loc *time.Location, year int, month time.Month, day, hour int) time.Time {
+		return time.Date(year, month, day, hour, 0, 0, 0, loc)
+	}
+
+	tests := []struct {
+		name   string
+		rule   string
+		start  time.Time
+		after  time.Time
+		want   time.Time
+		wantOK bool
+	}{
+		{name: "每天", rule: "daily", start: at(shanghai, 2024, 1, 1, 9), after: at(shanghai, 2024, 1, 1, 9), want: at(shanghai, 2024, 1, 2, 9), wantOK: true},
+		{name: "首次发生前返回首次", rule: "daily", start: at(shanghai, 2024, 1, 5, 9), after: at(shanghai, 2024, 1, 1, 0), want: at(shanghai, 2024, 1, 5, 9), wantOK: true},
+		{name: "跳过很久以前的发生时间", rule: "daily", start: at(shanghai, 2000, 1, 1, 9), after: at(shanghai, 2024, 3, 10, 12), want: at(shanghai, 2024, 3, 11, 9), wantOK: true},
+		{name: "隔两周", rule: "FREQ=WEEKLY;INTERVAL=2", start: at(shanghai, 2024, 1, 1, 9), after: at(shanghai, 2024, 1, 2, 0), want: at(shanghai, 2024, 1, 15, 9), wantOK: true},
+		{name: "每周一和周五", rule: "FREQ=WEEKLY;BYDAY=MO,FR", start: at(shanghai, 2024, 1, 1, 9), after: at(shanghai, 2024, 1, 1, 9), want: at(shanghai, 2024, 1, 5, 9), wantOK: true},
+		{name: "每周一和周五跨周", rule: "FREQ=WEEKLY;BYDAY=MO,FR", start: at(shanghai, 2024, 1, 1, 9), after: at(shanghai, 2024, 1, 5, 9), want: at(shanghai, 2024, 1, 8, 9), wantOK: true},
+		{name: "隔周的周三", rule: "FREQ=WEEKLY;INTERVAL=2;BYDAY=WE", start: at(shanghai, 2024, 1, 1, 9), after: at(shanghai, 2024, 1, 3, 9), want: at(shanghai, 2024, 1, 17, 9), wantOK: true},
+		{name: "每月31日跳过小月", rule: "monthly", start: at(shanghai, 2024, 1, 31, 9), after: at(shanghai, 2024, 1, 31, 9), want: at(shanghai, 2024, 3, 31, 9), wantOK: true},
+		{name: "闰日每年只在闰年发生", rule: "yearly", start: at(shanghai, 2024, 2, 29, 9), after: at(shanghai, 2024, 2, 29, 9), want: at(shanghai, 2028, 2, 29, 9), wantOK: true},
+		{name: "夏令时保持墙上时间", rule: "daily", start: at(newYork, 2024, 3, 9, 9), after: at(newYork, 2024, 3, 9, 9), want: at(newYork, 2024, 3, 10, 9), wantOK: true},
+		{name: "截止日期当天仍发生", rule: "FREQ=DAILY;UNTIL=20240103", start: time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC), after: time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC), want: time.Date(2024, 1, 3, 9, 0, 0, 0, time.UTC), wantOK: true},
+		{name: "超过截止时间", rule: "FREQ=DAILY;UNTIL=20240103", start: time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC), after: time.Date(2024, 1, 3, 9, 0, 0, 0, time.UTC), wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := ParseRecurrence(tt.rule)
+			if err != nil {
+				t.Fatalf("ParseRecurrence(%q) error = %v", tt.rule, err)
+			}
+			got, ok := r.Next(tt.start, tt.after)
+			if ok != tt.wantOK || (ok && !got.Equal(tt.want)) {
+				t.Errorf("Next() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
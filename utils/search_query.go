@@ -0,0 +1,184 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// SearchQuery 解析后的搜索语句
+type SearchQuery struct {
+	// Text 传给 MongoDB $text 的搜索字符串
+	Text string
+	// Terms 用于高亮的正向关键词和短语原文
+	Terms []string
+}
+
+// TextRange 文本中的高亮区间，以字符（rune）为单位
+type TextRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// ParseSearchQuery 解析搜索语句，支持 "短语" 精确匹配和 -关键词 / -"短语" 排除
+func ParseSearchQuery(q string, analyzer Analyzer) (*SearchQuery, error) {
+	var parts []string
+	var terms []string
+	hasPositive := false
+
+	for _, clause := range splitClauses(q) {
+		negate := strings.HasPrefix(clause, "-")
+		if negate {
+			clause = clause[1:]
+		}
+		phrase := strings.HasPrefix(clause, "\"")
+		raw := strings.Trim(clause, "\"")
+
+		tokens := analyzer.Tokenize(raw)
+		if len(tokens) == 0 {
+			continue
+		}
+
+		switch {
+		case negate && len(tokens) == 1:
+			parts = append(parts, "-"+tokens[0])
+		case negate:
+			parts = append(parts, "-\""+strings.Join(tokens, " ")+"\"")
+		case phrase || len(tokens) > 1:
+			// 多词元的关键词按短语匹配，避免中文被拆开后匹配到不相关的文档
+			parts = append(parts, "\""+strings.Join(tokens, " ")+"\"")
+			hasPositive = true
+			terms = append(terms, raw)
+		default:
+			parts = append(parts, tokens[0])
+			hasPositive = true
+			terms = append(terms, raw)
+		}
+	}
+
+	if !hasPositive {
+		return nil, errors.New("搜索关键词不能为空")
+	}
+
+	return &SearchQuery{
+		Text:  strings.Join(parts, " "),
+		Terms: terms,
+	}, nil
+}
+
+// splitClauses 按空白切分搜索语句，引号内的空白保留
+func splitClauses(q string) []string {
+	var clauses []string
+	var current strings.Builder
+	inQuote := false
+
+	for _, r := range q {
+		switch {
+		case r == '"':
+			inQuote = !inQuote
+			current.WriteRune(r)
+		case unicode.IsSpace(r) && !inQuote:
+			if current.Len() > 0 {
+				clauses = append(clauses, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if current.Len() > 0 {
+		clauses = append(clauses, current.String())
+	}
+	return clauses
+}
+
+// FindHighlights 查找关键词在文本中出现的所有位置（忽略大小写），区间按起点排序且互不重叠
+func FindHighlights(text string, terms []string) []TextRange {
+	lower := lowerRunes(text)
+	covered := make([]bool, len(lower))
+
+	for _, term := range terms {
+		needle := lowerRunes(term)
+		if len(needle) == 0 {
+			continue
+		}
+		for i := 0; i+len(needle) <= len(lower); i++ {
+			if runesEqual(lower[i:i+len(needle)], needle) {
+				for j := i; j < i+len(needle); j++ {
+					covered[j] = true
+				}
+			}
+		}
+	}
+
+	ranges := []TextRange{}
+	for i := 0; i < len(covered); i++ {
+		if !covered[i] {
+			continue
+		}
+		start := i
+		for i < len(covered) && covered[i] {
+			i++
+		}
+		ranges = append(ranges, TextRange{Start: start, End: i})
+	}
+	return ranges
+}
+
+// BuildSnippet 截取首个关键词附近的片段，返回片段及片段内的高亮区间
+func BuildSnippet(text string, terms []string, radius int) (string, []TextRange) {
+	runes := []rune(text)
+	highlights := FindHighlights(text, terms)
+
+	start := 0
+	if len(highlights) > 0 {
+		start = highlights[0].Start - radius
+		if start < 0 {
+			start = 0
+		}
+	}
+	end := start + 2*radius
+	if end > len(runes) {
+		end = len(runes)
+	}
+
+	snippet := string(runes[start:end])
+	prefix := ""
+	if start > 0 {
+		prefix = "…"
+	}
+	if end < len(runes) {
+		snippet += "…"
+	}
+
+	offset := utf8.RuneCountInString(prefix)
+	var inSnippet []TextRange
+	for _, h := range highlights {
+		if h.Start >= start && h.End <= end {
+			inSnippet = append(inSnippet, TextRange{Start: h.Start - start + offset, End: h.End - start + offset})
+		}
+	}
+	if inSnippet == nil {
+		inSnippet = []TextRange{}
+	}
+	return prefix + snippet, inSnippet
+}
+
+// lowerRunes 逐字符转小写，保证结果与原文字符位置一一对应
+func lowerRunes(s string) []rune {
+	runes := []rune(s)
+	for i, r := range runes {
+		runes[i] = unicode.ToLower(r)
+	}
+	return runes
+}
+
+func runesEqual(a, b []rune) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,131 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+)
+
+// MaxImagePixels 解码图片的像素上限。压缩后很小的图片可以声明极大的尺寸，
+// 解码前先读取尺寸，超出上限时拒绝，避免分配大量内存
+const MaxImagePixels = 25_000_000
+
+// ErrImageTooLarge 图片像素超过 MaxImagePixels
+var ErrImageTooLarge = errors.New("图片像素过多")
+
+// ThumbnailSupported 判断MIME类型是否支持生成缩略图
+func ThumbnailSupported(contentType string) bool {
+	switch contentType {
+	case "image/jpeg", "image/png", "image/gif":
+		return true
+	}
+	return false
+}
+
+// MakeThumbnail 将图片等比缩小到最长边不超过 maxSize，返回缩略图数据、MIME类型和原图尺寸
+// JPEG 输出为 JPEG，其余格式输出为 PNG 以保留透明通道
+func MakeThumbnail(data []byte, contentType string, maxSize int) ([]byte, string, int, int, error) {
+	src, err := DecodeImage(data, contentType)
+	if err != nil {
+		return nil, "", 0, 0, err
+	}
+
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	dst := scaleDown(src, maxSize)
+
+	var buf bytes.Buffer
+	if contentType == "image/jpeg" {
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 80})
+		return buf.Bytes(), "image/jpeg", width, height, err
+	}
+	err = png.Encode(&buf, dst)
+	return buf.Bytes(), "image/png", width, height, err
+}
+
+// DecodeImage 按MIME类型解码图片，未知类型按 PNG 解码。像素超过 MaxImagePixels 时返回 ErrImageTooLarge
+func DecodeImage(data []byte, contentType string) (image.Image, error) {
+	cfg, err := DecodeImageConfig(data, contentType)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width*cfg.Height > MaxImagePixels {
+		return nil, ErrImageTooLarge
+	}
+
+	switch contentType {
+	case "image/jpeg":
+		return jpeg.Decode(bytes.NewReader(data))
+	case "image/gif":
+		return gif.Decode(bytes.NewReader(data))
+	default:
+		return png.Decode(bytes.NewReader(data))
+	}
+}
+
+// DecodeImageConfig 只读取图片头部的尺寸，不解码像素
+func DecodeImageConfig(data []byte, contentType string) (image.Config, error) {
+	switch contentType {
+	case "image/jpeg":
+		return jpeg.DecodeConfig(bytes.NewReader(data))
+	case "image/gif":
+		return gif.DecodeConfig(bytes.NewReader(data))
+	default:
+		return png.DecodeConfig(bytes.NewReader(data))
+	}
+}
+
+// scaleDown 使用区域平均算法缩小图片，图片本身不超过 maxSize 时原样返回
+func scaleDown(src image.Image, maxSize int) image.Image {
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w <= maxSize && h <= maxSize {
+		return src
+	}
+
+	dw, dh := maxSize, maxSize
+	if w > h {
+		dh = h * maxSize / w
+	} else {
+		dw = w * maxSize / h
+	}
+	if dw < 1 {
+		dw = 1
+	}
+	if dh < 1 {
+		dh = 1
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0 := bounds.Min.Y + y*h/dh
+		y1 := bounds.Min.Y + (y+1)*h/dh
+		for x := 0; x < dw; x++ {
+			x0 := bounds.Min.X + x*w/dw
+			x1 := bounds.Min.X + (x+1)*w/dw
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					c := color.NRGBA64Model.Convert(src.At(sx, sy)).(color.NRGBA64)
+					r += uint64(c.R)
+					g += uint64(c.G)
+					b += uint64(c.B)
+					a += uint64(c.A)
+					n++
+				}
+			}
+			dst.SetNRGBA(x, y, color.NRGBA{
+				R: uint8(r / n >> 8),
+				G: uint8(g / n >> 8),
+				B: uint8(b / n >> 8),
+				A: uint8(a / n >> 8),
+			})
+		}
+	}
+	return dst
+}